@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/server"
+)
+
+// adminTokenFile holds the bearer token required by the admin endpoints,
+// typically mounted from a Secret. They are served on the admission port,
+// which anything that can reach the Service can call, so without a token
+// they are not served at all.
+var adminTokenFile = ""
+
+// loadAdminToken reads the token in path, without surrounding whitespace.
+func loadAdminToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", errors.New("token file is empty")
+	}
+	return token, nil
+}
+
+// newAdminChain returns the webhook middleware with the bearer token check
+// in its auth stage.
+func newAdminChain(middleware *server.Chain, token string) *server.Chain {
+	return middleware.Clone().Use(server.StageAuth, server.BearerToken(token))
+}
+
+// registerAdminHandlers registers the endpoints that expose or change the
+// state of the webhook on mux, behind chain.
+func registerAdminHandlers(mux *http.ServeMux, chain *server.Chain) {
+	mux.Handle("/maintenance", chain.ThenFunc(handleMaintenance))
+	mux.Handle("/api/v1/rules/overrides", chain.ThenFunc(handleRuleOverrides))
+	mux.Handle("/debug/rules", chain.ThenFunc(handleDebugRules))
+	mux.Handle("/debug/config", chain.ThenFunc(handleDebugConfig))
+	mux.Handle("/debug/objects", chain.ThenFunc(handleDebugObjects))
+	mux.Handle("/debug/explain", chain.ThenFunc(handleDebugExplain))
+	mux.Handle("/debug/unsupported-kinds", chain.ThenFunc(handleDebugUnsupportedKinds))
+	mux.Handle("/debug/runtime", chain.ThenFunc(handleDebugRuntime))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/server"
+)
+
+func TestLoadAdminToken(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "token")
+	if err := os.WriteFile(path, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write token: %v", err)
+	}
+
+	token, err := loadAdminToken(path)
+	if err != nil || token != "s3cret" {
+		t.Errorf("Expected token s3cret, got %q (%v)", token, err)
+	}
+
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte(" \n"), 0o600); err != nil {
+		t.Fatalf("Failed to write token: %v", err)
+	}
+	if _, err := loadAdminToken(empty); err == nil {
+		t.Errorf("Expected an error for an empty token file, got nil")
+	}
+}
+
+func TestRegisterAdminHandlers_RequireToken(t *testing.T) {
+	mux := http.NewServeMux()
+	registerAdminHandlers(mux, newAdminChain(server.NewChain(), "s3cret"))
+
+	for _, path := range []string{"/maintenance", "/api/v1/rules/overrides", "/debug/rules", "/debug/config", "/debug/objects", "/debug/runtime"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected %s to require the token, got %d", path, w.Code)
+		}
+
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Authorization", "Bearer s3cret")
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected %s to be served with the token, got %d", path, w.Code)
+		}
+	}
+}
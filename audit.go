@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// auditAnnotations controls whether decisions are explained in the apiserver
+// audit log. The apiserver prefixes every key with the webhook name, so
+// "decision" is recorded as "application.admission.webhook/decision" with the
+// bundled ValidatingWebhookConfiguration.
+var auditAnnotations = true
+
+// auditPatch adds the normalized JSON patch of each significant change, up
+// to maxAuditPatchBytes, to the audit annotations. It is off by default since
+// audit events are kept for every write and the patch may carry object data
+// the audit log should not.
+var auditPatch = false
+
+// maxAuditChangedPaths bounds the changed-paths annotation, since audit
+// events are kept for every write.
+const maxAuditChangedPaths = 20
+
+// maxAuditPatchBytes bounds the patch annotation. A larger patch is left out
+// rather than truncated, as a partial patch would be misleading.
+const maxAuditPatchBytes = 4096
+
+// buildAuditAnnotations describes the final decision for resp and, when the
+// objects were compared, what changed and which ignore paths matched.
+func buildAuditAnnotations(resp *admissionv1.AdmissionResponse, cmp *comparison) map[string]string {
+	annotations := map[string]string{"decision": "allow"}
+	if !resp.Allowed {
+		annotations["decision"] = "deny"
+	}
+
+	if cmp == nil {
+		annotations["reason"] = "not-compared"
+		return annotations
+	}
+
+	if !cmp.changed() {
+		annotations["reason"] = "no-op"
+	} else {
+		annotations["reason"] = "changed"
+		annotations["changed-sections"] = strings.Join(cmp.changedSections(), ",")
+		annotations["change-categories"] = strings.Join(cmp.categories(), ",")
+		if !cmp.partial {
+			annotations["changed-paths"] = joinChangedPaths(diffObjects(cmp.oldObj, cmp.newObj))
+			if auditPatch {
+				if patch, err := json.Marshal(patchObjects(cmp.oldObj, cmp.newObj)); err == nil && len(patch) <= maxAuditPatchBytes {
+					annotations["normalized-patch"] = string(patch)
+				}
+			}
+		}
+	}
+	if len(cmp.ignoredHits) > 0 {
+		annotations["ignored-paths"] = strings.Join(cmp.ignoredHits, ",")
+	}
+	if cmp.variant != "" {
+		annotations["ruleset"] = cmp.variant
+	}
+	return annotations
+}
+
+func joinChangedPaths(diffs []difference) string {
+	paths := make([]string, 0, maxAuditChangedPaths+1)
+	for i, diff := range diffs {
+		if i == maxAuditChangedPaths {
+			paths = append(paths, fmt.Sprintf("+%d more", len(diffs)-i))
+			break
+		}
+		paths = append(paths, diff.Path)
+	}
+	return strings.Join(paths, ",")
+}
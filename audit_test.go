@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestHandleAdmissionReview_AuditAnnotations(t *testing.T) {
+	defer func(enabled bool) { auditPatch = enabled }(auditPatch)
+
+	tests := []struct {
+		name     string
+		uid      string
+		object   string
+		patch    bool
+		expected map[string]string
+	}{
+		{
+			name:   "no-op",
+			uid:    "audit-noop-uid",
+			object: `{"metadata": {"name": "d"}, "spec": {"json": "{}"}, "status": {}}`,
+			expected: map[string]string{
+				"decision": "deny",
+				"reason":   "no-op",
+				"ruleset":  rulesetStable,
+			},
+		},
+		{
+			name:   "spec change",
+			uid:    "audit-change-uid",
+			object: `{"metadata": {"name": "d"}, "spec": {"json": "{\"title\": \"x\"}"}, "status": {}}`,
+			expected: map[string]string{
+				"decision":          "allow",
+				"reason":            "changed",
+				"changed-sections":  "spec",
+				"change-categories": "spec-change",
+				"changed-paths":     "spec.json.title",
+				"ruleset":           rulesetStable,
+			},
+		},
+		{
+			name:   "spec change with patch",
+			uid:    "audit-patch-uid",
+			object: `{"metadata": {"name": "d"}, "spec": {"json": "{\"title\": \"x\"}"}, "status": {}}`,
+			patch:  true,
+			expected: map[string]string{
+				"decision":          "allow",
+				"reason":            "changed",
+				"changed-sections":  "spec",
+				"change-categories": "spec-change",
+				"changed-paths":     "spec.json.title",
+				"normalized-patch":  `[{"op":"add","path":"/spec/json/title","value":"x"}]`,
+				"ruleset":           rulesetStable,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditPatch = tt.patch
+			review := admissionv1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request: &admissionv1.AdmissionRequest{
+					UID:       types.UID(tt.uid),
+					Kind:      metav1.GroupVersionKind{Group: "grafana.integreatly.org", Version: "v1beta1", Kind: "GrafanaDashboard"},
+					Resource:  metav1.GroupVersionResource{Group: "grafana.integreatly.org", Version: "v1beta1", Resource: "grafanadashboards"},
+					Operation: admissionv1.Update,
+					OldObject: runtime.RawExtension{Raw: []byte(`{"metadata": {"name": "d"}, "spec": {"json": "{}"}, "status": {}}`)},
+					Object:    runtime.RawExtension{Raw: []byte(tt.object)},
+				},
+			}
+			reqBytes, _ := json.Marshal(review)
+
+			w := httptest.NewRecorder()
+			handleAdmissionReview(w, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(reqBytes)))
+
+			var resp admissionv1.AdmissionReview
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			assertValidAdmissionReview(t, review.Request.UID, resp)
+			if !reflect.DeepEqual(resp.Response.AuditAnnotations, tt.expected) {
+				t.Errorf("Expected audit annotations %v, got %v", tt.expected, resp.Response.AuditAnnotations)
+			}
+		})
+	}
+}
+
+func TestJoinChangedPaths_Truncates(t *testing.T) {
+	var diffs []difference
+	for i := 0; i < maxAuditChangedPaths+5; i++ {
+		diffs = append(diffs, difference{Path: fmt.Sprintf("spec.field%d", i)})
+	}
+
+	joined := joinChangedPaths(diffs)
+	if !strings.HasSuffix(joined, ",+5 more") {
+		t.Errorf("Expected truncated paths to end with +5 more, got %s", joined)
+	}
+	if n := strings.Count(joined, "spec.field"); n != maxAuditChangedPaths {
+		t.Errorf("Expected %d paths, got %d", maxAuditChangedPaths, n)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/authorizer"
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Significant changes that every local stage allowed can be sent to an
+// external authorizer over gRPC for the final decision, so a policy engine can
+// be plugged in without forking the webhook. Its decisions are cached in the
+// state store for authorizerCacheTTL, keyed by everything sent except the UID.
+var (
+	authorizerAddress       = ""
+	authorizerCAFile        = ""
+	authorizerPlaintext     = false
+	authorizerTimeout       = time.Second
+	authorizerFailurePolicy = "Ignore"
+	authorizerCacheTTL      = time.Minute
+)
+
+var externalAuthorizer *authorizer.Client
+
+var (
+	// Counter for external authorizer decisions, by result
+	authorizerRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_authorizer_requests_total",
+			Help: "Total number of significant changes reviewed by the external authorizer, differentiated by result.",
+		},
+		[]string{"result"}, // result is "allowed", "denied" or "error"
+	)
+
+	// Counter for external authorizer decisions served from the cache
+	authorizerCacheHitsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_authorizer_cache_hits_total",
+			Help: "Total number of external authorizer decisions served from the cache.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(authorizerRequestsTotal)
+	prometheus.MustRegister(authorizerCacheHitsTotal)
+}
+
+// configureAuthorizer creates the client for the external authorizer.
+func configureAuthorizer() error {
+	if authorizerFailurePolicy != "Ignore" && authorizerFailurePolicy != "Fail" {
+		return fmt.Errorf("invalid failure policy %q, must be Ignore or Fail", authorizerFailurePolicy)
+	}
+	if authorizerTimeout <= 0 || authorizerCacheTTL < 0 {
+		return fmt.Errorf("invalid timeout %s or cache TTL %s", authorizerTimeout, authorizerCacheTTL)
+	}
+
+	creds := insecure.NewCredentials()
+	if !authorizerPlaintext {
+		tlsConfig, err := clientTLSConfig(authorizerCAFile)
+		if err != nil {
+			return fmt.Errorf("CA bundle: %w", err)
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	client, err := authorizer.NewClient(authorizerAddress, creds)
+	if err != nil {
+		return err
+	}
+	externalAuthorizer = client
+	return nil
+}
+
+// applyExternalAuthorizer asks the external authorizer for the final decision
+// on a significant change that is still allowed.
+func applyExternalAuthorizer(ctx context.Context, req *admissionv1.AdmissionRequest, cmp *comparison, resp *admissionv1.AdmissionResponse) {
+	if externalAuthorizer == nil || cmp == nil || !cmp.changed() || !resp.Allowed {
+		return
+	}
+
+	review := &authorizer.ReviewRequest{
+		Group:            req.Kind.Group,
+		Version:          req.Kind.Version,
+		Kind:             req.Kind.Kind,
+		Namespace:        req.Namespace,
+		Name:             req.Name,
+		Operation:        string(req.Operation),
+		Username:         req.UserInfo.Username,
+		Groups:           req.UserInfo.Groups,
+		ChangedSections:  cmp.changedSections(),
+		ChangeCategories: cmp.categories(),
+		OldObject:        req.OldObject.Raw,
+		Object:           req.Object.Raw,
+	}
+
+	decision, err := reviewWithCache(ctx, review, string(req.UID))
+	if err != nil {
+		authorizerRequestsTotal.WithLabelValues("error").Inc()
+		loggerFromContext(ctx).Errorf("External authorizer failed: %v", err)
+		if authorizerFailurePolicy == "Fail" {
+			resp.Allowed = false
+			resp.Result = &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: "external authorizer failed",
+				Code:    http.StatusInternalServerError,
+			}
+		}
+		return
+	}
+
+	resp.Warnings = append(resp.Warnings, decision.Warnings...)
+	if decision.Allowed {
+		authorizerRequestsTotal.WithLabelValues("allowed").Inc()
+		return
+	}
+
+	authorizerRequestsTotal.WithLabelValues("denied").Inc()
+	code := decision.Code
+	if code == 0 {
+		code = http.StatusForbidden
+	}
+	loggerFromContext(ctx).Infof("Denied by external authorizer: %s", decision.Message)
+
+	resp.Allowed = false
+	resp.Result = &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Message: decision.Message,
+		Code:    code,
+	}
+}
+
+// reviewWithCache returns the cached decision for review, or asks the
+// authorizer and caches its decision. The UID is only sent, not cached on.
+func reviewWithCache(ctx context.Context, review *authorizer.ReviewRequest, uid string) (*authorizer.Decision, error) {
+	codec := authorizer.Codec{}
+	key := ""
+	if authorizerCacheTTL > 0 {
+		encoded, err := codec.Marshal(review)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(encoded)
+		key = "authorizer/" + hex.EncodeToString(sum[:])
+
+		cached, err := state.Get(ctx, key)
+		if err == nil {
+			decision := &authorizer.Decision{}
+			if err := codec.Unmarshal(cached, decision); err == nil {
+				authorizerCacheHitsTotal.Inc()
+				return decision, nil
+			}
+		} else if !errors.Is(err, store.ErrNotFound) {
+			stateStoreFailed(ctx, "authorizer cache lookup", err)
+		}
+	}
+
+	review.UID = uid
+	callCtx, cancel := context.WithTimeout(ctx, authorizerTimeout)
+	defer cancel()
+	decision, err := externalAuthorizer.Review(callCtx, review)
+	if err != nil {
+		return nil, err
+	}
+
+	if key != "" {
+		encoded, err := codec.Marshal(decision)
+		if err == nil {
+			err = state.Set(ctx, key, encoded, authorizerCacheTTL)
+		}
+		if err != nil {
+			stateStoreFailed(ctx, "authorizer cache update", err)
+		}
+	}
+	return decision, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/authorizer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// startAuthorizer serves an external authorizer that denies changes by bob
+// and counts the reviews it receives.
+func startAuthorizer(t *testing.T) (*authorizer.Client, *atomic.Int32) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	var reviews atomic.Int32
+	srv := grpc.NewServer(grpc.ForceServerCodec(authorizer.Codec{}), grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+		var req authorizer.ReviewRequest
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		reviews.Add(1)
+		if req.Username == "bob" {
+			return stream.SendMsg(&authorizer.Decision{Message: "bob may not change dashboards"})
+		}
+		return stream.SendMsg(&authorizer.Decision{Allowed: true})
+	}))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	client, err := authorizer.NewClient(lis.Addr().String(), insecure.NewCredentials())
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, &reviews
+}
+
+func authorizerRequest(uid, username string) *admissionv1.AdmissionRequest {
+	return &admissionv1.AdmissionRequest{
+		UID:       "authorizer-" + types.UID(uid),
+		Kind:      metav1.GroupVersionKind{Kind: "GrafanaDashboard"},
+		Namespace: "ns",
+		Name:      "authorized",
+		Operation: admissionv1.Update,
+		UserInfo:  authenticationv1.UserInfo{Username: username},
+		OldObject: runtime.RawExtension{Raw: []byte(`{"spec": {}}`)},
+		Object:    runtime.RawExtension{Raw: []byte(`{"spec": {"url": "https://grafana.example.com"}}`)},
+	}
+}
+
+func TestApplyExternalAuthorizer(t *testing.T) {
+	client, reviews := startAuthorizer(t)
+	defer func(c *authorizer.Client) { externalAuthorizer = c }(externalAuthorizer)
+	externalAuthorizer = client
+
+	cmp := &comparison{specChanged: true}
+
+	resp := &admissionv1.AdmissionResponse{Allowed: true}
+	applyExternalAuthorizer(context.Background(), authorizerRequest("1", "bob"), cmp, resp)
+	if resp.Allowed {
+		t.Fatalf("Expected the change to be denied by the authorizer")
+	}
+	if resp.Result.Code != http.StatusForbidden || resp.Result.Message != "bob may not change dashboards" {
+		t.Errorf("Expected a 403 with the authorizer message, got %+v", resp.Result)
+	}
+
+	// The same change under a new UID is answered from the cache
+	resp = &admissionv1.AdmissionResponse{Allowed: true}
+	applyExternalAuthorizer(context.Background(), authorizerRequest("2", "bob"), cmp, resp)
+	if resp.Allowed {
+		t.Errorf("Expected the cached decision to deny the change")
+	}
+	if n := reviews.Load(); n != 1 {
+		t.Errorf("Expected 1 review, got %d", n)
+	}
+
+	resp = &admissionv1.AdmissionResponse{Allowed: true}
+	applyExternalAuthorizer(context.Background(), authorizerRequest("3", "alice"), cmp, resp)
+	if !resp.Allowed {
+		t.Errorf("Expected the change to be allowed by the authorizer, got %+v", resp.Result)
+	}
+
+	// No-op updates are not reviewed
+	applyExternalAuthorizer(context.Background(), authorizerRequest("4", "alice"), &comparison{}, &admissionv1.AdmissionResponse{})
+	if n := reviews.Load(); n != 2 {
+		t.Errorf("Expected 2 reviews, got %d", n)
+	}
+}
+
+func TestApplyExternalAuthorizer_FailurePolicy(t *testing.T) {
+	client, err := authorizer.NewClient("127.0.0.1:1", insecure.NewCredentials())
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	defer func(c *authorizer.Client, policy string) {
+		externalAuthorizer, authorizerFailurePolicy = c, policy
+	}(externalAuthorizer, authorizerFailurePolicy)
+	externalAuthorizer = client
+
+	for policy, expectedAllowed := range map[string]bool{"Ignore": true, "Fail": false} {
+		authorizerFailurePolicy = policy
+		resp := &admissionv1.AdmissionResponse{Allowed: true}
+		applyExternalAuthorizer(context.Background(), authorizerRequest("unreachable-"+policy, "carol-"+policy), &comparison{specChanged: true}, resp)
+		if resp.Allowed != expectedAllowed {
+			t.Errorf("Expected allowed=%t with failure policy %s, got %t", expectedAllowed, policy, resp.Allowed)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// batchPair is one line of a /validate-batch request: the live object and the
+// version that would be applied on top of it.
+type batchPair struct {
+	OldObject json.RawMessage `json:"oldObject"`
+	Object    json.RawMessage `json:"object"`
+}
+
+// batchDecision is one line of a /validate-batch response.
+type batchDecision struct {
+	Index           int      `json:"index"`
+	Name            string   `json:"name,omitempty"`
+	Allowed         bool     `json:"allowed"`
+	ChangedSections []string `json:"changedSections,omitempty"`
+	Error           string   `json:"error,omitempty"`
+}
+
+// handleValidateBatch evaluates a stream of NDJSON object pairs, or a
+// multi-document YAML stream of pairs, with the same comparison as /validate
+// and streams back one decision per pair. It is meant
+// for CI pipelines pre-checking manifests and is not called by the apiserver,
+// so it leaves the admission metrics untouched. The whole stream is limited
+// to maxRequestBodyBytes; past it the request fails with 413 if no decision
+// was written yet, or ends with an error line otherwise.
+func handleValidateBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+
+	var decode func(v interface{}) error
+	if isYAMLRequest(r) {
+		decode = newYAMLDocuments(r.Body).decode
+	} else {
+		decode = json.NewDecoder(r.Body).Decode
+	}
+
+	enc := json.NewEncoder(w)
+	for index := 0; ; index++ {
+		var pair batchPair
+		err := decode(&pair)
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) && index == 0 {
+			http.Error(w, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			// The stream cannot be resynchronized after a syntax error.
+			if err := enc.Encode(batchDecision{Index: index, Error: fmt.Sprintf("failed to decode pair: %v", err)}); err != nil {
+				log.Errorf("Failed to write batch decision: %v", err)
+			}
+			return
+		}
+
+		if err := enc.Encode(evaluatePair(r.Context(), index, pair)); err != nil {
+			log.Errorf("Failed to write batch decision: %v", err)
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// evaluatePair decides one batch pair like /validate would decide the update.
+func evaluatePair(ctx context.Context, index int, pair batchPair) batchDecision {
+	decision := batchDecision{Index: index}
+
+	// Without both versions there is nothing to diff, as in /validate: a pair
+	// without a live object is a creation, one without an object a deletion
+	if isMissingObject(pair.OldObject) || isMissingObject(pair.Object) {
+		decision.Allowed = true
+		return decision
+	}
+
+	cmp, err := compareObjects(ctx, activeRuleset(kindFilter.kind), pair.OldObject, pair.Object)
+	if err != nil {
+		decision.Error = err.Error()
+		return decision
+	}
+
+	if metadata, ok := cmp.newObj["metadata"].(map[string]interface{}); ok {
+		name, _ := metadata["name"].(string)
+		if namespace, _ := metadata["namespace"].(string); namespace != "" {
+			name = namespace + "/" + name
+		}
+		decision.Name = name
+	}
+
+	decision.Allowed = cmp.changed()
+	decision.ChangedSections = cmp.changedSections()
+	return decision
+}
+
+// isMissingObject reports whether raw, one side of a batch pair, is absent.
+func isMissingObject(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleValidateBatch(t *testing.T) {
+	body := strings.Join([]string{
+		`{"oldObject": {"metadata": {"name": "a", "namespace": "ns"}, "spec": {"json": "1"}}, "object": {"metadata": {"name": "a", "namespace": "ns"}, "spec": {"json": "2"}}}`,
+		`{"oldObject": {"metadata": {"name": "b"}, "status": {"lastResync": "1"}}, "object": {"metadata": {"name": "b"}, "status": {"lastResync": "2"}}}`,
+		`{"oldObject": "not an object", "object": {}}`,
+		`{"object": {"metadata": {"name": "new"}}}`,
+	}, "\n")
+
+	req := httptest.NewRequest(http.MethodPost, "/validate-batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handleValidateBatch(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", w.Code)
+	}
+
+	var decisions []batchDecision
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var d batchDecision
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			t.Fatalf("Failed to decode decision %q: %v", scanner.Text(), err)
+		}
+		decisions = append(decisions, d)
+	}
+
+	if len(decisions) != 4 {
+		t.Fatalf("Expected 4 decisions, got %d", len(decisions))
+	}
+
+	if !decisions[0].Allowed || decisions[0].Name != "ns/a" || len(decisions[0].ChangedSections) != 1 || decisions[0].ChangedSections[0] != "spec" {
+		t.Errorf("Unexpected decision for changed pair: %+v", decisions[0])
+	}
+	if decisions[1].Allowed || decisions[1].Name != "b" {
+		t.Errorf("Unexpected decision for no-op pair: %+v", decisions[1])
+	}
+	if decisions[2].Error == "" || decisions[2].Index != 2 {
+		t.Errorf("Expected an error for malformed pair, got %+v", decisions[2])
+	}
+	if !decisions[3].Allowed || decisions[3].Error != "" {
+		t.Errorf("Expected a pair without oldObject to be allowed, got %+v", decisions[3])
+	}
+}
+
+func TestHandleValidateBatch_MalformedStream(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/validate-batch", strings.NewReader(`{"oldObject": {}, "object": {}}`+"\n{broken"))
+	w := httptest.NewRecorder()
+
+	handleValidateBatch(w, req)
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d: %q", len(lines), w.Body.String())
+	}
+	if !strings.Contains(lines[1], `"error"`) {
+		t.Errorf("Expected the last line to report the decode error, got %s", lines[1])
+	}
+}
+
+func TestHandleValidateBatch_TooLarge(t *testing.T) {
+	defer func(n int64) { maxRequestBodyBytes = n }(maxRequestBodyBytes)
+	pair := `{"oldObject": {"spec": {"json": "1"}}, "object": {"spec": {"json": "2"}}}` + "\n"
+	maxRequestBodyBytes = int64(len(pair)) + 8
+	handler := newMiddlewareChain().ThenFunc(handleValidateBatch)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/validate-batch", strings.NewReader(strings.Repeat(" ", 64)+pair)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status code 413, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/validate-batch", strings.NewReader(pair+pair)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200 once decisions were streamed, got %d", w.Code)
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], "request body too large") {
+		t.Errorf("Expected one decision and an error line, got %q", w.Body.String())
+	}
+}
+
+func TestEvaluatePair(t *testing.T) {
+	obj := json.RawMessage(`{"metadata": {"name": "a"}, "spec": {"json": "1"}}`)
+	tests := []struct {
+		name            string
+		pair            batchPair
+		expectedAllowed bool
+	}{
+		{"both objects", batchPair{OldObject: obj, Object: obj}, false},
+		{"no old object", batchPair{Object: obj}, true},
+		{"no object", batchPair{OldObject: obj}, true},
+		{"neither object", batchPair{}, true},
+		{"null object", batchPair{OldObject: obj, Object: json.RawMessage("null")}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := evaluatePair(context.Background(), 0, tt.pair)
+			if decision.Error != "" {
+				t.Fatalf("Unexpected error: %s", decision.Error)
+			}
+			if decision.Allowed != tt.expectedAllowed {
+				t.Errorf("Expected allowed to be %t, got %+v", tt.expectedAllowed, decision)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// responseCacheTTL is how long an encoded AdmissionReview response is kept
+// for its request UID. The apiserver reuses the UID when it retries the same
+// admission call, so a short TTL is enough; zero disables the cache.
+var responseCacheTTL = 10 * time.Second
+
+var (
+	// Counter for admission requests answered from the response cache
+	responseCacheHitsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_response_cache_hits_total",
+			Help: "Total number of admission requests answered from the response cache.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(responseCacheHitsTotal)
+}
+
+// responseCache holds encoded admission responses keyed by request UID.
+type responseCache struct {
+	store store.Store
+}
+
+var admissionResponses = newResponseCache(state)
+
+func newResponseCache(s store.Store) *responseCache {
+	return &responseCache{store: s}
+}
+
+// get returns the cached response body for uid if it has not expired.
+func (c *responseCache) get(ctx context.Context, uid types.UID) ([]byte, bool) {
+	if uid == "" || responseCacheTTL <= 0 {
+		return nil, false
+	}
+
+	body, err := c.store.Get(ctx, "responses/"+string(uid))
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			stateStoreFailed(ctx, "response cache lookup", err)
+		}
+		return nil, false
+	}
+	return body, true
+}
+
+// put stores body for uid until responseCacheTTL has passed.
+func (c *responseCache) put(ctx context.Context, uid types.UID, body []byte) {
+	if uid == "" || responseCacheTTL <= 0 {
+		return
+	}
+
+	if err := c.store.Set(ctx, "responses/"+string(uid), body, responseCacheTTL); err != nil {
+		stateStoreFailed(ctx, "response cache update", err)
+	}
+}
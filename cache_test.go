@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/store"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestHandleAdmissionReview_RetryReturnsCachedResponse(t *testing.T) {
+	review := func(oldRaw, newRaw string) []byte {
+		reqBytes, err := json.Marshal(admissionv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "admission.k8s.io/v1",
+				Kind:       "AdmissionReview",
+			},
+			Request: &admissionv1.AdmissionRequest{
+				UID:       "test-uid-cached-retry",
+				Kind:      metav1.GroupVersionKind{Kind: "GrafanaDashboard"},
+				Operation: admissionv1.Update,
+				OldObject: runtime.RawExtension{Raw: []byte(oldRaw)},
+				Object:    runtime.RawExtension{Raw: []byte(newRaw)},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to marshal request: %v", err)
+		}
+		return reqBytes
+	}
+
+	// The first call carries a spec change and is allowed.
+	first := httptest.NewRecorder()
+	handleAdmissionReview(first, httptest.NewRequest(http.MethodPost, "/validate",
+		bytes.NewReader(review(`{"spec": {"json": "a"}}`, `{"spec": {"json": "b"}}`))))
+
+	// A retry with the same UID must get the identical response even though
+	// this body on its own would be denied as a no-op.
+	retry := httptest.NewRecorder()
+	handleAdmissionReview(retry, httptest.NewRequest(http.MethodPost, "/validate",
+		bytes.NewReader(review(`{"spec": {}}`, `{"spec": {}}`))))
+
+	if !bytes.Equal(first.Body.Bytes(), retry.Body.Bytes()) {
+		t.Errorf("Expected cached response %s, got %s", first.Body.String(), retry.Body.String())
+	}
+}
+
+func TestResponseCache_Expiry(t *testing.T) {
+	defer func(ttl time.Duration) { responseCacheTTL = ttl }(responseCacheTTL)
+	responseCacheTTL = 20 * time.Millisecond
+
+	c := newResponseCache(store.NewMemory())
+	c.put(context.Background(), "uid", []byte("body"))
+
+	if body, ok := c.get(context.Background(), "uid"); !ok || string(body) != "body" {
+		t.Fatalf("Expected cached body, got %q (found=%t)", body, ok)
+	}
+
+	time.Sleep(2 * responseCacheTTL)
+
+	if _, ok := c.get(context.Background(), "uid"); ok {
+		t.Errorf("Expected entry to have expired")
+	}
+}
+
+func TestResponseCache_IgnoresEmptyUID(t *testing.T) {
+	c := newResponseCache(store.NewMemory())
+	c.put(context.Background(), types.UID(""), []byte("body"))
+
+	if _, ok := c.get(context.Background(), ""); ok {
+		t.Errorf("Expected empty UID not to be cached")
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// canaryRulesFile is an optional JSON rule layer that is only applied to
+// canaryPercent of the objects, chosen by a hash of the object UID so an
+// object always gets the same ruleset.
+var canaryRulesFile = ""
+
+// canaryPercent is the share of requests evaluated with the canary ruleset.
+var canaryPercent = 0
+
+// Ruleset variants a request can be evaluated with.
+const (
+	rulesetStable = "stable"
+	rulesetCanary = "canary"
+)
+
+var (
+	// Counter for requests evaluated with each ruleset variant
+	rulesetRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_ruleset_requests_total",
+			Help: "Total number of admission requests evaluated, differentiated by ruleset variant.",
+		},
+		[]string{"variant"}, // variant is "stable" or "canary"
+	)
+
+	// Counter for canary requests whose decision differs from the stable ruleset
+	canaryDivergenceTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_canary_divergence_total",
+			Help: "Total number of canary requests whose decision would have differed under the stable ruleset, differentiated by the canary decision.",
+		},
+		[]string{"changed"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(rulesetRequestsTotal)
+	prometheus.MustRegister(canaryDivergenceTotal)
+}
+
+// canaryKey identifies the object of req for canaryBucket. It is the
+// metadata.uid of the object rather than the UID of the request, which is new
+// on every request and would move an object between the rulesets from one
+// update to the next. An object being created has no UID yet, so its
+// namespace and name stand in for it.
+func canaryKey(req *admissionv1.AdmissionRequest) string {
+	for _, raw := range [][]byte{req.OldObject.Raw, req.Object.Raw} {
+		var obj struct {
+			Metadata struct {
+				UID string `json:"uid"`
+			} `json:"metadata"`
+		}
+		if len(raw) > 0 && json.Unmarshal(raw, &obj) == nil && obj.Metadata.UID != "" {
+			return obj.Metadata.UID
+		}
+	}
+	return req.Namespace + "/" + req.Name
+}
+
+// canaryBucket maps an object key onto 0-99.
+func canaryBucket(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % 100)
+}
+
+// selectRuleset returns the ruleset for the object of kind identified by key,
+// and the variant it belongs to.
+func selectRuleset(kind, key string) (ruleset, string) {
+	ruleLayersMu.RLock()
+	defer ruleLayersMu.RUnlock()
+
+	if canaryRules != nil && canaryBucket(key) < canaryPercent {
+		return canaryRules[kind].ruleset, rulesetCanary
+	}
+	return mergedRules[kind].ruleset, rulesetStable
+}
+
+// compareWithRollout compares the objects with the ruleset selected for the
+// object key. Canary comparisons are repeated with the stable ruleset to
+// detect whether the rollout changed the decision.
+func compareWithRollout(ctx context.Context, kind, key string, oldRaw, newRaw []byte) (comparison, error) {
+	rules, variant := selectRuleset(kind, key)
+	cmp, err := compareObjects(ctx, rules, oldRaw, newRaw)
+	if err != nil {
+		return cmp, err
+	}
+	cmp.variant = variant
+	if variant != rulesetCanary {
+		return cmp, nil
+	}
+
+	stable, err := compareObjects(ctx, activeRuleset(kind), oldRaw, newRaw)
+	if err != nil {
+		return cmp, nil
+	}
+	cmp.diverged = stable.changed() != cmp.changed()
+	if cmp.diverged {
+		loggerFromContext(ctx).Infof("Canary ruleset diverged from stable: changed=%t, stable would have changed=%t", cmp.changed(), stable.changed())
+	}
+	return cmp, nil
+}
+
+// recordRollout counts the ruleset variant of a comparison and whether it
+// diverged from the stable ruleset.
+func recordRollout(cmp comparison) {
+	if cmp.variant != rulesetCanary {
+		rulesetRequestsTotal.WithLabelValues(rulesetStable).Inc()
+		return
+	}
+
+	rulesetRequestsTotal.WithLabelValues(rulesetCanary).Inc()
+	if cmp.diverged {
+		if cmp.changed() {
+			canaryDivergenceTotal.WithLabelValues("true").Inc()
+		} else {
+			canaryDivergenceTotal.WithLabelValues("false").Inc()
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestCanaryBucket(t *testing.T) {
+	key := "canary-bucket-uid"
+	if canaryBucket(key) != canaryBucket(key) {
+		t.Errorf("Expected the same bucket for the same key")
+	}
+	for i := 0; i < 1000; i++ {
+		if b := canaryBucket(fmt.Sprintf("uid-%d", i)); b < 0 || b > 99 {
+			t.Fatalf("Expected bucket between 0 and 99, got %d", b)
+		}
+	}
+}
+
+func TestCanaryKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      *admissionv1.AdmissionRequest
+		expected string
+	}{
+		{
+			name: "update",
+			req: &admissionv1.AdmissionRequest{
+				Namespace: "ns", Name: "d",
+				OldObject: runtime.RawExtension{Raw: []byte(`{"metadata": {"uid": "object-uid"}}`)},
+				Object:    runtime.RawExtension{Raw: []byte(`{"metadata": {"uid": "object-uid"}}`)},
+			},
+			expected: "object-uid",
+		},
+		{
+			name: "create",
+			req: &admissionv1.AdmissionRequest{
+				Namespace: "ns", Name: "d",
+				Object: runtime.RawExtension{Raw: []byte(`{"metadata": {"name": "d"}}`)},
+			},
+			expected: "ns/d",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canaryKey(tt.req); got != tt.expected {
+				t.Errorf("Expected key %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSelectRuleset_SameObjectSameVariant(t *testing.T) {
+	defer setRuleLayer(ruleSourceCanary, nil)
+	defer func(p int) { canaryPercent = p }(canaryPercent)
+
+	setRuleLayer(ruleSourceCanary, &ruleLayer{Kinds: map[string]kindRules{
+		"GrafanaDashboard": {IgnorePaths: []string{"spec.resyncPeriod"}},
+	}})
+	canaryPercent = 50
+
+	object := []byte(`{"metadata": {"uid": "stable-object-uid"}}`)
+	var variants []string
+	for i := 0; i < 20; i++ {
+		req := &admissionv1.AdmissionRequest{
+			UID:       types.UID(fmt.Sprintf("request-uid-%d", i)),
+			Namespace: "ns",
+			Name:      "d",
+			OldObject: runtime.RawExtension{Raw: object},
+			Object:    runtime.RawExtension{Raw: object},
+		}
+		_, variant := selectRuleset("GrafanaDashboard", canaryKey(req))
+		variants = append(variants, variant)
+	}
+	for _, variant := range variants {
+		if variant != variants[0] {
+			t.Fatalf("Expected every request for the object to use one variant, got %v", variants)
+		}
+	}
+}
+
+func TestCompareWithRollout(t *testing.T) {
+	defer setRuleLayer(ruleSourceCanary, nil)
+	defer func(p int) { canaryPercent = p }(canaryPercent)
+
+	setRuleLayer(ruleSourceCanary, &ruleLayer{Kinds: map[string]kindRules{
+		"GrafanaDashboard": {IgnorePaths: []string{"spec.resyncPeriod"}},
+	}})
+
+	oldObject := []byte(`{"metadata": {"name": "d"}, "spec": {"resyncPeriod": "5m"}}`)
+	newObject := []byte(`{"metadata": {"name": "d"}, "spec": {"resyncPeriod": "10m"}}`)
+
+	canaryPercent = 0
+	cmp, err := compareWithRollout(context.Background(), "GrafanaDashboard", "rollout-uid-1", oldObject, newObject)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cmp.variant != rulesetStable || !cmp.changed() {
+		t.Errorf("Expected the stable ruleset to see a change, got variant=%s changed=%t", cmp.variant, cmp.changed())
+	}
+
+	canaryPercent = 100
+	cmp, err = compareWithRollout(context.Background(), "GrafanaDashboard", "rollout-uid-1", oldObject, newObject)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cmp.variant != rulesetCanary || cmp.changed() {
+		t.Errorf("Expected the canary ruleset to ignore the change, got variant=%s changed=%t", cmp.variant, cmp.changed())
+	}
+	if !cmp.diverged {
+		t.Errorf("Expected the canary decision to diverge from stable")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Change categories describe what kind of change an update makes, so that
+// consumers of the logs, metrics, audit log and decision records can filter
+// for the changes they care about.
+const (
+	categorySpec       = "spec-change"
+	categoryLabel      = "label-change"
+	categoryAnnotation = "annotation-change"
+	categoryFinalizer  = "finalizer-change"
+	categoryMetadata   = "metadata-change"
+	categoryStatus     = "status-change"
+)
+
+var (
+	// Counter for detected changes, by category
+	changeCategoriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_change_categories_total",
+			Help: "Total number of changed objects, differentiated by change category. An update can count towards several categories.",
+		},
+		[]string{"category"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(changeCategoriesTotal)
+}
+
+// categories classifies the changes found by the comparison. Metadata changes
+// are split into labels, annotations and finalizers, with metadata-change
+// covering anything else; partial comparisons only report sections.
+func (c comparison) categories() []string {
+	categories := []string{}
+	if c.specChanged {
+		categories = append(categories, categorySpec)
+	}
+	if c.metadataChanged {
+		if c.partial {
+			categories = append(categories, categoryMetadata)
+		} else {
+			categories = append(categories, metadataCategories(c.oldObj, c.newObj)...)
+		}
+	}
+	if c.statusChanged {
+		categories = append(categories, categoryStatus)
+	}
+	return categories
+}
+
+func metadataCategories(oldObj, newObj map[string]interface{}) []string {
+	oldMeta, _ := oldObj["metadata"].(map[string]interface{})
+	newMeta, _ := newObj["metadata"].(map[string]interface{})
+
+	var categories []string
+	for key, category := range map[string]string{
+		"labels":      categoryLabel,
+		"annotations": categoryAnnotation,
+		"finalizers":  categoryFinalizer,
+	} {
+		if !reflect.DeepEqual(oldMeta[key], newMeta[key]) {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+
+	if len(categories) == 0 || !reflect.DeepEqual(withoutKeys(oldMeta), withoutKeys(newMeta)) {
+		categories = append(categories, categoryMetadata)
+	}
+	return categories
+}
+
+// withoutKeys returns a copy of meta without the keys that have their own
+// category.
+func withoutKeys(meta map[string]interface{}) map[string]interface{} {
+	rest := make(map[string]interface{}, len(meta))
+	for key, value := range meta {
+		switch key {
+		case "labels", "annotations", "finalizers":
+		default:
+			rest[key] = value
+		}
+	}
+	return rest
+}
+
+// recordChangeCategories counts the categories of one changed object.
+func recordChangeCategories(categories []string) {
+	for _, category := range categories {
+		changeCategoriesTotal.WithLabelValues(category).Inc()
+	}
+}
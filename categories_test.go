@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestComparisonCategories(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		expected []string
+	}{
+		{
+			name:     "spec",
+			old:      `{"spec": {"json": "a"}}`,
+			new:      `{"spec": {"json": "b"}}`,
+			expected: []string{categorySpec},
+		},
+		{
+			name:     "labels and finalizers",
+			old:      `{"metadata": {"labels": {"a": "1"}}}`,
+			new:      `{"metadata": {"labels": {"a": "2"}, "finalizers": ["x"]}}`,
+			expected: []string{categoryFinalizer, categoryLabel},
+		},
+		{
+			name:     "annotations and other metadata",
+			old:      `{"metadata": {"annotations": {"a": "1"}, "ownerReferences": []}}`,
+			new:      `{"metadata": {"annotations": {"a": "2"}}}`,
+			expected: []string{categoryAnnotation, categoryMetadata},
+		},
+		{
+			name:     "status",
+			old:      `{"status": {"hash": "a"}}`,
+			new:      `{"status": {"hash": "b"}}`,
+			expected: []string{categoryStatus},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cmp comparison
+			json.Unmarshal([]byte(tt.old), &cmp.oldObj)
+			json.Unmarshal([]byte(tt.new), &cmp.newObj)
+			cmp.metadataChanged = !reflect.DeepEqual(cmp.oldObj["metadata"], cmp.newObj["metadata"])
+			cmp.specChanged = !reflect.DeepEqual(cmp.oldObj["spec"], cmp.newObj["spec"])
+			cmp.statusChanged = !reflect.DeepEqual(cmp.oldObj["status"], cmp.newObj["status"])
+
+			if categories := cmp.categories(); !reflect.DeepEqual(categories, tt.expected) {
+				t.Errorf("Expected categories %v, got %v", tt.expected, categories)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Chaos mode injects faults into admission requests so that the failurePolicy
+// and timeoutSeconds of the webhook configuration can be exercised before a
+// real outage. It must never be enabled on a production cluster.
+var (
+	chaosMode           = false
+	chaosLatencyPercent = 0
+	chaosLatency        = 5 * time.Second
+	chaosErrorPercent   = 0
+	chaosErrorStatus    = http.StatusInternalServerError
+)
+
+var (
+	// Counter for faults injected by chaos mode, by fault type
+	chaosInjectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_chaos_injected_total",
+			Help: "Total number of faults injected by chaos mode, differentiated by fault.",
+		},
+		[]string{"fault"}, // fault is "latency" or "error"
+	)
+)
+
+func init() {
+	prometheus.MustRegister(chaosInjectedTotal)
+}
+
+// validateChaosSettings checks the chaos flags.
+func validateChaosSettings() error {
+	if chaosLatencyPercent < 0 || chaosLatencyPercent > 100 || chaosErrorPercent < 0 || chaosErrorPercent > 100 {
+		return fmt.Errorf("chaos percentages must be between 0 and 100")
+	}
+	if chaosLatency < 0 {
+		return fmt.Errorf("invalid chaos latency %s", chaosLatency)
+	}
+	if chaosErrorStatus < 400 || chaosErrorStatus > 599 {
+		return fmt.Errorf("invalid chaos error status %d, must be a 4xx or 5xx code", chaosErrorStatus)
+	}
+	return nil
+}
+
+// chaosHandler delays a share of requests by latency and fails another share
+// with errorStatus before passing them to next.
+type chaosHandler struct {
+	next           http.Handler
+	latencyPercent int
+	latency        time.Duration
+	errorPercent   int
+	errorStatus    int
+	roll           func() int // returns 0-99
+}
+
+func newChaosHandler(next http.Handler) *chaosHandler {
+	log.Warnf("Chaos mode enabled: %d%% of requests delayed by %s, %d%% failed with status %d",
+		chaosLatencyPercent, chaosLatency, chaosErrorPercent, chaosErrorStatus)
+	return &chaosHandler{
+		next:           next,
+		latencyPercent: chaosLatencyPercent,
+		latency:        chaosLatency,
+		errorPercent:   chaosErrorPercent,
+		errorStatus:    chaosErrorStatus,
+		roll:           func() int { return rand.IntN(100) },
+	}
+}
+
+func (c *chaosHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if c.roll() < c.latencyPercent {
+		chaosInjectedTotal.WithLabelValues("latency").Inc()
+		select {
+		case <-time.After(c.latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if c.roll() < c.errorPercent {
+		chaosInjectedTotal.WithLabelValues("error").Inc()
+		http.Error(w, "chaos mode injected error", c.errorStatus)
+		return
+	}
+
+	c.next.ServeHTTP(w, r)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChaosHandler(t *testing.T) {
+	reached := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	})
+
+	tests := []struct {
+		name           string
+		roll           int
+		expectedStatus int
+		expectReached  bool
+		expectDelay    bool
+	}{
+		{"no fault", 99, http.StatusOK, true, false},
+		{"latency and error", 0, http.StatusServiceUnavailable, false, true},
+		{"latency only", 20, http.StatusOK, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached = false
+			chaos := &chaosHandler{
+				next:           next,
+				latencyPercent: 50,
+				latency:        20 * time.Millisecond,
+				errorPercent:   10,
+				errorStatus:    http.StatusServiceUnavailable,
+				roll:           func() int { return tt.roll },
+			}
+
+			rr := httptest.NewRecorder()
+			start := time.Now()
+			chaos.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/validate", nil))
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if reached != tt.expectReached {
+				t.Errorf("Expected next handler reached=%t, got %t", tt.expectReached, reached)
+			}
+			if delayed := time.Since(start) >= 20*time.Millisecond; delayed != tt.expectDelay {
+				t.Errorf("Expected delay=%t, got %t", tt.expectDelay, delayed)
+			}
+		})
+	}
+}
+
+func TestValidateChaosSettings(t *testing.T) {
+	defer func(p, s int) { chaosErrorPercent, chaosErrorStatus = p, s }(chaosErrorPercent, chaosErrorStatus)
+
+	chaosErrorPercent, chaosErrorStatus = 10, http.StatusInternalServerError
+	if err := validateChaosSettings(); err != nil {
+		t.Errorf("Expected valid settings, got %v", err)
+	}
+
+	chaosErrorStatus = http.StatusOK
+	if err := validateChaosSettings(); err == nil {
+		t.Errorf("Expected an error for a non-error status, got nil")
+	}
+
+	chaosErrorPercent, chaosErrorStatus = 101, http.StatusInternalServerError
+	if err := validateChaosSettings(); err == nil {
+		t.Errorf("Expected an error for a percentage above 100, got nil")
+	}
+}
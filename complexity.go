@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/errdefs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Objects are diffed recursively, so a deeply nested or very wide object can
+// cost far more than its byte size suggests. Objects beyond these limits are
+// not compared at all; zero disables a limit.
+var (
+	maxObjectDepth = 64
+	maxObjectKeys  = 100000
+)
+
+var (
+	// Counter for objects rejected by the complexity guard, by limit
+	complexityGuardTrippedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_complexity_guard_tripped_total",
+			Help: "Total number of objects not compared because they exceeded the nesting depth or key count limit.",
+		},
+		[]string{"limit"}, // limit is "depth" or "keys"
+	)
+)
+
+func init() {
+	prometheus.MustRegister(complexityGuardTrippedTotal)
+}
+
+// complexityError is the error of an object beyond one of the limits. It
+// wraps errdefs.ErrOversizedObject.
+type complexityError struct {
+	limit  string // "depth" or "keys"
+	detail string
+}
+
+func (e *complexityError) Error() string {
+	return fmt.Sprintf("%v: %s", errdefs.ErrOversizedObject, e.detail)
+}
+
+func (e *complexityError) Unwrap() error {
+	return errdefs.ErrOversizedObject
+}
+
+// recordComplexityGuard counts err in complexityGuardTrippedTotal if it was
+// returned by checkComplexity. Only admission requests are counted, so dry
+// runs through /api/v1/evaluate and /validate-batch leave it untouched.
+func recordComplexityGuard(err error) {
+	var complexityErr *complexityError
+	if errors.As(err, &complexityErr) {
+		complexityGuardTrippedTotal.WithLabelValues(complexityErr.limit).Inc()
+	}
+}
+
+// checkComplexity scans raw JSON and returns a complexityError if it nests
+// deeper than maxObjectDepth or holds more than maxObjectKeys object keys. It
+// does not validate the JSON, which the decoder does afterwards. Admission
+// requests fail open on it.
+func checkComplexity(raw []byte) error {
+	depth, keys := 0, 0
+	inString, escaped := false, false
+
+	for _, c := range raw {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if maxObjectDepth > 0 && depth > maxObjectDepth {
+				return &complexityError{limit: "depth", detail: fmt.Sprintf("nesting deeper than %d", maxObjectDepth)}
+			}
+		case '}', ']':
+			depth--
+		case ':':
+			keys++
+			if maxObjectKeys > 0 && keys > maxObjectKeys {
+				return &complexityError{limit: "keys", detail: fmt.Sprintf("more than %d keys", maxObjectKeys)}
+			}
+		}
+	}
+	return nil
+}
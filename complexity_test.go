@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/errdefs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestCheckComplexity(t *testing.T) {
+	defer func(d, k int) { maxObjectDepth, maxObjectKeys = d, k }(maxObjectDepth, maxObjectKeys)
+	maxObjectDepth, maxObjectKeys = 3, 4
+
+	tests := []struct {
+		name      string
+		raw       string
+		expectErr bool
+	}{
+		{"within limits", `{"a": {"b": [1, 2]}, "c": 1}`, false},
+		{"too deep", `{"a": {"b": [[1]]}}`, true},
+		{"too many keys", `{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5}`, true},
+		{"brackets in strings", `{"a": "{{{{[[[[", "b": "\"{:"}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkComplexity([]byte(tt.raw))
+			if tt.expectErr != errors.Is(err, errdefs.ErrOversizedObject) {
+				t.Errorf("Expected error=%t, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestHandleAdmissionReview_TooComplexFailsOpen(t *testing.T) {
+	defer func(d int) { maxObjectDepth = d }(maxObjectDepth)
+	maxObjectDepth = 8
+
+	nested := strings.Repeat(`{"a": `, 10) + "1" + strings.Repeat("}", 10)
+	object := fmt.Sprintf(`{"metadata": {"name": "deep"}, "spec": %s}`, nested)
+
+	review := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       "complexity-uid",
+			Kind:      metav1.GroupVersionKind{Group: "grafana.integreatly.org", Version: "v1beta1", Kind: "GrafanaDashboard"},
+			Resource:  metav1.GroupVersionResource{Group: "grafana.integreatly.org", Version: "v1beta1", Resource: "grafanadashboards"},
+			Operation: admissionv1.Update,
+			OldObject: runtime.RawExtension{Raw: []byte(object)},
+			Object:    runtime.RawExtension{Raw: []byte(object)},
+		},
+	}
+	reqBytes, _ := json.Marshal(review)
+
+	before := testutil.ToFloat64(complexityGuardTrippedTotal.WithLabelValues("depth"))
+	if _, err := compareObjects(context.Background(), activeRuleset(kindFilter.kind), []byte(object), []byte(object)); !errors.Is(err, errdefs.ErrOversizedObject) {
+		t.Fatalf("Expected an oversized object error, got %v", err)
+	}
+	if got := testutil.ToFloat64(complexityGuardTrippedTotal.WithLabelValues("depth")) - before; got != 0 {
+		t.Errorf("Expected compareObjects not to count the guard, got %v", got)
+	}
+
+	w := httptest.NewRecorder()
+	handleAdmissionReview(w, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(reqBytes)))
+	if got := testutil.ToFloat64(complexityGuardTrippedTotal.WithLabelValues("depth")) - before; got != 1 {
+		t.Errorf("Expected the guard to be counted once, got %v", got)
+	}
+
+	var resp admissionv1.AdmissionReview
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !resp.Response.Allowed {
+		t.Errorf("Expected a too complex object to be allowed, got denied")
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/objectstore"
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/profile"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// startupFlags are the flags main keeps in local variables.
+type startupFlags struct {
+	logLevel       string
+	port           string
+	timeoutSeconds int
+	objectSelector string
+}
+
+// startupConfig is what validateConfig loaded while checking the flags.
+type startupConfig struct {
+	logLevel       log.Level
+	rules          *ruleLayer
+	canaryRules    *ruleLayer
+	freezes        *freezeConfig
+	objectSelector *metav1.LabelSelector
+	snapshotBucket *objectstore.Bucket
+	adminToken     string
+}
+
+// configProblem is one invalid setting found at startup.
+type configProblem struct {
+	Flag    string `json:"flag"`
+	Value   string `json:"value"`
+	Problem string `json:"problem"`
+}
+
+// configError reports every invalid setting at once, so a deployment can be
+// fixed in one go rather than one crash loop per mistake.
+type configError struct {
+	problems []configProblem
+}
+
+func (e *configError) Error() string {
+	lines := make([]string, len(e.problems))
+	for i, p := range e.problems {
+		lines[i] = fmt.Sprintf("--%s=%s: %s", p.Flag, p.Value, p.Problem)
+	}
+	return fmt.Sprintf("%d invalid settings:\n  %s", len(e.problems), strings.Join(lines, "\n  "))
+}
+
+// configCheck collects the problems found by validateConfig.
+type configCheck struct {
+	problems []configProblem
+}
+
+func (c *configCheck) fail(flag string, value interface{}, format string, args ...interface{}) {
+	c.problems = append(c.problems, configProblem{
+		Flag:    flag,
+		Value:   fmt.Sprint(value),
+		Problem: fmt.Sprintf(format, args...),
+	})
+}
+
+// validateConfig checks every flag and the combinations between them before
+// anything is started, loading the files they name, and returns a
+// *configError listing all problems found.
+func validateConfig(flags startupFlags) (*startupConfig, error) {
+	var c configCheck
+	config := &startupConfig{logLevel: log.InfoLevel}
+
+	if level, err := log.ParseLevel(flags.logLevel); err != nil {
+		c.fail("log-level", flags.logLevel, "must be one of debug, info, warn, error, fatal or panic")
+	} else {
+		config.logLevel = level
+	}
+
+	if port, err := strconv.Atoi(flags.port); err != nil || port < 1 || port > 65535 {
+		c.fail("port", flags.port, "must be a port number between 1 and 65535")
+	}
+	if flags.timeoutSeconds < 1 || flags.timeoutSeconds > 30 {
+		c.fail("webhook-timeout-seconds", flags.timeoutSeconds, "must be between 1 and 30 seconds")
+	}
+	// Downstream webhooks and the authorizer run one after the other within
+	// the apiserver timeout; past it the apiserver applies failurePolicy
+	// Ignore and their own failure policies are bypassed
+	var callTimeouts time.Duration
+	if len(downstreamURLs) > 0 {
+		callTimeouts += downstreamTimeout
+	}
+	if authorizerAddress != "" {
+		callTimeouts += authorizerTimeout
+	}
+	if callTimeouts >= time.Duration(flags.timeoutSeconds)*time.Second {
+		c.fail("webhook-timeout-seconds", flags.timeoutSeconds, "must exceed the downstream and authorizer timeouts, %s together", callTimeouts)
+	}
+	if idleTimeout <= 0 {
+		c.fail("idle-timeout", idleTimeout, "must be positive")
+	}
+	if maxRequestsPerConn < 0 {
+		c.fail("max-requests-per-connection", maxRequestsPerConn, "must not be negative")
+	}
+	if shutdownDrainTimeout <= 0 {
+		c.fail("shutdown-drain-timeout", shutdownDrainTimeout, "must be positive")
+	}
+	if shutdownFlushTimeout <= 0 {
+		c.fail("shutdown-flush-timeout", shutdownFlushTimeout, "must be positive")
+	}
+	if workerCount < 1 {
+		c.fail("workers", workerCount, "must be at least 1")
+	}
+	if queueSize < 0 {
+		c.fail("queue-size", queueSize, "must not be negative")
+	}
+	if responseCacheTTL < 0 {
+		c.fail("response-cache-ttl", responseCacheTTL, "must not be negative")
+	}
+	if registerWebhook && webhookReconcileInterval <= 0 {
+		c.fail("webhook-reconcile-interval", webhookReconcileInterval, "must be positive")
+	}
+
+	if _, err := tls.LoadX509KeyPair(tlsCertFile, tlsKeyFile); err != nil {
+		c.fail("tls-cert-file", tlsCertFile, "cannot be loaded with --tls-key-file=%s: %v", tlsKeyFile, err)
+	}
+
+	if adminTokenFile != "" {
+		token, err := loadAdminToken(adminTokenFile)
+		if err != nil {
+			c.fail("admin-token-file", adminTokenFile, "%v", err)
+		}
+		config.adminToken = token
+	}
+
+	validateRuleConfig(&c, config, flags)
+
+	if !validDecisionMode(decisionMode) {
+		c.fail("decision-mode", decisionMode, "must be %s or %s", decisionModeDeny, decisionModeAllowWarn)
+	}
+	if specChangeRateLimit < 0 {
+		c.fail("spec-change-rate-limit", specChangeRateLimit, "must not be negative")
+	}
+	if specChangeRateWindow <= 0 {
+		c.fail("spec-change-rate-window", specChangeRateWindow, "must be positive")
+	}
+	if maxObjectDepth < 0 {
+		c.fail("max-object-depth", maxObjectDepth, "must not be negative")
+	}
+	if maxObjectKeys < 0 {
+		c.fail("max-object-keys", maxObjectKeys, "must not be negative")
+	}
+	if largeObjectThresholdBytes < 0 {
+		c.fail("large-object-threshold-bytes", largeObjectThresholdBytes, "must not be negative")
+	}
+	if changeIndexWindow <= 0 {
+		c.fail("change-index-window", changeIndexWindow, "must be positive")
+	}
+	if denyLoopThreshold < 0 {
+		c.fail("deny-loop-threshold", denyLoopThreshold, "must not be negative")
+	}
+	if denyLoopWindow <= 0 {
+		c.fail("deny-loop-window", denyLoopWindow, "must be positive")
+	}
+	if denyLoopCooldown <= 0 {
+		c.fail("deny-loop-cooldown", denyLoopCooldown, "must be positive")
+	}
+	if chaosMode {
+		if err := validateChaosSettings(); err != nil {
+			c.fail("chaos-mode", chaosMode, "%v", err)
+		}
+	}
+	if changeAnnotation != "" {
+		if errs := validation.IsQualifiedName(changeAnnotation); len(errs) > 0 {
+			c.fail("change-annotation", changeAnnotation, "%s", strings.Join(errs, "; "))
+		}
+	}
+	if memorySoftLimitBytes < 0 || memoryHardLimitBytes < 0 {
+		c.fail("memory-hard-limit-bytes", memoryHardLimitBytes, "memory limits must not be negative")
+	} else if memorySoftLimitBytes > 0 && memoryHardLimitBytes > 0 && memorySoftLimitBytes >= memoryHardLimitBytes {
+		c.fail("memory-soft-limit-bytes", memorySoftLimitBytes, "must be below --memory-hard-limit-bytes=%d", memoryHardLimitBytes)
+	}
+	if memoryCheckInterval <= 0 {
+		c.fail("memory-check-interval", memoryCheckInterval, "must be positive")
+	}
+	if memoryDrainTimeout < 0 {
+		c.fail("memory-drain-timeout", memoryDrainTimeout, "must not be negative")
+	}
+
+	if u, err := url.Parse(stateStoreURL); err != nil || (u.Scheme != "memory" && u.Scheme != "bolt" && u.Scheme != "redis" && u.Scheme != "rediss") {
+		c.fail("state-store", stateStoreURL, "must be a memory://, bolt:// or redis:// URL")
+	}
+	if _, err := newFilteringGatherer(prometheus.DefaultGatherer, disabledMetrics, droppedMetricLabels); err != nil {
+		c.fail("drop-metric-label", droppedMetricLabels.String(), "%v", err)
+	}
+
+	validateSinkConfig(&c, config)
+
+	if len(c.problems) > 0 {
+		return nil, &configError{problems: c.problems}
+	}
+	return config, nil
+}
+
+// validateRuleConfig checks the ignore paths, the rule files and the object
+// selector.
+func validateRuleConfig(c *configCheck, config *startupConfig, flags startupFlags) {
+	if err := defaultRuleLayer().validate(); err != nil {
+		c.fail("ignore-paths", strings.Join(ignoredPaths, ","), "%v", err)
+	}
+
+	for _, file := range []struct {
+		flag, path string
+		layer      **ruleLayer
+	}{
+		{"rules-file", rulesFile, &config.rules},
+		{"canary-rules-file", canaryRulesFile, &config.canaryRules},
+	} {
+		if file.path == "" {
+			continue
+		}
+		layer, err := loadRulesFile(file.path)
+		if err != nil {
+			c.fail(file.flag, file.path, "%v", err)
+			continue
+		}
+		for kind := range layer.Kinds {
+			if _, ok := profile.Lookup(kind); !ok {
+				c.fail(file.flag, file.path, "kind %s has no profile, compiled in: %s", kind, strings.Join(profile.Kinds(), ", "))
+			}
+		}
+		*file.layer = layer
+	}
+	if canaryPercent < 0 || canaryPercent > 100 {
+		c.fail("canary-percent", canaryPercent, "must be between 0 and 100")
+	}
+
+	if rulesURL != "" {
+		if !isHTTPURL(rulesURL) {
+			c.fail("rules-url", rulesURL, "must be an http or https URL")
+		} else if !rulesAllowHTTP && !strings.HasPrefix(rulesURL, "https://") {
+			c.fail("rules-url", rulesURL, "must be an https URL unless --rules-allow-http is set")
+		}
+		if _, err := clientTLSConfig(rulesCAFile); err != nil {
+			c.fail("rules-ca-file", rulesCAFile, "%v", err)
+		}
+		if rulesPollInterval <= 0 {
+			c.fail("rules-poll-interval", rulesPollInterval, "must be positive")
+		}
+	}
+
+	if freezeWindowsFile != "" {
+		freezes, err := loadFreezeConfig(freezeWindowsFile)
+		if err != nil {
+			c.fail("freeze-windows-file", freezeWindowsFile, "%v", err)
+		}
+		config.freezes = freezes
+	}
+
+	selector, err := metav1.ParseToLabelSelector(flags.objectSelector)
+	if err != nil {
+		c.fail("object-selector", flags.objectSelector, "%v", err)
+	}
+	config.objectSelector = selector
+}
+
+// validateSinkConfig checks where requests and decisions are sent: downstream
+// webhooks, the mirror, the external authorizer, the OTLP endpoint and the
+// snapshot bucket.
+func validateSinkConfig(c *configCheck, config *startupConfig) {
+	for _, rawURL := range downstreamURLs {
+		if !isHTTPURL(rawURL) {
+			c.fail("downstream-url", rawURL, "must be an http or https URL")
+		}
+	}
+	if downstreamFailurePolicy != "Ignore" && downstreamFailurePolicy != "Fail" {
+		c.fail("downstream-failure-policy", downstreamFailurePolicy, "must be Ignore or Fail")
+	}
+	if downstreamTimeout <= 0 {
+		c.fail("downstream-timeout", downstreamTimeout, "must be positive")
+	}
+	if _, err := clientTLSConfig(downstreamCAFile); err != nil {
+		c.fail("downstream-ca-file", downstreamCAFile, "%v", err)
+	}
+
+	if mirrorURL != "" {
+		if !isHTTPURL(mirrorURL) {
+			c.fail("mirror-url", mirrorURL, "must be an http or https URL")
+		}
+		if _, err := clientTLSConfig(mirrorCAFile); err != nil {
+			c.fail("mirror-ca-file", mirrorCAFile, "%v", err)
+		}
+		if mirrorSamplePercent < 0 || mirrorSamplePercent > 100 {
+			c.fail("mirror-sample-percent", mirrorSamplePercent, "must be between 0 and 100")
+		}
+		if mirrorTimeout <= 0 {
+			c.fail("mirror-timeout", mirrorTimeout, "must be positive")
+		}
+	}
+
+	if authorizerAddress != "" {
+		if authorizerFailurePolicy != "Ignore" && authorizerFailurePolicy != "Fail" {
+			c.fail("authorizer-failure-policy", authorizerFailurePolicy, "must be Ignore or Fail")
+		}
+		if authorizerTimeout <= 0 {
+			c.fail("authorizer-timeout", authorizerTimeout, "must be positive")
+		}
+		if authorizerCacheTTL < 0 {
+			c.fail("authorizer-cache-ttl", authorizerCacheTTL, "must not be negative")
+		}
+		if !authorizerPlaintext {
+			if _, err := clientTLSConfig(authorizerCAFile); err != nil {
+				c.fail("authorizer-ca-file", authorizerCAFile, "%v", err)
+			}
+		}
+	}
+
+	if otlpLogsEndpoint != "" {
+		if !isHTTPURL(otlpLogsEndpoint) {
+			c.fail("otlp-logs-endpoint", otlpLogsEndpoint, "must be an http or https URL")
+		}
+		if otlpBatchSize < 1 {
+			c.fail("otlp-batch-size", otlpBatchSize, "must be at least 1")
+		}
+		if otlpFlushInterval <= 0 {
+			c.fail("otlp-flush-interval", otlpFlushInterval, "must be positive")
+		}
+	}
+
+	if snapshotBucketURL != "" {
+		bucket, err := objectstore.Open(snapshotBucketURL)
+		if err != nil {
+			c.fail("snapshot-bucket", snapshotBucketURL, "%v", err)
+		}
+		config.snapshotBucket = bucket
+		if snapshotCluster == "" {
+			c.fail("snapshot-cluster", snapshotCluster, "must not be empty")
+		}
+		if snapshotInterval <= 0 {
+			c.fail("snapshot-interval", snapshotInterval, "must be positive")
+		}
+		if snapshotMaxRecords < 1 {
+			c.fail("snapshot-max-records", snapshotMaxRecords, "must be at least 1")
+		}
+	}
+}
+
+// isHTTPURL reports whether rawURL is an absolute http or https URL.
+func isHTTPURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
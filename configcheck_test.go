@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func validTestStartupFlags(t *testing.T) startupFlags {
+	t.Helper()
+
+	certFile, keyFile := writeTestKeyPair(t, t.TempDir(), time.Now().Add(time.Hour))
+	oldCert, oldKey := tlsCertFile, tlsKeyFile
+	tlsCertFile, tlsKeyFile = certFile, keyFile
+	t.Cleanup(func() { tlsCertFile, tlsKeyFile = oldCert, oldKey })
+
+	return startupFlags{logLevel: "debug", port: "8443", timeoutSeconds: 3, objectSelector: "team=a"}
+}
+
+func TestValidateConfig(t *testing.T) {
+	flags := validTestStartupFlags(t)
+
+	rules := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(rules, []byte(`{"kinds": {"GrafanaDashboard": {"ignorePaths": ["status.hash"]}}}`), 0o600); err != nil {
+		t.Fatalf("Failed to write rules: %v", err)
+	}
+	defer func(f string) { rulesFile = f }(rulesFile)
+	rulesFile = rules
+
+	config, err := validateConfig(flags)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.logLevel != log.DebugLevel {
+		t.Errorf("Expected the debug level, got %s", config.logLevel)
+	}
+	if config.rules == nil || len(config.rules.Kinds["GrafanaDashboard"].IgnorePaths) != 1 {
+		t.Errorf("Expected the rules file to be loaded, got %+v", config.rules)
+	}
+	if config.objectSelector == nil || config.objectSelector.MatchLabels["team"] != "a" {
+		t.Errorf("Expected the object selector to be parsed, got %+v", config.objectSelector)
+	}
+}
+
+func TestValidateConfig_ReportsEveryProblem(t *testing.T) {
+	flags := validTestStartupFlags(t)
+	flags.port = "99999"
+	flags.timeoutSeconds = 60
+
+	rules := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(rules, []byte(`{"kinds": {"Application": {}}}`), 0o600); err != nil {
+		t.Fatalf("Failed to write rules: %v", err)
+	}
+	defer func(f string, p int, m string, u string) {
+		rulesFile, canaryPercent, decisionMode, otlpLogsEndpoint = f, p, m, u
+	}(rulesFile, canaryPercent, decisionMode, otlpLogsEndpoint)
+	rulesFile, canaryPercent, decisionMode, otlpLogsEndpoint = rules, 120, "reject", "otel-collector:4318"
+	defer func(f string) { tlsKeyFile = f }(tlsKeyFile)
+	tlsKeyFile = filepath.Join(t.TempDir(), "missing.key")
+
+	_, err := validateConfig(flags)
+	var configErr *configError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("Expected a configError, got %v", err)
+	}
+
+	flagged := map[string]bool{}
+	for _, p := range configErr.problems {
+		flagged[p.Flag] = true
+	}
+	for _, flag := range []string{"port", "webhook-timeout-seconds", "tls-cert-file", "rules-file", "canary-percent", "decision-mode", "otlp-logs-endpoint"} {
+		if !flagged[flag] {
+			t.Errorf("Expected --%s to be reported, got %v", flag, configErr.problems)
+		}
+	}
+	if len(configErr.problems) != 7 {
+		t.Errorf("Expected 7 problems, got %d: %v", len(configErr.problems), configErr.problems)
+	}
+	if !strings.HasPrefix(err.Error(), "7 invalid settings:\n  --") || !strings.Contains(err.Error(), "--canary-percent=120: must be between 0 and 100") {
+		t.Errorf("Expected a readable report, got %s", err)
+	}
+}
+
+func TestValidateConfig_RulesURL(t *testing.T) {
+	flags := validTestStartupFlags(t)
+	defer func(u string, allow bool) { rulesURL, rulesAllowHTTP = u, allow }(rulesURL, rulesAllowHTTP)
+
+	tests := []struct {
+		url       string
+		allowHTTP bool
+		valid     bool
+	}{
+		{"https://rules.example.com/rules.json", false, true},
+		{"http://rules.example.com/rules.json", false, false},
+		{"http://rules.example.com/rules.json", true, true},
+		{"ftp://rules.example.com/rules.json", true, false},
+	}
+
+	for _, tt := range tests {
+		rulesURL, rulesAllowHTTP = tt.url, tt.allowHTTP
+		if _, err := validateConfig(flags); (err == nil) != tt.valid {
+			t.Errorf("Expected %s with --rules-allow-http=%t to be valid=%t, got %v", tt.url, tt.allowHTTP, tt.valid, err)
+		}
+	}
+}
+
+func TestValidateConfig_CallTimeoutsWithinWebhookTimeout(t *testing.T) {
+	flags := validTestStartupFlags(t)
+	defer func(urls stringSliceFlag, d time.Duration, addr string, a time.Duration, plaintext bool) {
+		downstreamURLs, downstreamTimeout, authorizerAddress, authorizerTimeout, authorizerPlaintext = urls, d, addr, a, plaintext
+	}(downstreamURLs, downstreamTimeout, authorizerAddress, authorizerTimeout, authorizerPlaintext)
+	downstreamURLs = stringSliceFlag{"https://policy.example.com/validate"}
+	authorizerAddress, authorizerPlaintext = "authorizer:9000", true
+
+	tests := []struct {
+		downstream, authorizer time.Duration
+		valid                  bool
+	}{
+		{time.Second, time.Second, true},
+		{2 * time.Second, time.Second, false},
+		{3 * time.Second, 0, false},
+	}
+
+	for _, tt := range tests {
+		downstreamTimeout, authorizerTimeout = tt.downstream, tt.authorizer
+		_, err := validateConfig(flags)
+		var configErr *configError
+		flagged := errors.As(err, &configErr) && strings.Contains(err.Error(), "--webhook-timeout-seconds")
+		if flagged == tt.valid {
+			t.Errorf("Expected downstream %s and authorizer %s within 3s to be valid=%t, got %v", tt.downstream, tt.authorizer, tt.valid, err)
+		}
+	}
+}
+
+func TestValidateConfig_Ranges(t *testing.T) {
+	flags := validTestStartupFlags(t)
+	defer func(register bool, reconcile, downstream, window time.Duration, threshold int, ttl time.Duration) {
+		registerWebhook, webhookReconcileInterval, downstreamTimeout, changeIndexWindow, largeObjectThresholdBytes, responseCacheTTL = register, reconcile, downstream, window, threshold, ttl
+	}(registerWebhook, webhookReconcileInterval, downstreamTimeout, changeIndexWindow, largeObjectThresholdBytes, responseCacheTTL)
+
+	tests := []struct {
+		flag  string
+		set   func()
+		valid bool
+	}{
+		{"webhook-reconcile-interval", func() { registerWebhook, webhookReconcileInterval = true, 0 }, false},
+		{"webhook-reconcile-interval", func() { registerWebhook, webhookReconcileInterval = false, 0 }, true},
+		{"webhook-reconcile-interval", func() { registerWebhook, webhookReconcileInterval = true, time.Minute }, true},
+		{"downstream-timeout", func() { downstreamTimeout = 0 }, false},
+		{"downstream-timeout", func() { downstreamTimeout = -time.Second }, false},
+		{"change-index-window", func() { changeIndexWindow = 0 }, false},
+		{"change-index-window", func() { changeIndexWindow = time.Hour }, true},
+		{"large-object-threshold-bytes", func() { largeObjectThresholdBytes = -1 }, false},
+		{"large-object-threshold-bytes", func() { largeObjectThresholdBytes = 0 }, true},
+		{"response-cache-ttl", func() { responseCacheTTL = -time.Second }, false},
+		{"response-cache-ttl", func() { responseCacheTTL = 0 }, true},
+	}
+
+	for _, tt := range tests {
+		registerWebhook, webhookReconcileInterval, downstreamTimeout = false, time.Minute, time.Second
+		changeIndexWindow, largeObjectThresholdBytes, responseCacheTTL = time.Hour, 0, time.Minute
+		tt.set()
+		_, err := validateConfig(flags)
+		flagged := err != nil && strings.Contains(err.Error(), "--"+tt.flag+"=")
+		if flagged == tt.valid {
+			t.Errorf("Expected --%s to be valid=%t, got %v", tt.flag, tt.valid, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const redacted = "REDACTED"
+
+// secretFlagMarkers mark flags whose values must never be dumped.
+var secretFlagMarkers = []string{"password", "secret", "token", "credential"}
+
+// effectiveConfig returns the resolved value of every flag, plus the runtime
+// state that can be changed after startup, with secrets redacted.
+func effectiveConfig() map[string]interface{} {
+	flags := map[string]string{}
+	flag.VisitAll(func(f *flag.Flag) {
+		flags[f.Name] = redactFlagValue(f.Name, f.Value.String())
+	})
+
+	return map[string]interface{}{
+		"flags":           flags,
+		"maintenanceMode": maintenanceMode.Load(),
+		"rules":           mergedRulesSnapshot(),
+	}
+}
+
+func redactFlagValue(name, value string) string {
+	lower := strings.ToLower(name)
+	for _, marker := range secretFlagMarkers {
+		if strings.Contains(lower, marker) {
+			return redacted
+		}
+	}
+
+	// URLs may carry credentials in their user info.
+	parts := strings.Split(value, ",")
+	for i, part := range parts {
+		if u, err := url.Parse(part); err == nil && u.User != nil {
+			if _, hasPassword := u.User.Password(); hasPassword {
+				u.User = url.UserPassword(u.User.Username(), redacted)
+			}
+			parts[i] = u.String()
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// dumpConfigOnSignal logs the effective configuration every time the process
+// receives SIGQUIT, replacing the Go runtime's default goroutine dump.
+func dumpConfigOnSignal() {
+	dump := make(chan os.Signal, 1)
+	signal.Notify(dump, syscall.SIGQUIT)
+	go func() {
+		for range dump {
+			log.WithField("config", effectiveConfig()).Info("Effective configuration")
+		}
+	}()
+}
+
+// handleDebugConfig serves the effective configuration.
+func handleDebugConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	responseBytes, err := json.Marshal(effectiveConfig())
+	if err != nil {
+		log.Errorf("Failed to marshal effective configuration: %v", err)
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, responseBytes)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The apiserver keeps its connections to the webhook open for as long as it
+// can, so replicas added by a scale-up would get no traffic. With
+// maxRequestsPerConn the webhook recycles each connection after that many
+// admission requests (a GOAWAY on HTTP/2), letting the Service spread the new
+// connections over every replica.
+var (
+	tcpKeepAlive       = 15 * time.Second
+	idleTimeout        = 60 * time.Second
+	maxRequestsPerConn = int64(0)
+)
+
+// openConnections counts the client connections accepted and not yet closed.
+var openConnections atomic.Int64
+
+var (
+	// Gauge for the number of open client connections
+	openConnectionsGauge = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "grafana_operator_webhook_open_connections",
+			Help: "Number of client connections accepted and not yet closed.",
+		},
+		func() float64 { return float64(openConnections.Load()) },
+	)
+
+	// Counter for connections recycled after maxRequestsPerConn requests
+	connectionsRecycledTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_connections_recycled_total",
+			Help: "Total number of client connections asked to reconnect after serving the maximum number of requests.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(openConnectionsGauge)
+	prometheus.MustRegister(connectionsRecycledTotal)
+}
+
+// trackConnState is the http.Server ConnState hook counting open
+// connections. Hijacked connections are no longer the server's to track.
+func trackConnState(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		openConnections.Add(1)
+	case http.StateHijacked, http.StateClosed:
+		openConnections.Add(-1)
+	}
+}
+
+// listen opens the webhook listener with the configured TCP keep-alive
+// period; a negative period disables keep-alive probes.
+func listen(addr string) (net.Listener, error) {
+	lc := net.ListenConfig{KeepAlive: tcpKeepAlive}
+	return lc.Listen(context.Background(), "tcp", addr)
+}
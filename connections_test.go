@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/server"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareChain_RecyclesConnections(t *testing.T) {
+	defer func(n int64) { maxRequestsPerConn = n }(maxRequestsPerConn)
+	maxRequestsPerConn = 2
+
+	handler := newMiddlewareChain().ThenFunc(func(w http.ResponseWriter, r *http.Request) {})
+	req := httptest.NewRequest(http.MethodPost, "/validate", nil)
+	req = req.WithContext(server.CountConnRequests(req.Context(), nil))
+
+	before := testutil.ToFloat64(connectionsRecycledTotal)
+	for i := 1; i <= 2; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if closed := w.Header().Get("Connection") == "close"; closed != (i == 2) {
+			t.Errorf("Expected only request 2 to close the connection, request %d did: %t", i, closed)
+		}
+	}
+	if got := testutil.ToFloat64(connectionsRecycledTotal) - before; got != 1 {
+		t.Errorf("Expected 1 recycled connection, got %v", got)
+	}
+}
+
+func TestListen(t *testing.T) {
+	defer func(d time.Duration) { tcpKeepAlive = d }(tcpKeepAlive)
+	tcpKeepAlive = -1
+
+	ln, err := listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	ln.Close()
+}
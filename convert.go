@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/errdefs"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	// Counter for CRD conversion requests, by outcome
+	conversionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_conversions_total",
+			Help: "Total number of CRD conversion requests handled, differentiated by result.",
+		},
+		[]string{"result"}, // "success" or "failure"
+	)
+)
+
+func init() {
+	prometheus.MustRegister(conversionsTotal)
+}
+
+// converter converts obj, whose apiVersion differs from desiredAPIVersion, to
+// desiredAPIVersion. It may modify obj in place and return it.
+type converter func(obj map[string]interface{}, desiredAPIVersion string) (map[string]interface{}, error)
+
+var (
+	convertersMu sync.RWMutex
+	converters   = map[schema.GroupKind]converter{}
+)
+
+// registerConverter makes conv responsible for all versions of gk. A CRD
+// owned by this binary registers its converter from an init function once it
+// serves more than one version.
+func registerConverter(gk schema.GroupKind, conv converter) {
+	convertersMu.Lock()
+	defer convertersMu.Unlock()
+
+	if _, exists := converters[gk]; exists {
+		panic(fmt.Sprintf("converter for %s already registered", gk))
+	}
+	converters[gk] = conv
+}
+
+func lookupConverter(gk schema.GroupKind) (converter, bool) {
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+
+	conv, ok := converters[gk]
+	return conv, ok
+}
+
+// handleConversionReview implements the CRD conversion webhook contract.
+func handleConversionReview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var conversionReviewReq apiextensionsv1.ConversionReview
+	if err := json.Unmarshal(body, &conversionReviewReq); err != nil {
+		http.Error(w, "failed to unmarshal request", http.StatusBadRequest)
+		return
+	}
+
+	if conversionReviewReq.Request == nil {
+		http.Error(w, "conversion review request is empty", http.StatusBadRequest)
+		return
+	}
+
+	conversionReviewResp := apiextensionsv1.ConversionReview{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apiextensions.k8s.io/v1",
+			Kind:       "ConversionReview",
+		},
+		Response: &apiextensionsv1.ConversionResponse{
+			UID:    conversionReviewReq.Request.UID,
+			Result: metav1.Status{Status: metav1.StatusSuccess},
+		},
+	}
+
+	converted, err := convertObjects(conversionReviewReq.Request.Objects, conversionReviewReq.Request.DesiredAPIVersion)
+	if err != nil {
+		log.Errorf("Conversion to %s failed: %v", conversionReviewReq.Request.DesiredAPIVersion, err)
+		conversionReviewResp.Response.Result = metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: err.Error(),
+		}
+		conversionsTotal.WithLabelValues("failure").Inc()
+	} else {
+		conversionReviewResp.Response.ConvertedObjects = converted
+		conversionsTotal.WithLabelValues("success").Inc()
+	}
+
+	responseBytes, err := json.Marshal(conversionReviewResp)
+	if err != nil {
+		log.Errorf("Failed to marshal conversion response: %v", err)
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, responseBytes)
+}
+
+// convertObjects converts every object to desiredAPIVersion. The conversion
+// contract is all-or-nothing, so the first failure aborts the whole request.
+func convertObjects(objects []runtime.RawExtension, desiredAPIVersion string) ([]runtime.RawExtension, error) {
+	converted := make([]runtime.RawExtension, 0, len(objects))
+	for i, raw := range objects {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(raw.Raw, &obj); err != nil {
+			return nil, fmt.Errorf("failed to parse object %d: %w", i, err)
+		}
+
+		apiVersion, _ := obj["apiVersion"].(string)
+		kind, _ := obj["kind"].(string)
+		if apiVersion != desiredAPIVersion {
+			gv, err := schema.ParseGroupVersion(apiVersion)
+			if err != nil {
+				return nil, fmt.Errorf("object %d has invalid apiVersion %q: %w", i, apiVersion, err)
+			}
+			gk := schema.GroupKind{Group: gv.Group, Kind: kind}
+
+			conv, ok := lookupConverter(gk)
+			if !ok {
+				return nil, fmt.Errorf("%w: no converter registered for %s", errdefs.ErrUnsupportedKind, gk)
+			}
+			obj, err = conv(obj, desiredAPIVersion)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert %s from %s to %s: %w", gk, apiVersion, desiredAPIVersion, err)
+			}
+			obj["apiVersion"] = desiredAPIVersion
+		}
+
+		out, err := json.Marshal(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal object %d: %w", i, err)
+		}
+		converted = append(converted, runtime.RawExtension{Raw: out})
+	}
+	return converted, nil
+}
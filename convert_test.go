@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func init() {
+	// Test-only kind whose v2 renames spec.url to spec.endpoint
+	registerConverter(schema.GroupKind{Group: "test.example.com", Kind: "Widget"}, func(obj map[string]interface{}, desiredAPIVersion string) (map[string]interface{}, error) {
+		spec, _ := obj["spec"].(map[string]interface{})
+		if desiredAPIVersion == "test.example.com/v2" {
+			spec["endpoint"] = spec["url"]
+			delete(spec, "url")
+		}
+		return obj, nil
+	})
+}
+
+func convert(t *testing.T, desiredAPIVersion string, objects ...string) apiextensionsv1.ConversionReview {
+	t.Helper()
+
+	review := apiextensionsv1.ConversionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "ConversionReview"},
+		Request: &apiextensionsv1.ConversionRequest{
+			UID:               "test-uid-convert",
+			DesiredAPIVersion: desiredAPIVersion,
+		},
+	}
+	for _, obj := range objects {
+		review.Request.Objects = append(review.Request.Objects, runtime.RawExtension{Raw: []byte(obj)})
+	}
+
+	reqBytes, err := json.Marshal(review)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	handleConversionReview(w, httptest.NewRequest(http.MethodPost, "/convert", bytes.NewReader(reqBytes)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", w.Code)
+	}
+
+	var resp apiextensionsv1.ConversionReview
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Response == nil || resp.Response.UID != review.Request.UID {
+		t.Fatalf("Expected a response echoing UID %s, got %+v", review.Request.UID, resp.Response)
+	}
+	return resp
+}
+
+func TestHandleConversionReview_RegisteredConverter(t *testing.T) {
+	resp := convert(t, "test.example.com/v2",
+		`{"apiVersion": "test.example.com/v1", "kind": "Widget", "spec": {"url": "https://grafana"}}`,
+		`{"apiVersion": "test.example.com/v2", "kind": "Widget", "spec": {"endpoint": "https://other"}}`)
+
+	if resp.Response.Result.Status != metav1.StatusSuccess {
+		t.Fatalf("Expected success, got %+v", resp.Response.Result)
+	}
+	if len(resp.Response.ConvertedObjects) != 2 {
+		t.Fatalf("Expected 2 converted objects, got %d", len(resp.Response.ConvertedObjects))
+	}
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal(resp.Response.ConvertedObjects[0].Raw, &obj); err != nil {
+		t.Fatalf("Failed to parse converted object: %v", err)
+	}
+	if obj["apiVersion"] != "test.example.com/v2" {
+		t.Errorf("Expected apiVersion test.example.com/v2, got %v", obj["apiVersion"])
+	}
+	if spec := obj["spec"].(map[string]interface{}); spec["endpoint"] != "https://grafana" {
+		t.Errorf("Expected spec.endpoint to be converted, got %v", spec)
+	}
+}
+
+func TestHandleConversionReview_UnknownKind(t *testing.T) {
+	resp := convert(t, "test.example.com/v2", `{"apiVersion": "test.example.com/v1", "kind": "Gadget"}`)
+
+	if resp.Response.Result.Status != metav1.StatusFailure {
+		t.Errorf("Expected failure for unregistered kind, got %+v", resp.Response.Result)
+	}
+	if len(resp.Response.ConvertedObjects) != 0 {
+		t.Errorf("Expected no converted objects, got %d", len(resp.Response.ConvertedObjects))
+	}
+}
+
+func TestHandleConversionReview_NilRequest(t *testing.T) {
+	w := httptest.NewRecorder()
+	handleConversionReview(w, httptest.NewRequest(http.MethodPost, "/convert", bytes.NewReader([]byte(`{}`))))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code 400, got %d", w.Code)
+	}
+}
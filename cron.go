@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed five-field cron expression: minute, hour, day of
+// month, month and day of week. Fields accept *, values, ranges (a-b), lists
+// (a,b) and steps (*/n, a-b/n). As in cron, when both day fields are
+// restricted a time matches if either of them does.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64 // bit sets
+	domRestricted, dowRestricted  bool
+}
+
+var cronFieldBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+// parseCron parses a cron expression.
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	var sets [5]uint64
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFieldBounds[i][0], cronFieldBounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+		}
+		sets[i] = set
+	}
+
+	// Sunday may be written as 0 or 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	return &cronSchedule{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domRestricted: fields[2] != "*",
+		dowRestricted: fields[4] != "*",
+	}, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+
+		lo, hi := min, max
+		if rangePart != "*" {
+			loPart, hiPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = strconv.Atoi(loPart); err != nil {
+				return 0, fmt.Errorf("invalid value in %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiPart); err != nil {
+					return 0, fmt.Errorf("invalid value in %q", part)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// matches reports whether the schedule fires in the minute of t.
+func (s *cronSchedule) matches(t time.Time) bool {
+	return s.matchesDay(t) && s.hour&(1<<uint(t.Hour())) != 0 && s.minute&(1<<uint(t.Minute())) != 0
+}
+
+// matchesDay reports whether the schedule fires on the day of t.
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	if s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// lastFire returns the latest time not after t, truncated to the minute, at
+// which the schedule fired within lookback, and whether there was one. Days
+// and hours the schedule skips are stepped over whole, so a long lookback
+// costs about one step per day instead of one per minute.
+func (s *cronSchedule) lastFire(t time.Time, lookback time.Duration) (time.Time, bool) {
+	earliest := t.Add(-lookback)
+	candidate := t.Truncate(time.Minute)
+	for candidate.After(earliest) {
+		y, mo, d := candidate.Date()
+		var next time.Time
+		switch {
+		case !s.matchesDay(candidate):
+			next = time.Date(y, mo, d, 0, 0, 0, 0, candidate.Location()).Add(-time.Minute)
+		case s.hour&(1<<uint(candidate.Hour())) == 0:
+			next = time.Date(y, mo, d, candidate.Hour(), 0, 0, 0, candidate.Location()).Add(-time.Minute)
+		case s.minute&(1<<uint(candidate.Minute())) == 0:
+			next = candidate.Add(-time.Minute)
+		default:
+			return candidate, true
+		}
+
+		// Around DST changes a wall clock time can be ambiguous and resolve
+		// to the later instant; fall back to a single minute then.
+		if !next.Before(candidate) {
+			next = candidate.Add(-time.Minute)
+		}
+		candidate = next
+	}
+	return time.Time{}, false
+}
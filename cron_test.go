@@ -0,0 +1,120 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("Expected %q to be rejected", expr)
+		}
+	}
+}
+
+func TestCronSchedule_Matches(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04", value)
+		if err != nil {
+			t.Fatalf("Invalid time %q: %v", value, err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		expr     string
+		time     string
+		expected bool
+	}{
+		{"* * * * *", "2026-10-16 18:30", true},
+		{"0 18 * * 5", "2026-10-16 18:00", true}, // a Friday
+		{"0 18 * * 5", "2026-10-15 18:00", false},
+		{"0 18 * * 5", "2026-10-16 18:01", false},
+		{"*/15 9-17 * * 1-5", "2026-10-14 09:45", true},
+		{"*/15 9-17 * * 1-5", "2026-10-14 09:50", false},
+		{"0 0 * * 7", "2026-10-18 00:00", true}, // Sunday written as 7
+		{"0 0 1,15 * *", "2026-10-15 00:00", true},
+		{"0 0 24 12 *", "2026-12-24 00:00", true},
+		{"0 0 1 * 1", "2026-10-12 00:00", true}, // either day field matches
+	}
+
+	for _, tt := range tests {
+		schedule, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", tt.expr, err)
+		}
+		if got := schedule.matches(at(tt.time)); got != tt.expected {
+			t.Errorf("Expected %q at %s to match=%t, got %t", tt.expr, tt.time, tt.expected, got)
+		}
+	}
+}
+
+func TestCronSchedule_LastFire(t *testing.T) {
+	schedule, err := parseCron("0 18 * * 5")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	saturday := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	fired, ok := schedule.lastFire(saturday, 24*time.Hour)
+	if !ok || !fired.Equal(time.Date(2026, 10, 16, 18, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the schedule to have fired Friday 18:00, got %s, %t", fired, ok)
+	}
+
+	if _, ok := schedule.lastFire(saturday, time.Hour); ok {
+		t.Errorf("Expected no fire within the last hour")
+	}
+}
+
+func TestCronSchedule_LastFireMatchesMinuteScan(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Time zone data unavailable: %v", err)
+	}
+
+	// scan is the reference: every minute from t back to the lookback
+	scan := func(s *cronSchedule, t time.Time, lookback time.Duration) (time.Time, bool) {
+		for candidate := t.Truncate(time.Minute); t.Sub(candidate) < lookback; candidate = candidate.Add(-time.Minute) {
+			if s.matches(candidate) {
+				return candidate, true
+			}
+		}
+		return time.Time{}, false
+	}
+
+	expressions := []string{"0 18 * * 5", "*/15 9-17 * * 1-5", "30 2 * * *", "0 0 1 * *", "59 23 31 12 *", "0 12 13 * 5"}
+	times := []time.Time{
+		time.Date(2026, 10, 17, 12, 0, 0, 0, location),
+		time.Date(2026, 3, 8, 3, 30, 0, 0, location),  // after spring forward
+		time.Date(2026, 11, 1, 1, 30, 0, 0, location), // ambiguous hour
+		time.Date(2027, 1, 1, 0, 0, 30, 0, location),
+	}
+
+	for _, expr := range expressions {
+		schedule, err := parseCron(expr)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", expr, err)
+		}
+		for _, now := range times {
+			for _, lookback := range []time.Duration{time.Hour, 24 * time.Hour, 40 * 24 * time.Hour} {
+				expected, expectedOK := scan(schedule, now, lookback)
+				got, ok := schedule.lastFire(now, lookback)
+				if ok != expectedOK || !got.Equal(expected) {
+					t.Errorf("%q at %s within %s: expected %s, %t, got %s, %t", expr, now, lookback, expected, expectedOK, got, ok)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/store"
+	"github.com/prometheus/client_golang/prometheus"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// A controller that ignores the success status of a denied no-op may retry it
+// in a tight loop. Once an object is denied denyLoopThreshold times within
+// denyLoopWindow, its updates are allowed with a warning for
+// denyLoopCooldown. A threshold of zero disables the detector.
+var (
+	denyLoopThreshold = 20
+	denyLoopWindow    = time.Minute
+	denyLoopCooldown  = 5 * time.Minute
+)
+
+// denyLoopWarning is returned to clients while their object is backed off.
+const denyLoopWarning = "grafana-operator-webhook detected repeated no-op updates of this object and is allowing them temporarily"
+
+var (
+	// Counter for objects switched to allow-with-warning by the deny-loop detector
+	denyLoopBackoffsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_deny_loop_backoffs_total",
+			Help: "Total number of times an object was repeatedly denied and switched to allow-with-warning.",
+		},
+	)
+
+	// Counter for denials turned into allows while an object is backed off
+	denyLoopAllowedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_deny_loop_allowed_total",
+			Help: "Total number of denials allowed because the object was backed off by the deny-loop detector.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(denyLoopBackoffsTotal)
+	prometheus.MustRegister(denyLoopAllowedTotal)
+}
+
+type denyLoopEntry struct {
+	WindowStart  time.Time `json:"windowStart"`
+	Denials      int       `json:"denials"`
+	BackoffUntil time.Time `json:"backoffUntil"`
+}
+
+// denyLoopDetector counts denials per object.
+type denyLoopDetector struct {
+	store store.Store
+}
+
+var denyLoops = newDenyLoopDetector(state)
+
+func newDenyLoopDetector(s store.Store) *denyLoopDetector {
+	return &denyLoopDetector{store: s}
+}
+
+// observe records a denial of key at now and reports whether the object is
+// backed off, in which case the denial should be turned into an allow.
+func (d *denyLoopDetector) observe(ctx context.Context, key string, now time.Time) (backedOff, started bool) {
+	// An entry is stale once both its window and its backoff have passed
+	ttl := denyLoopWindow + denyLoopCooldown
+
+	err := d.store.Update(ctx, "deny-loops/"+key, ttl, func(old []byte) ([]byte, error) {
+		entry := denyLoopEntry{WindowStart: now}
+		if old != nil {
+			if err := json.Unmarshal(old, &entry); err != nil {
+				return nil, err
+			}
+		}
+
+		backedOff, started = entry.observe(now)
+		return json.Marshal(entry)
+	})
+	if err != nil {
+		stateStoreFailed(ctx, "deny-loop update", err)
+		return false, false
+	}
+	return backedOff, started
+}
+
+func (e *denyLoopEntry) observe(now time.Time) (backedOff, started bool) {
+	if now.Before(e.BackoffUntil) {
+		return true, false
+	}
+	if now.Sub(e.WindowStart) > denyLoopWindow {
+		e.WindowStart, e.Denials = now, 0
+	}
+
+	e.Denials++
+	if e.Denials < denyLoopThreshold {
+		return false, false
+	}
+	e.BackoffUntil = now.Add(denyLoopCooldown)
+	e.WindowStart, e.Denials = e.BackoffUntil, 0
+	return true, true
+}
+
+// applyDenyLoopBackoff allows a denied no-op update with a warning if its
+// object has been denied too often recently.
+func applyDenyLoopBackoff(ctx context.Context, req *admissionv1.AdmissionRequest, resp *admissionv1.AdmissionResponse) {
+	if denyLoopThreshold <= 0 || !isNoopDenial(resp) {
+		return
+	}
+
+	key := fmt.Sprintf("%s/%s/%s", req.Kind.Kind, req.Namespace, req.Name)
+	backedOff, started := denyLoops.observe(ctx, key, time.Now())
+	if started {
+		denyLoopBackoffsTotal.Inc()
+		loggerFromContext(ctx).Warnf("Object denied %d times within %s, allowing its updates for %s", denyLoopThreshold, denyLoopWindow, denyLoopCooldown)
+	}
+	if !backedOff {
+		return
+	}
+
+	denyLoopAllowedTotal.Inc()
+	resp.Allowed = true
+	resp.Result = nil
+	resp.Warnings = append(resp.Warnings, denyLoopWarning)
+}
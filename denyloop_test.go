@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/store"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDenyLoopDetector(t *testing.T) {
+	defer func(th int, w, c time.Duration) {
+		denyLoopThreshold, denyLoopWindow, denyLoopCooldown = th, w, c
+	}(denyLoopThreshold, denyLoopWindow, denyLoopCooldown)
+	denyLoopThreshold, denyLoopWindow, denyLoopCooldown = 3, time.Minute, 5*time.Minute
+
+	d := newDenyLoopDetector(store.NewMemory())
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if backedOff, _ := d.observe(context.Background(), "ns/a", now); backedOff {
+			t.Fatalf("Expected no backoff after %d denials", i+1)
+		}
+	}
+	if backedOff, started := d.observe(context.Background(), "ns/a", now); !backedOff || !started {
+		t.Errorf("Expected backoff to start at the threshold, got backedOff=%t started=%t", backedOff, started)
+	}
+	if backedOff, started := d.observe(context.Background(), "ns/a", now.Add(time.Minute)); !backedOff || started {
+		t.Errorf("Expected the object to stay backed off during the cooldown, got backedOff=%t started=%t", backedOff, started)
+	}
+	if backedOff, _ := d.observe(context.Background(), "ns/b", now); backedOff {
+		t.Errorf("Expected other objects to be unaffected")
+	}
+	if backedOff, _ := d.observe(context.Background(), "ns/a", now.Add(6*time.Minute)); backedOff {
+		t.Errorf("Expected the backoff to end after the cooldown")
+	}
+}
+
+func TestDenyLoopDetector_WindowExpires(t *testing.T) {
+	defer func(th int, w time.Duration) { denyLoopThreshold, denyLoopWindow = th, w }(denyLoopThreshold, denyLoopWindow)
+	denyLoopThreshold, denyLoopWindow = 2, time.Minute
+
+	d := newDenyLoopDetector(store.NewMemory())
+	now := time.Now()
+
+	d.observe(context.Background(), "ns/a", now)
+	if backedOff, _ := d.observe(context.Background(), "ns/a", now.Add(2*time.Minute)); backedOff {
+		t.Errorf("Expected denials in separate windows not to trigger a backoff")
+	}
+}
+
+func TestApplyDenyLoopBackoff(t *testing.T) {
+	defer func(th int, w, c time.Duration, d *denyLoopDetector) {
+		denyLoopThreshold, denyLoopWindow, denyLoopCooldown, denyLoops = th, w, c, d
+	}(denyLoopThreshold, denyLoopWindow, denyLoopCooldown, denyLoops)
+	denyLoopThreshold, denyLoopWindow, denyLoopCooldown = 2, time.Minute, 5*time.Minute
+
+	tests := []struct {
+		name      string
+		result    *metav1.Status
+		backedOff bool
+	}{
+		{"no-op denial", &metav1.Status{Status: metav1.StatusSuccess}, true},
+		{"change freeze", &metav1.Status{Status: metav1.StatusFailure, Code: 403}, false},
+		{"rate limit", &metav1.Status{Status: metav1.StatusFailure, Code: 429}, false},
+		{"no result", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			denyLoops = newDenyLoopDetector(store.NewMemory())
+			req := &admissionv1.AdmissionRequest{Kind: metav1.GroupVersionKind{Kind: "GrafanaDashboard"}, Namespace: "ns", Name: "dash"}
+
+			var resp *admissionv1.AdmissionResponse
+			for i := 0; i < denyLoopThreshold; i++ {
+				resp = &admissionv1.AdmissionResponse{Allowed: false, Result: tt.result}
+				applyDenyLoopBackoff(context.Background(), req, resp)
+			}
+			if resp.Allowed != tt.backedOff {
+				t.Errorf("Expected allowed to be %t at the threshold, got %+v", tt.backedOff, resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// difference is a single changed value between two objects.
+type difference struct {
+	Path     string      `json:"path"`
+	Type     string      `json:"type"` // "added", "removed" or "changed"
+	OldValue interface{} `json:"oldValue,omitempty"`
+	NewValue interface{} `json:"newValue,omitempty"`
+}
+
+// diffObjects returns the differences between oldObj and newObj as dot paths,
+// sorted by path. Nested objects are descended into; any other values,
+// including lists, are compared as a whole.
+func diffObjects(oldObj, newObj map[string]interface{}) []difference {
+	diffs := []difference{}
+	diffMaps("", oldObj, newObj, &diffs)
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return diffs
+}
+
+func diffMaps(prefix string, oldMap, newMap map[string]interface{}, diffs *[]difference) {
+	for key, oldValue := range oldMap {
+		path := prefix + key
+		newValue, exists := newMap[key]
+		if !exists {
+			*diffs = append(*diffs, difference{Path: path, Type: "removed", OldValue: oldValue})
+			continue
+		}
+
+		oldChild, oldIsMap := oldValue.(map[string]interface{})
+		newChild, newIsMap := newValue.(map[string]interface{})
+		if oldIsMap && newIsMap {
+			diffMaps(path+".", oldChild, newChild, diffs)
+			continue
+		}
+		if !reflect.DeepEqual(oldValue, newValue) {
+			*diffs = append(*diffs, difference{Path: path, Type: "changed", OldValue: oldValue, NewValue: newValue})
+		}
+	}
+
+	for key, newValue := range newMap {
+		if _, exists := oldMap[key]; !exists {
+			*diffs = append(*diffs, difference{Path: prefix + key, Type: "added", NewValue: newValue})
+		}
+	}
+}
+
+// jsonPatchOperation is one operation of an RFC 6902 JSON Patch.
+type jsonPatchOperation struct {
+	Op    string      `json:"op"` // "add", "remove" or "replace"
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// MarshalJSON omits the value of remove operations only, as add and replace
+// operations may set null.
+func (o jsonPatchOperation) MarshalJSON() ([]byte, error) {
+	if o.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{o.Op, o.Path})
+	}
+	type operation jsonPatchOperation
+	return json.Marshal(operation(o))
+}
+
+// patchObjects returns the JSON Patch turning oldObj into newObj, sorted by
+// path. It has the granularity of diffObjects, but its JSON Pointer paths
+// stay unambiguous for keys containing dots. The objects are the normalized
+// ones compared, with embedded JSON expanded and ignored paths removed, so
+// the patch describes the change and cannot be applied to the real objects:
+// a path into spec.json points at a string there, and ignored subtrees are
+// missing.
+func patchObjects(oldObj, newObj map[string]interface{}) []jsonPatchOperation {
+	ops := []jsonPatchOperation{}
+	patchMaps("", oldObj, newObj, &ops)
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Path < ops[j].Path })
+	return ops
+}
+
+func patchMaps(prefix string, oldMap, newMap map[string]interface{}, ops *[]jsonPatchOperation) {
+	for key, oldValue := range oldMap {
+		path := prefix + "/" + escapeJSONPointer(key)
+		newValue, exists := newMap[key]
+		if !exists {
+			*ops = append(*ops, jsonPatchOperation{Op: "remove", Path: path})
+			continue
+		}
+
+		oldChild, oldIsMap := oldValue.(map[string]interface{})
+		newChild, newIsMap := newValue.(map[string]interface{})
+		if oldIsMap && newIsMap {
+			patchMaps(path, oldChild, newChild, ops)
+			continue
+		}
+		if !reflect.DeepEqual(oldValue, newValue) {
+			*ops = append(*ops, jsonPatchOperation{Op: "replace", Path: path, Value: newValue})
+		}
+	}
+
+	for key, newValue := range newMap {
+		if _, exists := oldMap[key]; !exists {
+			*ops = append(*ops, jsonPatchOperation{Op: "add", Path: prefix + "/" + escapeJSONPointer(key), Value: newValue})
+		}
+	}
+}
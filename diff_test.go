@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPatchObjects(t *testing.T) {
+	var oldObj, newObj map[string]interface{}
+	if err := json.Unmarshal([]byte(`{
+		"metadata": {"labels": {"app.kubernetes.io/name": "a", "team": "x"}},
+		"spec": {"folder": "a", "panels": [1, 2], "removed": true, "nulled": 1}
+	}`), &oldObj); err != nil {
+		t.Fatalf("Failed to parse old object: %v", err)
+	}
+	if err := json.Unmarshal([]byte(`{
+		"metadata": {"labels": {"app.kubernetes.io/name": "b", "team": "x"}},
+		"spec": {"folder": "a", "panels": [1, 2, 3], "added": {"x": 1}, "nulled": null}
+	}`), &newObj); err != nil {
+		t.Fatalf("Failed to parse new object: %v", err)
+	}
+
+	patch, err := json.Marshal(patchObjects(oldObj, newObj))
+	if err != nil {
+		t.Fatalf("Failed to marshal patch: %v", err)
+	}
+
+	expected := `[` +
+		`{"op":"replace","path":"/metadata/labels/app.kubernetes.io~1name","value":"b"},` +
+		`{"op":"add","path":"/spec/added","value":{"x":1}},` +
+		`{"op":"replace","path":"/spec/nulled","value":null},` +
+		`{"op":"replace","path":"/spec/panels","value":[1,2,3]},` +
+		`{"op":"remove","path":"/spec/removed"}` +
+		`]`
+	if string(patch) != expected {
+		t.Errorf("Expected %s, got %s", expected, patch)
+	}
+}
+
+func TestPatchObjects_Unchanged(t *testing.T) {
+	obj := map[string]interface{}{"spec": map[string]interface{}{"folder": "a"}}
+	if patch := patchObjects(obj, obj); len(patch) != 0 {
+		t.Errorf("Expected an empty patch, got %+v", patch)
+	}
+}
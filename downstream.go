@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Requests allowed locally can be forwarded to further validating webhooks,
+// so several validators can sit behind a single registration. A request is
+// only allowed if every downstream webhook allows it as well.
+var (
+	downstreamURLs          stringSliceFlag
+	downstreamCAFile        = ""
+	downstreamTimeout       = time.Second
+	downstreamFailurePolicy = "Ignore"
+)
+
+var downstreamClient = &http.Client{}
+
+var (
+	// Counter for downstream webhook calls, by URL and outcome
+	downstreamRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_downstream_requests_total",
+			Help: "Total number of AdmissionReviews forwarded to downstream webhooks, differentiated by URL and result.",
+		},
+		[]string{"url", "result"}, // result is "allowed", "denied" or "error"
+	)
+)
+
+func init() {
+	prometheus.MustRegister(downstreamRequestsTotal)
+}
+
+// configureDownstreams prepares the HTTP client used to reach the downstream
+// webhooks, trusting the CA bundle at caFile in addition to the system roots.
+func configureDownstreams(caFile string) error {
+	if downstreamFailurePolicy != "Ignore" && downstreamFailurePolicy != "Fail" {
+		return fmt.Errorf("invalid downstream failure policy %q, must be Ignore or Fail", downstreamFailurePolicy)
+	}
+
+	tlsConfig, err := clientTLSConfig(caFile)
+	if err != nil {
+		return fmt.Errorf("downstream CA bundle: %w", err)
+	}
+
+	downstreamClient = &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		Timeout:   downstreamTimeout,
+	}
+	return nil
+}
+
+// clientTLSConfig returns a TLS client configuration trusting the CA bundle
+// at caFile, if set, in addition to the system roots.
+func clientTLSConfig(caFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile == "" {
+		return tlsConfig, nil
+	}
+
+	caBundle, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", caFile, err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(caBundle) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
+
+// consultDownstreams forwards the original AdmissionReview body to every
+// downstream webhook and denies resp if any of them denies it. Requests that
+// are already denied locally are not forwarded.
+func consultDownstreams(ctx context.Context, body []byte, resp *admissionv1.AdmissionResponse) {
+	if len(downstreamURLs) == 0 || !resp.Allowed {
+		return
+	}
+
+	results := make([]*admissionv1.AdmissionResponse, len(downstreamURLs))
+	errs := make([]error, len(downstreamURLs))
+
+	var wg sync.WaitGroup
+	for i, url := range downstreamURLs {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			results[i], errs[i] = callWebhook(ctx, downstreamClient, url, body)
+		}(i, url)
+	}
+	wg.Wait()
+
+	for i, url := range downstreamURLs {
+		if errs[i] == nil && results[i].UID != resp.UID {
+			errs[i] = fmt.Errorf("response UID %q does not match request UID %q", results[i].UID, resp.UID)
+		}
+		if errs[i] != nil {
+			downstreamRequestsTotal.WithLabelValues(url, "error").Inc()
+			loggerFromContext(ctx).Errorf("Downstream webhook %s failed: %v", url, errs[i])
+			if downstreamFailurePolicy == "Fail" {
+				resp.Allowed = false
+				resp.Result = &metav1.Status{
+					Status:  metav1.StatusFailure,
+					Message: fmt.Sprintf("downstream webhook %s failed", url),
+					Code:    http.StatusInternalServerError,
+				}
+				return
+			}
+			continue
+		}
+
+		resp.Warnings = append(resp.Warnings, results[i].Warnings...)
+		if !results[i].Allowed {
+			downstreamRequestsTotal.WithLabelValues(url, "denied").Inc()
+			resp.Allowed = false
+			resp.Result = downstreamDenial(url, results[i].Result)
+			return
+		}
+		downstreamRequestsTotal.WithLabelValues(url, "allowed").Inc()
+	}
+}
+
+// downstreamDenial is the status of a request denied by the downstream
+// webhook at url with result, which may be nil. It is always a failure, so a
+// downstream denial is never mistaken for the denial of a no-op update.
+func downstreamDenial(url string, result *metav1.Status) *metav1.Status {
+	status := &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Message: fmt.Sprintf("denied by downstream webhook %s", url),
+		Code:    http.StatusForbidden,
+	}
+	if result == nil {
+		return status
+	}
+	if result.Message != "" {
+		status.Message = fmt.Sprintf("downstream webhook %s: %s", url, result.Message)
+	}
+	if result.Code >= http.StatusBadRequest {
+		status.Code = result.Code
+	}
+	status.Reason = result.Reason
+	return status
+}
+
+// callWebhook posts the AdmissionReview body to the validating webhook at url
+// and returns its response.
+func callWebhook(ctx context.Context, client *http.Client, url string, body []byte) (*admissionv1.AdmissionResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", httpResp.StatusCode)
+	}
+
+	var review admissionv1.AdmissionReview
+	if err := json.NewDecoder(io.LimitReader(httpResp.Body, maxRequestBodyBytes)).Decode(&review); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if review.Response == nil {
+		return nil, fmt.Errorf("response is empty")
+	}
+	return review.Response, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newDownstream starts a webhook answering every review with allowed.
+func newDownstream(t *testing.T, allowed bool) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var review admissionv1.AdmissionReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		review.Response = &admissionv1.AdmissionResponse{
+			UID:      review.Request.UID,
+			Allowed:  allowed,
+			Warnings: []string{"checked by " + r.Host},
+		}
+		if !allowed {
+			review.Response.Result = &metav1.Status{Message: "denied downstream"}
+		}
+		_ = json.NewEncoder(w).Encode(review)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestConsultDownstreams(t *testing.T) {
+	defer func(urls stringSliceFlag, policy string) {
+		downstreamURLs, downstreamFailurePolicy = urls, policy
+	}(downstreamURLs, downstreamFailurePolicy)
+
+	allowing := newDownstream(t, true)
+	denying := newDownstream(t, false)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	body, err := json.Marshal(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{UID: "test-uid-downstream"}})
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		urls            []string
+		policy          string
+		localAllowed    bool
+		expectedAllowed bool
+	}{
+		{"all allow", []string{allowing.URL, allowing.URL}, "Ignore", true, true},
+		{"one denies", []string{allowing.URL, denying.URL}, "Ignore", true, false},
+		{"local deny is final", []string{allowing.URL}, "Ignore", false, false},
+		{"error ignored", []string{failing.URL}, "Ignore", true, true},
+		{"error fails", []string{failing.URL}, "Fail", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downstreamURLs = tt.urls
+			downstreamFailurePolicy = tt.policy
+
+			resp := &admissionv1.AdmissionResponse{UID: "test-uid-downstream", Allowed: tt.localAllowed}
+			consultDownstreams(context.Background(), body, resp)
+
+			if resp.Allowed != tt.expectedAllowed {
+				t.Errorf("Expected allowed=%t, got %t", tt.expectedAllowed, resp.Allowed)
+			}
+			if !resp.Allowed && tt.localAllowed && resp.Result == nil {
+				t.Errorf("Expected a result explaining the downstream denial")
+			}
+		})
+	}
+}
+
+func TestConsultDownstreams_Denial(t *testing.T) {
+	defer func(urls stringSliceFlag, policy string) {
+		downstreamURLs, downstreamFailurePolicy = urls, policy
+	}(downstreamURLs, downstreamFailurePolicy)
+	downstreamFailurePolicy = "Fail"
+
+	body, err := json.Marshal(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{UID: "test-uid-downstream"}})
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		response        admissionv1.AdmissionResponse
+		expectedMessage string
+		expectedCode    int32
+	}{
+		{
+			name:            "no result",
+			response:        admissionv1.AdmissionResponse{UID: "test-uid-downstream"},
+			expectedMessage: "denied by downstream webhook",
+			expectedCode:    http.StatusForbidden,
+		},
+		{
+			name:            "message and code",
+			response:        admissionv1.AdmissionResponse{UID: "test-uid-downstream", Result: &metav1.Status{Message: "missing owner", Code: http.StatusUnprocessableEntity}},
+			expectedMessage: "missing owner",
+			expectedCode:    http.StatusUnprocessableEntity,
+		},
+		{
+			name:            "success status",
+			response:        admissionv1.AdmissionResponse{UID: "test-uid-downstream", Result: &metav1.Status{Status: metav1.StatusSuccess, Message: "Update successful.", Code: http.StatusOK}},
+			expectedMessage: "Update successful.",
+			expectedCode:    http.StatusForbidden,
+		},
+		{
+			name:            "mismatched UID",
+			response:        admissionv1.AdmissionResponse{UID: "another-uid", Allowed: true},
+			expectedMessage: "failed",
+			expectedCode:    http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewEncoder(w).Encode(admissionv1.AdmissionReview{Response: &tt.response})
+			}))
+			defer srv.Close()
+			downstreamURLs = stringSliceFlag{srv.URL}
+
+			resp := &admissionv1.AdmissionResponse{UID: "test-uid-downstream", Allowed: true}
+			consultDownstreams(context.Background(), body, resp)
+
+			if resp.Allowed || resp.Result == nil {
+				t.Fatalf("Expected a denial with a result, got %+v", resp)
+			}
+			if resp.Result.Status != metav1.StatusFailure || resp.Result.Code != tt.expectedCode {
+				t.Errorf("Expected a failure with code %d, got %+v", tt.expectedCode, resp.Result)
+			}
+			if !strings.Contains(resp.Result.Message, tt.expectedMessage) || !strings.Contains(resp.Result.Message, srv.URL) {
+				t.Errorf("Expected the message to name %s and contain %q, got %q", srv.URL, tt.expectedMessage, resp.Result.Message)
+			}
+			if isNoopDenial(resp) {
+				t.Errorf("Expected a downstream denial not to look like a no-op denial")
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// embeddedJSONPaths are string fields holding a JSON document, such as the
+// dashboard model in spec.json. They are compared as documents, so edits that
+// only change whitespace or key order are not significant.
+var embeddedJSONPaths = activeProfile.EmbeddedJSONPaths
+
+// rejectInvalidEmbeddedJSON denies updates that change an embedded document
+// into something that is not a JSON object, before the operator fails to
+// sync it to Grafana.
+var rejectInvalidEmbeddedJSON = false
+
+var (
+	// Counter for embedded documents that failed to parse, by path
+	embeddedJSONInvalidTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_embedded_json_invalid_total",
+			Help: "Total number of updates with an embedded JSON document that is not a JSON object, differentiated by path.",
+		},
+		[]string{"path"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(embeddedJSONInvalidTotal)
+}
+
+// parseEmbeddedObject parses an embedded document, which must be a JSON
+// object.
+func parseEmbeddedObject(value interface{}) (map[string]interface{}, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &doc); err != nil || doc == nil {
+		return nil, false
+	}
+	return doc, true
+}
+
+// expandEmbeddedJSON replaces every embedded document in both objects with
+// its parsed form, so it is compared and diffed structurally. Documents that
+// do not parse are left as strings and returned as invalid if they changed.
+func expandEmbeddedJSON(paths []string, oldObj, newObj map[string]interface{}) (invalid []string) {
+	for _, path := range paths {
+		oldParent, leaf := embeddedParent(oldObj, path)
+		newParent, _ := embeddedParent(newObj, path)
+		if newParent == nil {
+			continue
+		}
+
+		newValue, exists := newParent[leaf]
+		if !exists {
+			continue
+		}
+		newDoc, newValid := parseEmbeddedObject(newValue)
+		if !newValid {
+			if oldParent == nil || oldParent[leaf] != newValue {
+				invalid = append(invalid, path)
+			}
+			continue
+		}
+
+		if oldParent != nil {
+			if oldDoc, ok := parseEmbeddedObject(oldParent[leaf]); ok {
+				oldParent[leaf] = oldDoc
+			}
+		}
+		newParent[leaf] = newDoc
+	}
+	return invalid
+}
+
+// embeddedParent returns the map holding the last key of path, and that key.
+func embeddedParent(obj map[string]interface{}, path string) (map[string]interface{}, string) {
+	i := strings.LastIndex(path, ".")
+	if i < 0 {
+		return obj, path
+	}
+	parent, _ := lookupField(obj, path[:i]).(map[string]interface{})
+	return parent, path[i+1:]
+}
+
+// applyEmbeddedJSONValidation denies an update that changed an embedded
+// document into invalid JSON when rejectInvalidEmbeddedJSON is set.
+func applyEmbeddedJSONValidation(ctx context.Context, cmp *comparison, resp *admissionv1.AdmissionResponse) {
+	if cmp == nil || len(cmp.invalidEmbedded) == 0 {
+		return
+	}
+
+	for _, path := range cmp.invalidEmbedded {
+		embeddedJSONInvalidTotal.WithLabelValues(path).Inc()
+	}
+	if !rejectInvalidEmbeddedJSON || !resp.Allowed {
+		loggerFromContext(ctx).Warnf("Embedded documents are not JSON objects: %s", strings.Join(cmp.invalidEmbedded, ", "))
+		return
+	}
+
+	message := fmt.Sprintf("%s must contain a JSON object", strings.Join(cmp.invalidEmbedded, ", "))
+	loggerFromContext(ctx).Infof("Denied update: %s", message)
+
+	resp.Allowed = false
+	resp.Result = &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Reason:  metav1.StatusReasonInvalid,
+		Message: message,
+		Code:    http.StatusUnprocessableEntity,
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+func TestCompareObjects_EmbeddedJSON(t *testing.T) {
+	tests := []struct {
+		name            string
+		oldObject       string
+		object          string
+		expectedChanged bool
+		expectedInvalid []string
+	}{
+		{
+			name:            "reformatted",
+			oldObject:       `{"spec": {"json": "{\"title\": \"A\", \"panels\": []}"}}`,
+			object:          `{"spec": {"json": "{\n  \"panels\": [],\n  \"title\": \"A\"\n}"}}`,
+			expectedChanged: false,
+		},
+		{
+			name:            "changed",
+			oldObject:       `{"spec": {"json": "{\"title\": \"A\"}"}}`,
+			object:          `{"spec": {"json": "{\"title\": \"B\"}"}}`,
+			expectedChanged: true,
+		},
+		{
+			name:            "became invalid",
+			oldObject:       `{"spec": {"json": "{\"title\": \"A\"}"}}`,
+			object:          `{"spec": {"json": "{\"title\": "}}`,
+			expectedChanged: true,
+			expectedInvalid: []string{"spec.json"},
+		},
+		{
+			name:            "unchanged invalid",
+			oldObject:       `{"spec": {"json": "[]"}}`,
+			object:          `{"spec": {"json": "[]"}}`,
+			expectedChanged: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmp, err := compareObjects(context.Background(), activeRuleset(kindFilter.kind), []byte(tt.oldObject), []byte(tt.object))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if cmp.specChanged != tt.expectedChanged {
+				t.Errorf("Expected spec changed %v, got %v", tt.expectedChanged, cmp.specChanged)
+			}
+			if !reflect.DeepEqual(cmp.invalidEmbedded, tt.expectedInvalid) {
+				t.Errorf("Expected invalid embedded paths %v, got %v", tt.expectedInvalid, cmp.invalidEmbedded)
+			}
+		})
+	}
+}
+
+func TestApplyEmbeddedJSONValidation(t *testing.T) {
+	cmp := &comparison{specChanged: true, invalidEmbedded: []string{"spec.json"}}
+
+	resp := &admissionv1.AdmissionResponse{Allowed: true}
+	applyEmbeddedJSONValidation(context.Background(), cmp, resp)
+	if !resp.Allowed {
+		t.Errorf("Expected invalid embedded JSON to be allowed by default")
+	}
+
+	defer func(reject bool) { rejectInvalidEmbeddedJSON = reject }(rejectInvalidEmbeddedJSON)
+	rejectInvalidEmbeddedJSON = true
+
+	resp = &admissionv1.AdmissionResponse{Allowed: true}
+	applyEmbeddedJSONValidation(context.Background(), cmp, resp)
+	if resp.Allowed {
+		t.Fatalf("Expected invalid embedded JSON to be denied")
+	}
+	if resp.Result.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code 422, got %d", resp.Result.Code)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/errdefs"
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+// evaluateRequestBody is the body of POST /api/v1/evaluate, in JSON or, with a
+// YAML Content-Type, in YAML. Without a ruleset the active one is used.
+type evaluateRequestBody struct {
+	OldObject json.RawMessage `json:"oldObject"`
+	Object    json.RawMessage `json:"object"`
+	Ruleset   *ruleset        `json:"ruleset,omitempty"`
+}
+
+// evaluateResponseBody explains the decision for an object pair.
+type evaluateResponseBody struct {
+	Allowed         bool                 `json:"allowed"`
+	Partial         bool                 `json:"partial,omitempty"`
+	ChangedSections []string             `json:"changedSections"`
+	Diff            []difference         `json:"diff"`
+	NormalizedPatch []jsonPatchOperation `json:"normalizedPatch"`
+	FiredRules      []string             `json:"firedRules"`
+}
+
+// handleEvaluate is a dry run of the comparison for debugging rules: it
+// returns the decision, the remaining diff and the ignore paths that matched,
+// without touching any counters.
+func handleEvaluate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if isYAMLRequest(r) {
+		if body, err = yaml.YAMLToJSON(body); err != nil {
+			http.Error(w, "failed to convert YAML request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var req evaluateRequestBody
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "failed to unmarshal request", http.StatusBadRequest)
+		return
+	}
+
+	rules := activeRuleset(kindFilter.kind)
+	if req.Ruleset != nil {
+		rules = *req.Ruleset
+		for _, path := range rules.IgnorePaths {
+			if _, err := parsePath(path); err != nil {
+				http.Error(w, err.Error(), errdefs.HTTPStatus(err))
+				return
+			}
+		}
+	}
+
+	cmp, err := compareObjects(r.Context(), rules, req.OldObject, req.Object)
+	if err != nil {
+		http.Error(w, err.Error(), errdefs.HTTPStatus(err))
+		return
+	}
+
+	resp := evaluateResponseBody{
+		Allowed:         cmp.changed(),
+		Partial:         cmp.partial,
+		ChangedSections: cmp.changedSections(),
+		Diff:            diffObjects(cmp.oldObj, cmp.newObj),
+		NormalizedPatch: patchObjects(cmp.oldObj, cmp.newObj),
+		FiredRules:      cmp.ignoredHits,
+	}
+	if resp.FiredRules == nil {
+		resp.FiredRules = []string{}
+	}
+
+	responseBytes, err := json.Marshal(resp)
+	if err != nil {
+		log.Errorf("Failed to marshal evaluation: %v", err)
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, responseBytes)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func evaluate(t *testing.T, body string) evaluateResponseBody {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	handleEvaluate(w, httptest.NewRequest(http.MethodPost, "/api/v1/evaluate", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp evaluateResponseBody
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return resp
+}
+
+func TestHandleEvaluate_ActiveRuleset(t *testing.T) {
+	resp := evaluate(t, `{
+		"oldObject": {"metadata": {"generation": 1}, "spec": {"json": "a", "folder": "x"}, "status": {"lastResync": "1"}},
+		"object": {"metadata": {"generation": 2}, "spec": {"json": "b"}, "status": {"lastResync": "2"}}
+	}`)
+
+	if !resp.Allowed {
+		t.Errorf("Expected a spec change to be allowed")
+	}
+	expectedDiff := []difference{
+		{Path: "spec.folder", Type: "removed", OldValue: "x"},
+		{Path: "spec.json", Type: "changed", OldValue: "a", NewValue: "b"},
+	}
+	if !reflect.DeepEqual(resp.Diff, expectedDiff) {
+		t.Errorf("Expected diff %+v, got %+v", expectedDiff, resp.Diff)
+	}
+	expectedPatch := []jsonPatchOperation{
+		{Op: "remove", Path: "/spec/folder"},
+		{Op: "replace", Path: "/spec/json", Value: "b"},
+	}
+	if !reflect.DeepEqual(resp.NormalizedPatch, expectedPatch) {
+		t.Errorf("Expected patch %+v, got %+v", expectedPatch, resp.NormalizedPatch)
+	}
+	if expected := []string{"metadata.generation", "status.lastResync"}; !reflect.DeepEqual(resp.FiredRules, expected) {
+		t.Errorf("Expected fired rules %v, got %v", expected, resp.FiredRules)
+	}
+}
+
+func TestHandleEvaluate_InlineRuleset(t *testing.T) {
+	resp := evaluate(t, `{
+		"oldObject": {"spec": {"json": "a"}, "status": {"lastResync": "1"}},
+		"object": {"spec": {"json": "a"}, "status": {"lastResync": "2"}},
+		"ruleset": {"ignorePaths": []}
+	}`)
+
+	if !resp.Allowed {
+		t.Errorf("Expected lastResync to count as a change without ignore paths")
+	}
+	if len(resp.FiredRules) != 0 {
+		t.Errorf("Expected no fired rules, got %v", resp.FiredRules)
+	}
+	if len(resp.Diff) != 1 || resp.Diff[0].Path != "status.lastResync" {
+		t.Errorf("Expected a status.lastResync diff, got %+v", resp.Diff)
+	}
+}
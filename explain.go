@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// maxExplainedDiff caps the diff kept with the last decision of an object, as
+// a dashboard model can be large.
+const maxExplainedDiff = 100
+
+// explainedDecision is the last decision made for an object.
+type explainedDecision struct {
+	Time            time.Time            `json:"time"`
+	UID             types.UID            `json:"uid"`
+	Operation       string               `json:"operation"`
+	User            string               `json:"user"`
+	Allowed         bool                 `json:"allowed"`
+	Code            int32                `json:"code,omitempty"`
+	Message         string               `json:"message,omitempty"`
+	Warnings        []string             `json:"warnings,omitempty"`
+	Ruleset         string               `json:"ruleset,omitempty"`
+	ChangedSections []string             `json:"changedSections,omitempty"`
+	FiredRules      []string             `json:"firedRules,omitempty"`
+	Diff            []difference         `json:"diff,omitempty"`
+	NormalizedPatch []jsonPatchOperation `json:"normalizedPatch,omitempty"`
+	DiffTruncated   bool                 `json:"diffTruncated,omitempty"`
+}
+
+// decisionLog keeps the last decision per object for as long as the change
+// history, changeIndexWindow.
+type decisionLog struct {
+	mu        sync.Mutex
+	entries   map[string]explainedDecision
+	lastSweep time.Time
+}
+
+var lastDecisions = newDecisionLog()
+
+func newDecisionLog() *decisionLog {
+	return &decisionLog{entries: make(map[string]explainedDecision)}
+}
+
+func decisionKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// newExplainedDecision describes the decision made for req at now.
+func newExplainedDecision(req *admissionv1.AdmissionRequest, resp *admissionv1.AdmissionResponse, cmp *comparison, now time.Time) explainedDecision {
+	decision := explainedDecision{
+		Time:      now,
+		UID:       req.UID,
+		Operation: string(req.Operation),
+		User:      req.UserInfo.Username,
+		Allowed:   resp.Allowed,
+		Warnings:  resp.Warnings,
+	}
+	if resp.Result != nil {
+		decision.Code = resp.Result.Code
+		decision.Message = resp.Result.Message
+	}
+	if cmp != nil {
+		decision.Ruleset = cmp.variant
+		decision.ChangedSections = cmp.changedSections()
+		decision.FiredRules = cmp.ignoredHits
+		if cmp.changed() && !cmp.partial {
+			decision.Diff = diffObjects(cmp.oldObj, cmp.newObj)
+			if len(decision.Diff) > maxExplainedDiff {
+				decision.Diff, decision.DiffTruncated = decision.Diff[:maxExplainedDiff], true
+			}
+			decision.NormalizedPatch = patchObjects(cmp.oldObj, cmp.newObj)
+			if len(decision.NormalizedPatch) > maxExplainedDiff {
+				decision.NormalizedPatch, decision.DiffTruncated = decision.NormalizedPatch[:maxExplainedDiff], true
+			}
+		}
+	}
+	return decision
+}
+
+// record stores the last decision made for req.
+func (l *decisionLog) record(req *admissionv1.AdmissionRequest, decision explainedDecision) {
+	now := decision.Time
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= changeIndexWindow {
+		for key, entry := range l.entries {
+			if now.Sub(entry.Time) > changeIndexWindow {
+				delete(l.entries, key)
+			}
+		}
+		l.lastSweep = now
+	}
+	l.entries[decisionKey(req.Kind.Kind, req.Namespace, req.Name)] = decision
+}
+
+// reset forgets every decision.
+func (l *decisionLog) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = make(map[string]explainedDecision)
+}
+
+// get returns the last decision made for the object, if any.
+func (l *decisionLog) get(kind, namespace, name string) (explainedDecision, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	decision, ok := l.entries[decisionKey(kind, namespace, name)]
+	return decision, ok
+}
+
+// explanation is the /debug/explain view of one object.
+type explanation struct {
+	Kind               string             `json:"kind"`
+	Namespace          string             `json:"namespace"`
+	Name               string             `json:"name"`
+	Rules              mergedKindRules    `json:"rules"`
+	CanaryRules        *mergedKindRules   `json:"canaryRules,omitempty"`
+	CanaryPercent      int                `json:"canaryPercent,omitempty"`
+	DecisionMode       string             `json:"decisionMode"`
+	DecisionModeSource string             `json:"decisionModeSource"`
+	LastDecision       *explainedDecision `json:"lastDecision"`
+}
+
+// handleDebugExplain answers what the webhook does to one object and why: the
+// rules that apply to it, the decision mode of its namespace and the last
+// decision made for it, with its diff.
+func handleDebugExplain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	e := explanation{
+		Kind:      query.Get("kind"),
+		Namespace: query.Get("namespace"),
+		Name:      query.Get("name"),
+	}
+	if e.Kind == "" {
+		e.Kind = kindFilter.kind
+	}
+	if e.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	ruleLayersMu.RLock()
+	e.Rules = mergedRules[e.Kind]
+	if rules, ok := canaryRules[e.Kind]; ok && canaryPercent > 0 {
+		e.CanaryRules, e.CanaryPercent = &rules, canaryPercent
+	}
+	ruleLayersMu.RUnlock()
+
+	mode, overridden := decisionModeFor(e.Namespace)
+	e.DecisionMode, e.DecisionModeSource = mode, "global"
+	if overridden {
+		e.DecisionModeSource = "namespace"
+	}
+
+	if decision, ok := lastDecisions.get(e.Kind, e.Namespace, e.Name); ok {
+		e.LastDecision = &decision
+	}
+
+	responseBytes, err := json.Marshal(e)
+	if err != nil {
+		log.Errorf("Failed to marshal explanation: %v", err)
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, responseBytes)
+}
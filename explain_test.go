@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestHandleDebugExplain(t *testing.T) {
+	reqBytes, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       "explain-uid",
+			Kind:      metav1.GroupVersionKind{Kind: "GrafanaDashboard"},
+			Namespace: "ns",
+			Name:      "explained",
+			Operation: admissionv1.Update,
+			OldObject: runtime.RawExtension{Raw: []byte(`{"metadata": {"generation": 1}, "spec": {"json": "{\"title\": \"a\"}"}}`)},
+			Object:    runtime.RawExtension{Raw: []byte(`{"metadata": {"generation": 2}, "spec": {"json": "{\"title\": \"b\"}"}}`)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+	handleAdmissionReview(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(reqBytes)))
+
+	w := httptest.NewRecorder()
+	handleDebugExplain(w, httptest.NewRequest(http.MethodGet, "/debug/explain?kind=GrafanaDashboard&namespace=ns&name=explained", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", w.Code)
+	}
+
+	var e explanation
+	if err := json.NewDecoder(w.Body).Decode(&e); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(e.Rules.IgnorePaths) == 0 {
+		t.Errorf("Expected the dashboard rules, got %+v", e.Rules)
+	}
+	if e.DecisionMode != decisionMode || e.DecisionModeSource != "global" {
+		t.Errorf("Expected the global decision mode, got %s from %s", e.DecisionMode, e.DecisionModeSource)
+	}
+	if e.LastDecision == nil {
+		t.Fatalf("Expected the last decision to be explained")
+	}
+	if !e.LastDecision.Allowed || e.LastDecision.UID != "explain-uid" {
+		t.Errorf("Expected the allowed decision for explain-uid, got %+v", e.LastDecision)
+	}
+	if len(e.LastDecision.Diff) != 1 || e.LastDecision.Diff[0].Path != "spec.json.title" {
+		t.Errorf("Expected a spec.json.title diff, got %+v", e.LastDecision.Diff)
+	}
+	if len(e.LastDecision.NormalizedPatch) != 1 || e.LastDecision.NormalizedPatch[0].Path != "/spec/json/title" {
+		t.Errorf("Expected a /spec/json/title patch, got %+v", e.LastDecision.NormalizedPatch)
+	}
+	if len(e.LastDecision.FiredRules) != 1 || e.LastDecision.FiredRules[0] != "metadata.generation" {
+		t.Errorf("Expected metadata.generation to have fired, got %v", e.LastDecision.FiredRules)
+	}
+}
+
+func TestHandleDebugExplain_RequiresName(t *testing.T) {
+	w := httptest.NewRecorder()
+	handleDebugExplain(w, httptest.NewRequest(http.MethodGet, "/debug/explain?namespace=ns", nil))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code 400, got %d", w.Code)
+	}
+}
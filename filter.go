@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// admissionFilter describes which admission requests the webhook evaluates.
+// The registered ValidatingWebhookConfiguration is derived from the same
+// filter, so the apiserver only sends requests the handler acts on.
+type admissionFilter struct {
+	group      string
+	version    string
+	resource   string
+	kind       string
+	operations []admissionv1.Operation
+
+	// objectSelector restricts evaluation to objects with matching labels.
+	objectSelector *metav1.LabelSelector
+}
+
+// kindFilter selects updates of the kind of the active profile.
+var kindFilter = admissionFilter{
+	group:          activeProfile.Group,
+	version:        activeProfile.Version,
+	resource:       activeProfile.Resource,
+	kind:           activeProfile.Kind,
+	operations:     []admissionv1.Operation{admissionv1.Update},
+	objectSelector: &metav1.LabelSelector{},
+}
+
+// matches reports whether req is one the webhook evaluates. Like the
+// apiserver, the object selector matches if either the old or the new object
+// carries matching labels. Subresources that do not carry the object never
+// match.
+func (f admissionFilter) matches(req *admissionv1.AdmissionRequest) bool {
+	if req.Kind.Kind != f.kind || isPassthroughSubresource(req) {
+		return false
+	}
+
+	operationMatched := false
+	for _, op := range f.operations {
+		if req.Operation == op {
+			operationMatched = true
+			break
+		}
+	}
+	if !operationMatched {
+		return false
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(f.objectSelector)
+	if err != nil {
+		return false
+	}
+	if selector.Empty() {
+		return true
+	}
+	return selector.Matches(objectLabels(req.OldObject.Raw)) || selector.Matches(objectLabels(req.Object.Raw))
+}
+
+// rules returns the webhook rules selecting the same requests as f.
+func (f admissionFilter) rules() []admissionregistrationv1.RuleWithOperations {
+	scope := admissionregistrationv1.AllScopes
+	operations := make([]admissionregistrationv1.OperationType, 0, len(f.operations))
+	for _, op := range f.operations {
+		operations = append(operations, admissionregistrationv1.OperationType(op))
+	}
+
+	return []admissionregistrationv1.RuleWithOperations{
+		{
+			Operations: operations,
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{f.group},
+				APIVersions: []string{f.version},
+				Resources:   []string{f.resource},
+				Scope:       &scope,
+			},
+		},
+	}
+}
+
+// objectLabels extracts metadata.labels from a raw object.
+func objectLabels(raw []byte) labels.Set {
+	var obj struct {
+		Metadata struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}
+	if len(raw) == 0 || json.Unmarshal(raw, &obj) != nil {
+		return nil
+	}
+	return obj.Metadata.Labels
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestAdmissionFilter_Matches(t *testing.T) {
+	selector, err := metav1.ParseToLabelSelector("team=platform")
+	if err != nil {
+		t.Fatalf("Failed to parse selector: %v", err)
+	}
+	filter := kindFilter
+	filter.objectSelector = selector
+
+	labeled := []byte(`{"metadata": {"labels": {"team": "platform"}}}`)
+	unlabeled := []byte(`{"metadata": {}}`)
+
+	tests := []struct {
+		name      string
+		kind      string
+		operation admissionv1.Operation
+		oldObject []byte
+		object    []byte
+		expected  bool
+	}{
+		{"matching labels", "GrafanaDashboard", admissionv1.Update, labeled, labeled, true},
+		{"label added", "GrafanaDashboard", admissionv1.Update, unlabeled, labeled, true},
+		{"label removed", "GrafanaDashboard", admissionv1.Update, labeled, unlabeled, true},
+		{"no matching labels", "GrafanaDashboard", admissionv1.Update, unlabeled, unlabeled, false},
+		{"other operation", "GrafanaDashboard", admissionv1.Create, nil, labeled, false},
+		{"other kind", "GrafanaFolder", admissionv1.Update, labeled, labeled, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &admissionv1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Kind: tt.kind},
+				Operation: tt.operation,
+				OldObject: runtime.RawExtension{Raw: tt.oldObject},
+				Object:    runtime.RawExtension{Raw: tt.object},
+			}
+			if got := filter.matches(req); got != tt.expected {
+				t.Errorf("Expected matches=%t, got %t", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDesiredWebhookConfiguration_FollowsFilter(t *testing.T) {
+	defer func(selector *metav1.LabelSelector) { kindFilter.objectSelector = selector }(kindFilter.objectSelector)
+	kindFilter.objectSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "platform"}}
+
+	webhook := desiredWebhookConfiguration(nil).Webhooks[0]
+
+	if webhook.ObjectSelector.MatchLabels["team"] != "platform" {
+		t.Errorf("Expected object selector from filter, got %v", webhook.ObjectSelector)
+	}
+	if rule := webhook.Rules[0]; rule.Resources[0] != kindFilter.resource || string(rule.Operations[0]) != string(admissionv1.Update) {
+		t.Errorf("Expected rules derived from filter, got %+v", rule)
+	}
+}
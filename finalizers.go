@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// finalizerPolicy restricts who may add or remove a finalizer. Entries are
+// usernames, or group names prefixed with "group:". An empty list leaves the
+// action unrestricted. Removing a finalizer that guards cleanup, such as the
+// operator's own, leaves dashboards behind in Grafana.
+type finalizerPolicy struct {
+	Finalizer   string   `json:"finalizer"`
+	AddUsers    []string `json:"addUsers,omitempty"`
+	RemoveUsers []string `json:"removeUsers,omitempty"`
+}
+
+var (
+	// Counter for updates denied by a finalizer policy, by finalizer and action
+	finalizerPolicyDenialsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_finalizer_policy_denials_total",
+			Help: "Total number of updates denied by a finalizer policy, differentiated by finalizer and action.",
+		},
+		[]string{"finalizer", "action"}, // action is "add" or "remove"
+	)
+)
+
+func init() {
+	prometheus.MustRegister(finalizerPolicyDenialsTotal)
+}
+
+// permits reports whether the user may perform an action restricted to
+// users.
+func permits(users []string, user string, groups []string) bool {
+	if len(users) == 0 {
+		return true
+	}
+	for _, allowed := range users {
+		if allowed == user {
+			return true
+		}
+		if group, ok := strings.CutPrefix(allowed, "group:"); ok && slices.Contains(groups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// objectFinalizers decodes only metadata.finalizers from a raw object.
+func objectFinalizers(raw []byte) ([]string, error) {
+	var obj struct {
+		Metadata struct {
+			Finalizers []string `json:"finalizers"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	return obj.Metadata.Finalizers, nil
+}
+
+// finalizerViolation returns the first policy the request violates and the
+// action taken, or nil.
+func finalizerViolation(policies []finalizerPolicy, req *admissionv1.AdmissionRequest) (*finalizerPolicy, string, error) {
+	oldFinalizers, err := objectFinalizers(req.OldObject.Raw)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse old object: %w", err)
+	}
+	newFinalizers, err := objectFinalizers(req.Object.Raw)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse new object: %w", err)
+	}
+
+	for i, policy := range policies {
+		had := slices.Contains(oldFinalizers, policy.Finalizer)
+		has := slices.Contains(newFinalizers, policy.Finalizer)
+
+		var action string
+		var users []string
+		switch {
+		case !had && has:
+			action, users = "add", policy.AddUsers
+		case had && !has:
+			action, users = "remove", policy.RemoveUsers
+		default:
+			continue
+		}
+		if !permits(users, req.UserInfo.Username, req.UserInfo.Groups) {
+			return &policies[i], action, nil
+		}
+	}
+	return nil, "", nil
+}
+
+// applyFinalizerPolicies denies a request that adds or removes a finalizer
+// its user may not touch, whatever the comparison decided.
+func applyFinalizerPolicies(ctx context.Context, req *admissionv1.AdmissionRequest, resp *admissionv1.AdmissionResponse) {
+	if !kindFilter.matches(req) || len(req.OldObject.Raw) == 0 || len(req.Object.Raw) == 0 {
+		return
+	}
+
+	rules, _ := selectRuleset(req.Kind.Kind, canaryKey(req))
+	if len(rules.FinalizerPolicies) == 0 {
+		return
+	}
+
+	policy, action, err := finalizerViolation(rules.FinalizerPolicies, req)
+	if err != nil {
+		loggerFromContext(ctx).Errorf("Failed to check finalizer policies: %v", err)
+		return
+	}
+	if policy == nil {
+		return
+	}
+
+	finalizerPolicyDenialsTotal.WithLabelValues(policy.Finalizer, action).Inc()
+	message := fmt.Sprintf("%s may not %s finalizer %s", req.UserInfo.Username, action, policy.Finalizer)
+	loggerFromContext(ctx).Warnf("Denied by finalizer policy: %s", message)
+
+	resp.Allowed = false
+	resp.Warnings = nil
+	resp.Result = &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Reason:  metav1.StatusReasonForbidden,
+		Message: message,
+		Code:    http.StatusForbidden,
+	}
+}
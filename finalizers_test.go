@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const operatorFinalizer = "operator.grafana.com/finalizer"
+
+func finalizerRequest(user string, groups []string, oldFinalizers, newFinalizers string) *admissionv1.AdmissionRequest {
+	return &admissionv1.AdmissionRequest{
+		UID:       "finalizer-uid",
+		Kind:      metav1.GroupVersionKind{Group: "grafana.integreatly.org", Version: "v1beta1", Kind: "GrafanaDashboard"},
+		Resource:  metav1.GroupVersionResource{Group: "grafana.integreatly.org", Version: "v1beta1", Resource: "grafanadashboards"},
+		Operation: admissionv1.Update,
+		UserInfo:  authenticationv1.UserInfo{Username: user, Groups: groups},
+		OldObject: runtime.RawExtension{Raw: []byte(`{"metadata": {"finalizers": ` + oldFinalizers + `}}`)},
+		Object:    runtime.RawExtension{Raw: []byte(`{"metadata": {"finalizers": ` + newFinalizers + `}}`)},
+	}
+}
+
+func TestApplyFinalizerPolicies(t *testing.T) {
+	defer setRuleLayer(ruleSourceRuntime, nil)
+	setRuleLayer(ruleSourceRuntime, &ruleLayer{Kinds: map[string]kindRules{
+		"GrafanaDashboard": {FinalizerPolicies: []finalizerPolicy{{
+			Finalizer:   operatorFinalizer,
+			RemoveUsers: []string{"system:serviceaccount:grafana-operator:grafana-operator", "group:platform-admins"},
+		}}},
+	}})
+
+	tests := []struct {
+		name          string
+		req           *admissionv1.AdmissionRequest
+		expectAllowed bool
+	}{
+		{"operator removes", finalizerRequest("system:serviceaccount:grafana-operator:grafana-operator", nil, `["`+operatorFinalizer+`"]`, `[]`), true},
+		{"admin group removes", finalizerRequest("alice", []string{"platform-admins"}, `["`+operatorFinalizer+`"]`, `null`), true},
+		{"other user removes", finalizerRequest("bob", []string{"developers"}, `["`+operatorFinalizer+`"]`, `[]`), false},
+		{"other user adds", finalizerRequest("bob", nil, `[]`, `["`+operatorFinalizer+`"]`), true},
+		{"other finalizer removed", finalizerRequest("bob", nil, `["`+operatorFinalizer+`", "x"]`, `["`+operatorFinalizer+`"]`), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &admissionv1.AdmissionResponse{UID: tt.req.UID, Allowed: true}
+			applyFinalizerPolicies(context.Background(), tt.req, resp)
+
+			if resp.Allowed != tt.expectAllowed {
+				t.Errorf("Expected allowed=%t, got %t", tt.expectAllowed, resp.Allowed)
+			}
+			if !resp.Allowed && (resp.Result == nil || resp.Result.Code != http.StatusForbidden) {
+				t.Errorf("Expected a 403 status for a policy denial, got %+v", resp.Result)
+			}
+			if !resp.Allowed && isNoopDenial(resp) {
+				t.Errorf("Expected a policy denial not to look like a no-op denial")
+			}
+		})
+	}
+}
+
+func TestMergeRuleLayers_FinalizerPolicies(t *testing.T) {
+	merged := mergeRuleLayers(map[string]*ruleLayer{
+		ruleSourceFile: {Kinds: map[string]kindRules{
+			"GrafanaDashboard": {FinalizerPolicies: []finalizerPolicy{
+				{Finalizer: "a", RemoveUsers: []string{"file"}},
+				{Finalizer: "b", RemoveUsers: []string{"file"}},
+			}},
+		}},
+		ruleSourceRuntime: {Kinds: map[string]kindRules{
+			"GrafanaDashboard": {FinalizerPolicies: []finalizerPolicy{{Finalizer: "a", RemoveUsers: []string{"runtime"}}}},
+		}},
+	})
+
+	policies := merged["GrafanaDashboard"].FinalizerPolicies
+	if len(policies) != 2 {
+		t.Fatalf("Expected 2 policies, got %+v", policies)
+	}
+	for _, policy := range policies {
+		if policy.Finalizer == "a" && policy.RemoveUsers[0] != "runtime" {
+			t.Errorf("Expected the runtime policy for finalizer a to win, got %+v", policy)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import "strings"
+
+// stringSliceFlag is a flag.Value collecting every occurrence of a repeated
+// flag.
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// freezeWindowsFile is an optional JSON file defining change freezes, during
+// which spec changes are denied.
+var freezeWindowsFile = ""
+
+// defaultBreakGlassAnnotation lets an object bypass a freeze when set to
+// "true" on the new version of the object.
+const defaultBreakGlassAnnotation = "grafana-operator-webhook/break-glass"
+
+// freezeConfig is the content of freezeWindowsFile.
+type freezeConfig struct {
+	// CalendarURL is included in denials so users can see the freezes.
+	CalendarURL          string         `json:"calendarURL,omitempty"`
+	BreakGlassAnnotation string         `json:"breakGlassAnnotation,omitempty"`
+	BreakGlassGroups     []string       `json:"breakGlassGroups,omitempty"`
+	Windows              []freezeWindow `json:"windows"`
+}
+
+// freezeWindow is a recurring freeze starting on Schedule and lasting
+// Duration, or a one-off freeze between Start and End. Namespaces and
+// Selector narrow it down; without them it applies to every object.
+type freezeWindow struct {
+	Name       string                `json:"name"`
+	Schedule   string                `json:"schedule,omitempty"`
+	Duration   string                `json:"duration,omitempty"`
+	TimeZone   string                `json:"timeZone,omitempty"`
+	Start      *time.Time            `json:"start,omitempty"`
+	End        *time.Time            `json:"end,omitempty"`
+	Namespaces []string              `json:"namespaces,omitempty"`
+	Selector   *metav1.LabelSelector `json:"selector,omitempty"`
+
+	schedule *cronSchedule
+	duration time.Duration
+	location *time.Location
+	selector labels.Selector
+}
+
+// freezes is the loaded configuration, nil without freezeWindowsFile.
+var freezes *freezeConfig
+
+var (
+	// Counter for spec changes denied by a freeze window, by window
+	freezeDenialsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_freeze_denials_total",
+			Help: "Total number of spec changes denied during a change freeze, differentiated by window.",
+		},
+		[]string{"window"},
+	)
+
+	// Counter for spec changes allowed during a freeze by break-glass
+	freezeBreakGlassTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_freeze_break_glass_total",
+			Help: "Total number of spec changes allowed during a change freeze by break-glass, differentiated by window.",
+		},
+		[]string{"window"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(freezeDenialsTotal)
+	prometheus.MustRegister(freezeBreakGlassTotal)
+}
+
+// loadFreezeConfig reads and validates a freeze configuration file.
+func loadFreezeConfig(path string) (*freezeConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read freeze windows file: %w", err)
+	}
+
+	var config freezeConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse freeze windows file %s: %w", path, err)
+	}
+	if config.BreakGlassAnnotation == "" {
+		config.BreakGlassAnnotation = defaultBreakGlassAnnotation
+	}
+
+	for i := range config.Windows {
+		if err := config.Windows[i].compile(); err != nil {
+			return nil, fmt.Errorf("invalid freeze window %q: %w", config.Windows[i].Name, err)
+		}
+	}
+	return &config, nil
+}
+
+func (w *freezeWindow) compile() error {
+	switch {
+	case w.Schedule != "":
+		schedule, err := parseCron(w.Schedule)
+		if err != nil {
+			return err
+		}
+		duration, err := time.ParseDuration(w.Duration)
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("invalid duration %q", w.Duration)
+		}
+		location, err := time.LoadLocation(w.TimeZone)
+		if err != nil {
+			return err
+		}
+		w.schedule, w.duration, w.location = schedule, duration, location
+	case w.Start != nil && w.End != nil:
+		if !w.End.After(*w.Start) {
+			return fmt.Errorf("end %s is not after start %s", w.End, w.Start)
+		}
+	default:
+		return fmt.Errorf("either schedule and duration or start and end must be set")
+	}
+
+	w.selector = labels.Everything()
+	if w.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(w.Selector)
+		if err != nil {
+			return err
+		}
+		w.selector = selector
+	}
+	return nil
+}
+
+// activeUntil reports whether the window is active at now and when it ends.
+func (w *freezeWindow) activeUntil(now time.Time) (time.Time, bool) {
+	if w.schedule == nil {
+		return *w.End, !now.Before(*w.Start) && now.Before(*w.End)
+	}
+
+	start, ok := w.schedule.lastFire(now.In(w.location), w.duration)
+	if !ok {
+		return time.Time{}, false
+	}
+	return start.Add(w.duration), true
+}
+
+// appliesTo reports whether the window covers an object in namespace with
+// objectLabels.
+func (w *freezeWindow) appliesTo(namespace string, objectLabels labels.Set) bool {
+	if len(w.Namespaces) > 0 && !slices.Contains(w.Namespaces, namespace) {
+		return false
+	}
+	return w.selector.Matches(objectLabels)
+}
+
+// breakGlass reports whether the request may bypass freezes. The annotation
+// only breaks glass for the update that sets it: it stays on the object, and
+// would otherwise let every later update through until someone removed it.
+func (c *freezeConfig) breakGlass(req *admissionv1.AdmissionRequest) bool {
+	for _, group := range req.UserInfo.Groups {
+		if slices.Contains(c.BreakGlassGroups, group) {
+			return true
+		}
+	}
+	return c.breakGlassAnnotated(req.Object.Raw) && !c.breakGlassAnnotated(req.OldObject.Raw)
+}
+
+// breakGlassAnnotated reports whether the raw object carries the break-glass
+// annotation set to "true".
+func (c *freezeConfig) breakGlassAnnotated(raw []byte) bool {
+	var obj struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return false
+	}
+	return obj.Metadata.Annotations[c.BreakGlassAnnotation] == "true"
+}
+
+// applyFreezeWindows denies a spec change made during an active freeze that
+// covers the object, unless the request breaks glass.
+func applyFreezeWindows(ctx context.Context, req *admissionv1.AdmissionRequest, cmp *comparison, resp *admissionv1.AdmissionResponse, now time.Time) {
+	if freezes == nil || cmp == nil || !cmp.specChanged || !resp.Allowed {
+		return
+	}
+
+	objLabels := objectLabels(req.Object.Raw)
+	for i := range freezes.Windows {
+		window := &freezes.Windows[i]
+		if !window.appliesTo(req.Namespace, objLabels) {
+			continue
+		}
+		until, active := window.activeUntil(now)
+		if !active {
+			continue
+		}
+
+		if freezes.breakGlass(req) {
+			freezeBreakGlassTotal.WithLabelValues(window.Name).Inc()
+			loggerFromContext(ctx).Warnf("Spec change allowed during freeze %q by break-glass from %s", window.Name, req.UserInfo.Username)
+			resp.Warnings = append(resp.Warnings, fmt.Sprintf("change freeze %q bypassed by break-glass", window.Name))
+			return
+		}
+
+		freezeDenialsTotal.WithLabelValues(window.Name).Inc()
+		message := fmt.Sprintf("spec changes are frozen by %q until %s", window.Name, until.UTC().Format(time.RFC3339))
+		if freezes.CalendarURL != "" {
+			message += fmt.Sprintf(", see %s", freezes.CalendarURL)
+		}
+		loggerFromContext(ctx).Infof("Denied spec change: %s", message)
+
+		resp.Allowed = false
+		resp.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Reason:  metav1.StatusReasonForbidden,
+			Message: message,
+			Code:    http.StatusForbidden,
+		}
+		return
+	}
+}
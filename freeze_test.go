@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestApplyFreezeWindows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "freeze.json")
+	if err := os.WriteFile(path, []byte(`{
+		"calendarURL": "https://calendar.example.com/freezes",
+		"breakGlassGroups": ["sre"],
+		"windows": [{
+			"name": "weekend",
+			"schedule": "0 18 * * 5",
+			"duration": "62h",
+			"namespaces": ["production"],
+			"selector": {"matchLabels": {"tier": "critical"}}
+		}]
+	}`), 0o600); err != nil {
+		t.Fatalf("Failed to write freeze windows file: %v", err)
+	}
+
+	config, err := loadFreezeConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load freeze windows: %v", err)
+	}
+	defer func(c *freezeConfig) { freezes = c }(freezes)
+	freezes = config
+
+	saturday := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	tuesday := time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC)
+	critical := `{"metadata": {"labels": {"tier": "critical"}}, "spec": {}}`
+	brokenGlass := `{"metadata": {"labels": {"tier": "critical"}, "annotations": {"grafana-operator-webhook/break-glass": "true"}}}`
+
+	tests := []struct {
+		name          string
+		namespace     string
+		oldObject     string
+		object        string
+		groups        []string
+		now           time.Time
+		expectAllowed bool
+	}{
+		{"frozen", "production", critical, critical, nil, saturday, false},
+		{"outside the window", "production", critical, critical, nil, tuesday, true},
+		{"other namespace", "staging", critical, critical, nil, saturday, true},
+		{"unselected object", "production", critical, `{"metadata": {"labels": {"tier": "low"}}}`, nil, saturday, true},
+		{"break-glass group", "production", critical, critical, []string{"sre"}, saturday, true},
+		{"break-glass annotation added", "production", critical, brokenGlass, nil, saturday, true},
+		{"break-glass annotation left in place", "production", brokenGlass, brokenGlass, nil, saturday, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &admissionv1.AdmissionRequest{
+				Namespace: tt.namespace,
+				UserInfo:  authenticationv1.UserInfo{Username: "alice", Groups: tt.groups},
+				OldObject: runtime.RawExtension{Raw: []byte(tt.oldObject)},
+				Object:    runtime.RawExtension{Raw: []byte(tt.object)},
+			}
+			resp := &admissionv1.AdmissionResponse{Allowed: true}
+
+			applyFreezeWindows(context.Background(), req, &comparison{specChanged: true}, resp, tt.now)
+
+			if resp.Allowed != tt.expectAllowed {
+				t.Errorf("Expected allowed=%t, got %t", tt.expectAllowed, resp.Allowed)
+			}
+			if !resp.Allowed && !strings.Contains(resp.Result.Message, "https://calendar.example.com/freezes") {
+				t.Errorf("Expected the denial to point to the calendar, got %q", resp.Result.Message)
+			}
+		})
+	}
+}
+
+func TestLoadFreezeConfig_Invalid(t *testing.T) {
+	for _, content := range []string{
+		`{"windows": [{"name": "no schedule"}]}`,
+		`{"windows": [{"name": "bad cron", "schedule": "* * *", "duration": "1h"}]}`,
+		`{"windows": [{"name": "bad duration", "schedule": "* * * * *", "duration": "soon"}]}`,
+		`{"windows": [{"name": "inverted", "start": "2026-10-02T00:00:00Z", "end": "2026-10-01T00:00:00Z"}]}`,
+	} {
+		path := filepath.Join(t.TempDir(), "freeze.json")
+		os.WriteFile(path, []byte(content), 0o600)
+		if _, err := loadFreezeConfig(path); err == nil {
+			t.Errorf("Expected %s to be rejected", content)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// newKubeConfig loads the in-cluster service account configuration.
+func newKubeConfig() (*rest.Config, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load in-cluster config: %w", err)
+	}
+	config.UserAgent = "grafana-operator-webhook"
+	return config, nil
+}
+
+// newKubeClient creates a clientset from the in-cluster service account.
+func newKubeClient() (kubernetes.Interface, error) {
+	config, err := newKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %w", err)
+	}
+	return client, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/errdefs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Objects above largeObjectThresholdBytes are not decoded and diffed in full.
+// The values at largeObjectPaths are hashed and compared, which keeps CPU
+// bounded for dashboards with huge specs or status trees at the cost of a
+// detailed diff. Metadata is small and always compared in full, minus the
+// ignored paths: a finalizer removal judged a no-op would never be written
+// and could leave the dashboard stuck terminating.
+var (
+	largeObjectThresholdBytes = 1 << 20 // 1 MiB
+	largeObjectPaths          = []string{"spec"}
+)
+
+var (
+	// Counter for comparisons that fell back to hashing high-value paths
+	largeObjectFallbackTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_large_object_fallback_total",
+			Help: "Total number of comparisons that hashed configured paths instead of diffing because the object exceeded the size threshold.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(largeObjectFallbackTotal)
+}
+
+// isLargeObject reports whether either object exceeds the size threshold.
+func isLargeObject(oldRaw, newRaw []byte) bool {
+	return largeObjectThresholdBytes > 0 && (len(oldRaw) > largeObjectThresholdBytes || len(newRaw) > largeObjectThresholdBytes)
+}
+
+// compareLargeObjects compares the metadata with the ignored paths of rules
+// removed, and the hashes of the values at largeObjectPaths. A differing path
+// marks its top-level section as changed. The comparison is marked partial,
+// which handleAdmissionReview counts in largeObjectFallbackTotal.
+func compareLargeObjects(ctx context.Context, rules ruleset, oldRaw, newRaw []byte) (comparison, error) {
+	loggerFromContext(ctx).Debugf("Object exceeds %d bytes, comparing metadata and hashes of %v only", largeObjectThresholdBytes, largeObjectPaths)
+
+	cmp := comparison{partial: true}
+	oldMeta, err := metadataOnly(oldRaw)
+	if err != nil {
+		return cmp, fmt.Errorf("%w: failed to parse old object: %w", errdefs.ErrMalformedReview, err)
+	}
+	newMeta, err := metadataOnly(newRaw)
+	if err != nil {
+		return cmp, fmt.Errorf("%w: failed to parse new object: %w", errdefs.ErrMalformedReview, err)
+	}
+	cmp.ignoredHits = rules.compiledIgnorePaths().apply(nil, oldMeta, newMeta)
+	removeChangeAnnotation(oldMeta, newMeta)
+	cmp.metadataChanged = !reflect.DeepEqual(oldMeta["metadata"], newMeta["metadata"])
+
+	for _, path := range largeObjectPaths {
+		oldHash, err := hashPath(oldRaw, path)
+		if err != nil {
+			return cmp, fmt.Errorf("%w: failed to parse old object: %w", errdefs.ErrMalformedReview, err)
+		}
+		newHash, err := hashPath(newRaw, path)
+		if err != nil {
+			return cmp, fmt.Errorf("%w: failed to parse new object: %w", errdefs.ErrMalformedReview, err)
+		}
+		if oldHash == newHash {
+			continue
+		}
+
+		switch strings.SplitN(path, ".", 2)[0] {
+		case "metadata":
+			cmp.metadataChanged = true
+		case "spec":
+			cmp.specChanged = true
+		case "status":
+			cmp.statusChanged = true
+		}
+	}
+	return cmp, nil
+}
+
+// metadataOnly decodes the metadata of raw into an object holding nothing
+// else, so the ignore paths apply to it as to a full object.
+func metadataOnly(raw []byte) (map[string]interface{}, error) {
+	var obj struct {
+		Metadata interface{} `json:"metadata"`
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"metadata": obj.Metadata}, nil
+}
+
+// hashPath returns the SHA-256 of the raw JSON value at the dot-separated
+// path. Only the objects along the path are decoded, one level at a time; a
+// missing value hashes like an empty one.
+func hashPath(raw []byte, path string) ([sha256.Size]byte, error) {
+	value := json.RawMessage(raw)
+	for _, key := range strings.Split(path, ".") {
+		if len(value) == 0 || bytes.Equal(value, []byte("null")) {
+			value = nil
+			break
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(value, &fields); err != nil {
+			return [sha256.Size]byte{}, err
+		}
+		value = fields[key]
+	}
+	return sha256.Sum256(value), nil
+}
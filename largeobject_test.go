@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestCompareObjects_LargeObjectFallback(t *testing.T) {
+	defer func(threshold int) { largeObjectThresholdBytes = threshold }(largeObjectThresholdBytes)
+	largeObjectThresholdBytes = 64
+
+	bigStatus := `"status": {"tree": "` + strings.Repeat("x", 128) + `"}`
+
+	tests := []struct {
+		name            string
+		oldObject       string
+		object          string
+		expectedMeta    bool
+		expectedSpec    bool
+		expectedChanged bool
+	}{
+		{
+			"status only",
+			`{"metadata": {"labels": {"a": "1"}}, "spec": {"json": "1"}, ` + bigStatus + `}`,
+			`{"metadata": {"labels": {"a": "1"}}, "spec": {"json": "1"}, "status": {"tree": "y"}}`,
+			false, false, false,
+		},
+		{
+			"spec changed",
+			`{"metadata": {"labels": {"a": "1"}}, "spec": {"json": "1"}, ` + bigStatus + `}`,
+			`{"metadata": {"labels": {"a": "1"}}, "spec": {"json": "2"}, ` + bigStatus + `}`,
+			false, true, true,
+		},
+		{
+			"finalizer removed",
+			`{"metadata": {"finalizers": ["operator.grafana.com/finalizer"]}, "spec": {"json": "1"}, ` + bigStatus + `}`,
+			`{"metadata": {}, "spec": {"json": "1"}, ` + bigStatus + `}`,
+			true, false, true,
+		},
+		{
+			"annotation changed",
+			`{"metadata": {"annotations": {"a": "1"}}, "spec": {"json": "1"}, ` + bigStatus + `}`,
+			`{"metadata": {"annotations": {"a": "2"}}, "spec": {"json": "1"}, ` + bigStatus + `}`,
+			true, false, true,
+		},
+		{
+			"owner reference added",
+			`{"metadata": {}, "spec": {"json": "1"}, ` + bigStatus + `}`,
+			`{"metadata": {"ownerReferences": [{"kind": "Folder", "name": "f"}]}, "spec": {"json": "1"}, ` + bigStatus + `}`,
+			true, false, true,
+		},
+		{
+			"ignored metadata only",
+			`{"metadata": {"generation": 1}, "spec": {"json": "1"}, ` + bigStatus + `}`,
+			`{"metadata": {"generation": 2}, "spec": {"json": "1"}, ` + bigStatus + `}`,
+			false, false, false,
+		},
+		{
+			"label added",
+			`{"metadata": {}, "spec": {"json": "1"}, ` + bigStatus + `}`,
+			`{"metadata": {"labels": {"a": "1"}}, "spec": {"json": "1"}, ` + bigStatus + `}`,
+			true, false, true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmp, err := compareObjects(context.Background(), activeRuleset(kindFilter.kind), []byte(tt.oldObject), []byte(tt.object))
+			if err != nil {
+				t.Fatalf("Failed to compare objects: %v", err)
+			}
+			if !cmp.partial {
+				t.Fatalf("Expected the large object fallback to be used")
+			}
+			if cmp.metadataChanged != tt.expectedMeta || cmp.specChanged != tt.expectedSpec || cmp.changed() != tt.expectedChanged {
+				t.Errorf("Unexpected comparison: %+v", cmp)
+			}
+		})
+	}
+}
+
+func TestHashPath_MissingEqualsNull(t *testing.T) {
+	missing, err := hashPath([]byte(`{"metadata": {}}`), "metadata.labels")
+	if err != nil {
+		t.Fatalf("Failed to hash path: %v", err)
+	}
+	null, err := hashPath([]byte(`{"metadata": null}`), "metadata.labels")
+	if err != nil {
+		t.Fatalf("Failed to hash path: %v", err)
+	}
+	if missing != null {
+		t.Errorf("Expected missing and null values to hash equally")
+	}
+
+	if _, err := hashPath([]byte(`not json`), "spec"); err == nil {
+		t.Errorf("Expected an error for invalid JSON, got nil")
+	}
+}
+
+func TestHandleAdmissionReview_LargeObjectCountedOnce(t *testing.T) {
+	defer func(threshold int) { largeObjectThresholdBytes = threshold }(largeObjectThresholdBytes)
+	largeObjectThresholdBytes = 64
+	defer setRuleLayer(ruleSourceCanary, nil)
+	defer func(p int) { canaryPercent = p }(canaryPercent)
+	setRuleLayer(ruleSourceCanary, &ruleLayer{Kinds: map[string]kindRules{
+		"GrafanaDashboard": {IgnorePaths: []string{"spec.resyncPeriod"}},
+	}})
+	canaryPercent = 100
+
+	object := []byte(`{"metadata": {"name": "big"}, "spec": {"json": "1"}, "status": {"tree": "` + strings.Repeat("x", 128) + `"}}`)
+	before := testutil.ToFloat64(largeObjectFallbackTotal)
+
+	// Dry runs compare without counting
+	if _, err := compareObjects(context.Background(), activeRuleset(kindFilter.kind), object, object); err != nil {
+		t.Fatalf("Failed to compare objects: %v", err)
+	}
+	if got := testutil.ToFloat64(largeObjectFallbackTotal) - before; got != 0 {
+		t.Errorf("Expected compareObjects not to count the fallback, got %v", got)
+	}
+
+	reqBytes, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       "large-object-uid",
+			Kind:      metav1.GroupVersionKind{Group: "grafana.integreatly.org", Version: "v1beta1", Kind: "GrafanaDashboard"},
+			Resource:  metav1.GroupVersionResource{Group: "grafana.integreatly.org", Version: "v1beta1", Resource: "grafanadashboards"},
+			Operation: admissionv1.Update,
+			OldObject: runtime.RawExtension{Raw: object},
+			Object:    runtime.RawExtension{Raw: object},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+	handleAdmissionReview(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(reqBytes)))
+
+	// The canary comparison is repeated with the stable rules, but the
+	// request is counted once
+	if got := testutil.ToFloat64(largeObjectFallbackTotal) - before; got != 1 {
+		t.Errorf("Expected 1 fallback to be counted, got %v", got)
+	}
+}
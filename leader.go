@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Every replica serves admission requests, but cluster-wide background tasks
+// must only run on one of them. With --leader-elect the replicas compete for
+// a Lease and only the holder runs the tasks.
+var (
+	leaderElect             = false
+	leaderElectionNamespace = "grafana-operator"
+	leaderElectionID        = "grafana-operator-webhook"
+)
+
+var (
+	// Gauge reporting whether this replica currently runs the background tasks
+	isLeader = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "grafana_operator_webhook_leader",
+			Help: "Whether this replica is the leader running background tasks (1) or not (0).",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(isLeader)
+}
+
+// backgroundTask runs until ctx is cancelled.
+type backgroundTask struct {
+	name string
+	run  func(ctx context.Context)
+}
+
+var backgroundTasks []backgroundTask
+
+// registerBackgroundTask adds a task that runs on the leader replica only.
+// It must be called before runBackgroundTasks.
+func registerBackgroundTask(name string, run func(ctx context.Context)) {
+	backgroundTasks = append(backgroundTasks, backgroundTask{name: name, run: run})
+}
+
+// runBackgroundTasks runs all registered tasks and returns once they have all
+// stopped after ctx is cancelled.
+func runBackgroundTasks(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, task := range backgroundTasks {
+		wg.Add(1)
+		go func(task backgroundTask) {
+			defer wg.Done()
+			log.Infof("Starting background task %s", task.name)
+			task.run(ctx)
+			log.Infof("Background task %s stopped", task.name)
+		}(task)
+	}
+	wg.Wait()
+}
+
+// runLeaderElection blocks until ctx is cancelled, running the background
+// tasks whenever this replica holds the lease.
+func runLeaderElection(ctx context.Context, client kubernetes.Interface) error {
+	identity, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("failed to determine leader election identity: %w", err)
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      leaderElectionID,
+			Namespace: leaderElectionNamespace,
+		},
+		Client:     client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+
+	// Losing the lease cancels the tasks, and RunOrDie returns; campaign
+	// again until the process itself is shutting down.
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			ReleaseOnCancel: true,
+			LeaseDuration:   15 * time.Second,
+			RenewDeadline:   10 * time.Second,
+			RetryPeriod:     2 * time.Second,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(ctx context.Context) {
+					log.Infof("Acquired leader lease %s/%s", leaderElectionNamespace, leaderElectionID)
+					isLeader.Set(1)
+					runBackgroundTasks(ctx)
+				},
+				OnStoppedLeading: func() {
+					log.Infof("Released leader lease %s/%s", leaderElectionNamespace, leaderElectionID)
+					isLeader.Set(0)
+				},
+				OnNewLeader: func(current string) {
+					if current != identity {
+						log.Infof("Current leader is %s", current)
+					}
+				},
+			},
+		})
+	}
+	return nil
+}
+
+// startBackgroundTasks runs the registered tasks until ctx is cancelled,
+// either directly or behind leader election.
+func startBackgroundTasks(ctx context.Context) {
+	if len(backgroundTasks) == 0 {
+		return
+	}
+
+	if !leaderElect {
+		isLeader.Set(1)
+		runBackgroundTasks(ctx)
+		return
+	}
+
+	client, err := newKubeClient()
+	if err != nil {
+		log.Fatalf("Failed to create Kubernetes client for leader election: %v", err)
+	}
+	if err := runLeaderElection(ctx, client); err != nil {
+		log.Fatalf("Leader election failed: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunBackgroundTasks_StopsOnCancel(t *testing.T) {
+	defer func(tasks []backgroundTask) { backgroundTasks = tasks }(backgroundTasks)
+	backgroundTasks = nil
+
+	started := make(chan string, 2)
+	for _, name := range []string{"a", "b"} {
+		registerBackgroundTask(name, func(ctx context.Context) {
+			started <- name
+			<-ctx.Done()
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runBackgroundTasks(ctx)
+		close(done)
+	}()
+
+	<-started
+	<-started
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected background tasks to stop after cancel")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Admission pressure does not show in CPU when requests queue behind the
+// workers or wait on downstream webhooks, so the webhook exports normalized
+// load gauges to autoscale on instead. Each is 1 at saturation: the
+// utilization when every worker is busy, the latency ratio when requests use
+// their whole apiserver timeout. Gauges are easier to consume than histograms
+// for the Prometheus adapter and KEDA, hence the in-process p95 over the last
+// loadWindow.
+var loadWindow = time.Minute
+
+// loadSamples caps the latency samples kept for the p95.
+const loadSamples = 1024
+
+var (
+	// Gauge for the admission requests in flight per worker
+	loadUtilization = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "grafana_operator_webhook_load_utilization_ratio",
+			Help: "Admission requests queued or being processed divided by the number of workers; above 1 requests are queueing.",
+		},
+		func() float64 { return utilization() },
+	)
+
+	// Gauge for the p95 share of the apiserver timeout consumed recently
+	loadLatency = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "grafana_operator_webhook_load_latency_ratio",
+			Help: "95th percentile of the fraction of the apiserver timeout consumed by admission requests over the last minute.",
+		},
+		func() float64 { return recentBudgets.quantile(0.95, time.Now()) },
+	)
+
+	// Gauge for the higher of the two load ratios, to autoscale on
+	loadRatio = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "grafana_operator_webhook_load_ratio",
+			Help: "The higher of the utilization and latency ratios; 1 means the replica is saturated.",
+		},
+		func() float64 { return math.Max(utilization(), recentBudgets.quantile(0.95, time.Now())) },
+	)
+)
+
+func init() {
+	prometheus.MustRegister(loadUtilization)
+	prometheus.MustRegister(loadLatency)
+	prometheus.MustRegister(loadRatio)
+}
+
+// utilization returns the admission requests in flight per worker.
+func utilization() float64 {
+	if workerCount <= 0 {
+		return 0
+	}
+	return float64(inFlightRequests.Load()) / float64(workerCount)
+}
+
+type budgetSample struct {
+	at    time.Time
+	ratio float64
+}
+
+// budgetWindow keeps the timeout budget consumed by the most recent requests
+// in a ring buffer.
+type budgetWindow struct {
+	mu      sync.Mutex
+	samples [loadSamples]budgetSample
+	next    int
+}
+
+var recentBudgets = &budgetWindow{}
+
+// observe records the budget ratio consumed by a request finished at now.
+func (b *budgetWindow) observe(ratio float64, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.samples[b.next] = budgetSample{at: now, ratio: ratio}
+	b.next = (b.next + 1) % loadSamples
+}
+
+// quantile returns the q quantile of the ratios observed within loadWindow
+// of now, or 0 without any.
+func (b *budgetWindow) quantile(q float64, now time.Time) float64 {
+	cutoff := now.Add(-loadWindow)
+
+	b.mu.Lock()
+	ratios := make([]float64, 0, loadSamples)
+	for _, sample := range b.samples {
+		if sample.at.After(cutoff) {
+			ratios = append(ratios, sample.ratio)
+		}
+	}
+	b.mu.Unlock()
+
+	if len(ratios) == 0 {
+		return 0
+	}
+	sort.Float64s(ratios)
+	rank := int(math.Ceil(q*float64(len(ratios)))) - 1
+	return ratios[max(rank, 0)]
+}
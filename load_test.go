@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBudgetWindow_Quantile(t *testing.T) {
+	b := &budgetWindow{}
+	now := time.Now()
+
+	if got := b.quantile(0.95, now); got != 0 {
+		t.Errorf("Expected 0 without samples, got %v", got)
+	}
+
+	for i := 1; i <= 100; i++ {
+		b.observe(float64(i)/100, now)
+	}
+	if got := b.quantile(0.95, now); got != 0.95 {
+		t.Errorf("Expected a p95 of 0.95, got %v", got)
+	}
+
+	// Samples older than the window no longer count
+	b = &budgetWindow{}
+	b.observe(0.9, now.Add(-2*loadWindow))
+	b.observe(0.1, now)
+	if got := b.quantile(0.95, now); got != 0.1 {
+		t.Errorf("Expected only the recent sample to count, got %v", got)
+	}
+}
+
+func TestBudgetWindow_KeepsMostRecentSamples(t *testing.T) {
+	b := &budgetWindow{}
+	now := time.Now()
+
+	b.observe(1, now)
+	for range loadSamples {
+		b.observe(0.2, now)
+	}
+	if got := b.quantile(1, now); got != 0.2 {
+		t.Errorf("Expected the oldest sample to be overwritten, got %v", got)
+	}
+}
+
+func TestLoadRatio(t *testing.T) {
+	defer func(workers int) { workerCount = workers }(workerCount)
+	defer func(n int64) { inFlightRequests.Store(n) }(inFlightRequests.Load())
+	defer func(b *budgetWindow) { recentBudgets = b }(recentBudgets)
+	workerCount = 4
+	inFlightRequests.Store(6)
+	recentBudgets = &budgetWindow{}
+	recentBudgets.observe(0.5, time.Now())
+
+	if got := testutil.ToFloat64(loadUtilization); got != 1.5 {
+		t.Errorf("Expected a utilization of 1.5, got %v", got)
+	}
+	if got := testutil.ToFloat64(loadLatency); got != 0.5 {
+		t.Errorf("Expected a latency ratio of 0.5, got %v", got)
+	}
+	if got := testutil.ToFloat64(loadRatio); got != 1.5 {
+		t.Errorf("Expected the load ratio to follow the utilization, got %v", got)
+	}
+}
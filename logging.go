@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+type loggerKey struct{}
+
+// withLogger returns a copy of ctx carrying entry as the request logger.
+func withLogger(ctx context.Context, entry *log.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, entry)
+}
+
+// loggerFromContext returns the request logger stored in ctx, or the global
+// logger when there is none.
+func loggerFromContext(ctx context.Context) *log.Entry {
+	if entry, ok := ctx.Value(loggerKey{}).(*log.Entry); ok {
+		return entry
+	}
+	return log.NewEntry(log.StandardLogger())
+}
+
+// requestLogger returns a logger annotated with the identity of req, so every
+// line logged while handling it can be correlated.
+func requestLogger(req *admissionv1.AdmissionRequest) *log.Entry {
+	return log.WithFields(log.Fields{
+		"uid":       req.UID,
+		"kind":      req.Kind.Kind,
+		"namespace": req.Namespace,
+		"name":      req.Name,
+		"operation": req.Operation,
+	})
+}
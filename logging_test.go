@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLoggerFromContext(t *testing.T) {
+	if entry := loggerFromContext(context.Background()); entry == nil || len(entry.Data) != 0 {
+		t.Errorf("Expected a bare global logger without a request logger, got %+v", entry)
+	}
+
+	req := &admissionv1.AdmissionRequest{
+		UID:       "test-uid-logger",
+		Kind:      metav1.GroupVersionKind{Kind: "GrafanaDashboard"},
+		Namespace: "ns",
+		Name:      "dash",
+		Operation: admissionv1.Update,
+	}
+	ctx := withLogger(context.Background(), requestLogger(req))
+
+	entry := loggerFromContext(ctx)
+	for field, expected := range map[string]interface{}{
+		"uid":       req.UID,
+		"kind":      "GrafanaDashboard",
+		"namespace": "ns",
+		"name":      "dash",
+		"operation": admissionv1.Update,
+	} {
+		if entry.Data[field] != expected {
+			t.Errorf("Expected field %s=%v, got %v", field, expected, entry.Data[field])
+		}
+	}
+}
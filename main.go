@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -10,11 +11,17 @@ import (
 	"os"
 	"os/signal"
 	"reflect"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/errdefs"
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/server"
 	admissionv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -69,7 +76,6 @@ func handleAdmissionReview(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var admissionReviewReq admissionv1.AdmissionReview
-	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, "failed to read request body", http.StatusRequestEntityTooLarge)
@@ -78,6 +84,7 @@ func handleAdmissionReview(w http.ResponseWriter, r *http.Request) {
 
 	err = json.Unmarshal(body, &admissionReviewReq)
 	if err != nil {
+		stats.recordError()
 		http.Error(w, "failed to unmarshal request", http.StatusBadRequest)
 		return
 	}
@@ -87,107 +94,302 @@ func handleAdmissionReview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Default AdmissionReview response
+	ctx := withLogger(r.Context(), requestLogger(admissionReviewReq.Request))
+	logger := loggerFromContext(ctx)
+
+	// A retry of an already answered request gets the exact same response
+	if cached, ok := admissionResponses.get(ctx, admissionReviewReq.Request.UID); ok {
+		logger.Debug("Returning cached response")
+		responseCacheHitsTotal.Inc()
+		writeResponse(w, cached)
+		return
+	}
+
 	admissionReviewResp := admissionv1.AdmissionReview{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "admission.k8s.io/v1",
 			Kind:       "AdmissionReview",
 		},
-		Response: &admissionv1.AdmissionResponse{
-			UID:     admissionReviewReq.Request.UID,
-			Allowed: true,
-		},
 	}
 
-	// Only process UPDATE requests for Application CR
-	if admissionReviewReq.Request.Operation != admissionv1.Update || admissionReviewReq.Request.Kind.Kind != "GrafanaDashboard" {
-		sendResponse(w, admissionReviewResp)
-		return
+	var cmp *comparison
+	admissionReviewResp.Response, cmp, err = evaluateRequest(ctx, admissionReviewReq.Request)
+	if errors.Is(err, errdefs.ErrOversizedObject) {
+		recordComplexityGuard(err)
+		admissionReviewResp.Response, err = allowOversized(ctx, admissionReviewReq.Request, err), nil
 	}
-
-	// Parse old and new objects
-	var oldObj, newObj map[string]interface{}
-	err = json.Unmarshal(admissionReviewReq.Request.OldObject.Raw, &oldObj)
 	if err != nil {
-		http.Error(w, "failed to parse old object", http.StatusInternalServerError)
+		stats.recordError()
+		http.Error(w, err.Error(), errdefs.HTTPStatus(err))
 		return
 	}
+	recordUncompared(ctx, admissionReviewReq.Request)
 
-	err = json.Unmarshal(admissionReviewReq.Request.Object.Raw, &newObj)
-	if err != nil {
-		http.Error(w, "failed to parse new object", http.StatusInternalServerError)
-		return
+	if cmp != nil {
+		if cmp.partial {
+			largeObjectFallbackTotal.Inc()
+		}
+		recordIgnoredHits(cmp.ignoredHits)
+		recordRollout(*cmp)
+		objectChangeIndex.record(admissionReviewReq.Request.Namespace, admissionReviewReq.Request.Name, *cmp, time.Now())
+
+		if !cmp.changed() {
+			logger.Debug("No significant differences found.")
+
+			// Increment the counter for unchanged dashboards
+			processedTotal.WithLabelValues("false").Inc()
+		} else {
+			categories := cmp.categories()
+			recordChangeCategories(categories)
+			logger.WithField("categories", strings.Join(categories, ",")).Info("Significant differences found.")
+
+			if cmp.metadataChanged {
+				printMetadataDifferences(ctx, cmp.oldObj, cmp.newObj)
+			}
+			if cmp.specChanged {
+				printSpecDifferences(ctx, cmp.oldObj, cmp.newObj)
+			}
+			if cmp.statusChanged {
+				printStatusDifferences(ctx, cmp.oldObj, cmp.newObj)
+			}
+
+			// Increment the counter for changed dashboards
+			processedTotal.WithLabelValues("true").Inc()
+		}
 	}
 
-	// Remove metadata.managedFields and metadata.generation
-	cleanupMetadata(oldObj)
-	cleanupMetadata(newObj)
+	finalizeResponse(ctx, admissionReviewReq.Request, cmp, body, admissionReviewResp.Response)
+	if auditAnnotations {
+		admissionReviewResp.Response.AuditAnnotations = buildAuditAnnotations(admissionReviewResp.Response, cmp)
+	}
+	if cmp != nil {
+		recordSourceFieldChanges(*cmp, admissionReviewResp.Response.Allowed)
+	}
+	emitDecision(admissionReviewReq.Request, admissionReviewResp.Response, cmp)
+	if mirror != nil {
+		mirror.send(body, admissionReviewResp.Response.Allowed)
+	}
+	decision := newExplainedDecision(admissionReviewReq.Request, admissionReviewResp.Response, cmp, time.Now())
+	lastDecisions.record(admissionReviewReq.Request, decision)
+	if snapshots != nil {
+		snapshots.add(admissionReviewReq.Request, decision)
+	}
+	stats.recordAnswered(admissionReviewResp.Response, cmp)
+	sendResponse(ctx, w, admissionReviewReq.Request.UID, admissionReviewResp)
 
-	// Remove reconciledAt from both old and new objects
-	removeLastResync(oldObj)
-	removeLastResync(newObj)
+	// Record the request duration
+	if cmp != nil {
+		recordRequestDuration(fmt.Sprintf("%t", cmp.changed()), start)
+	}
+}
 
-	metadataChanged := !reflect.DeepEqual(oldObj["metadata"], newObj["metadata"])
-	specChanged := !reflect.DeepEqual(oldObj["spec"], newObj["spec"])
-	statusChanged := !reflect.DeepEqual(oldObj["status"], newObj["status"])
+// evaluateRequest makes the local decision for an admission request without
+// side effects such as metrics. The comparison is nil when the request was
+// allowed without comparing objects. An object too large or complex to
+// compare returns an error wrapping errdefs.ErrOversizedObject, on which the
+// admission handlers fail open with allowOversized.
+func evaluateRequest(ctx context.Context, req *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, *comparison, error) {
+	return evaluateWithFilter(ctx, kindFilter, req)
+}
+
+// evaluateWithFilter is evaluateRequest with filter selecting the requests
+// that are compared.
+func evaluateWithFilter(ctx context.Context, filter admissionFilter, req *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, *comparison, error) {
+	resp := &admissionv1.AdmissionResponse{
+		UID:     req.UID,
+		Allowed: true,
+	}
+
+	// Subresources such as scale are not the object and cannot be compared,
+	// and a kind without a profile means the webhook rules select too much
+	if isPassthroughSubresource(req) || isUnsupportedKind(req) {
+		return resp, nil, nil
+	}
+
+	// Only process the requests selected by the dashboard filter
+	if !filter.matches(req) {
+		return resp, nil, nil
+	}
+
+	// Without both versions there is nothing to diff, so the update is allowed
+	if len(req.OldObject.Raw) == 0 || len(req.Object.Raw) == 0 {
+		loggerFromContext(ctx).Debug("Request lacks the old or new object, skipping comparison")
+		return resp, nil, nil
+	}
 
-	if !metadataChanged && !specChanged && !statusChanged {
-		log.Debug("No significant differences found.")
+	cmp, err := compareWithRollout(ctx, req.Kind.Kind, canaryKey(req), req.OldObject.Raw, req.Object.Raw)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		admissionReviewResp.Response.Allowed = false
-		admissionReviewResp.Response.Result = &metav1.Status{
-			Status:  "Success",
+	// A no-op update is denied with a success status so the client does not
+	// retry, sparing the apiserver and etcd the write.
+	if !cmp.changed() {
+		resp.Allowed = false
+		resp.Result = &metav1.Status{
+			Status:  metav1.StatusSuccess,
 			Message: "Update successful.",
 			Code:    http.StatusOK,
 		}
+	}
+	return resp, &cmp, nil
+}
 
-		// Increment the counter for unchanged apps
-		processedTotal.WithLabelValues("false").Inc()
-	} else {
-		if metadataChanged {
-			printMetadataDifferences(oldObj, newObj)
-		}
-		if specChanged {
-			printSpecDifferences(oldObj, newObj)
-		}
-		if statusChanged {
-			printStatusDifferences(oldObj, newObj)
-		}
-		admissionReviewResp.Response.Allowed = true
+// allowOversized is the response to a request whose objects were too large or
+// complex to compare: an update we cannot afford to diff is let through.
+func allowOversized(ctx context.Context, req *admissionv1.AdmissionRequest, err error) *admissionv1.AdmissionResponse {
+	loggerFromContext(ctx).Warnf("Skipping comparison: %v", err)
+	return &admissionv1.AdmissionResponse{UID: req.UID, Allowed: true}
+}
 
-		// Increment the counter for changed apps
-		processedTotal.WithLabelValues("true").Inc()
+// recordUncompared counts a request allowed without comparison because of
+// its subresource or kind. It is kept out of evaluateRequest so the self-test
+// and the mutating webhook, which sees the same requests, do not count them.
+func recordUncompared(ctx context.Context, req *admissionv1.AdmissionRequest) {
+	switch {
+	case isPassthroughSubresource(req):
+		subresourcePassthroughTotal.WithLabelValues(req.SubResource).Inc()
+		loggerFromContext(ctx).Debugf("Passing through the %s subresource", req.SubResource)
+	case isUnsupportedKind(req):
+		unsupportedKinds.observe(ctx, req, time.Now())
 	}
+}
 
-	sendResponse(w, admissionReviewResp)
+// finalizeResponse applies the decision stages that follow the local
+// comparison: finalizer and owner reference policies, change freezes, the
+// spec change rate limit, embedded JSON validation, downstream webhooks, the
+// external authorizer, the decision mode of the namespace, then the deny-loop
+// backoff. A spec change denied after the rate limit took its token gets the
+// token back, unless maintenance mode admits it anyway. Maintenance mode
+// itself is applied by maintenanceHandler as the response is sent.
+func finalizeResponse(ctx context.Context, req *admissionv1.AdmissionRequest, cmp *comparison, body []byte, resp *admissionv1.AdmissionResponse) {
+	applyFinalizerPolicies(ctx, req, resp)
+	applyOwnerReferencePolicy(ctx, req, resp)
+	applyFreezeWindows(ctx, req, cmp, resp, time.Now())
+	spent := applySpecChangeRateLimit(ctx, req, cmp, resp, time.Now())
+	applyEmbeddedJSONValidation(ctx, cmp, resp)
+	consultDownstreams(ctx, body, resp)
+	applyExternalAuthorizer(ctx, req, cmp, resp)
+	applyDecisionMode(ctx, req.Namespace, resp)
+	applyDenyLoopBackoff(ctx, req, resp)
+	if spent && !resp.Allowed && !maintenanceMode.Load() {
+		refundSpecChange(ctx, req, time.Now())
+	}
+}
 
-	// Record the request duration
-	recordRequestDuration(fmt.Sprintf("%t", metadataChanged || specChanged || statusChanged), start)
+// isNoopDenial reports whether resp is the success-status denial of a no-op
+// update, as opposed to a denial by policy or a downstream webhook.
+// Allow-warn mode and the deny-loop backoff only soften these, so denials by
+// finalizer policies and other checks stay enforced.
+func isNoopDenial(resp *admissionv1.AdmissionResponse) bool {
+	return !resp.Allowed && resp.Result != nil && resp.Result.Status == metav1.StatusSuccess
 }
 
-// Function to remove metadata.managedFields and metadata.generation
-func cleanupMetadata(obj map[string]interface{}) {
-	if metadata, exists := obj["metadata"].(map[string]interface{}); exists {
-		delete(metadata, "managedFields")
-		delete(metadata, "generation")
+// comparison is the outcome of comparing the old and new version of an object
+// once fields that change without user intent have been removed.
+type comparison struct {
+	oldObj, newObj  map[string]interface{}
+	metadataChanged bool
+	specChanged     bool
+	statusChanged   bool
+
+	// partial is set when only hashes of selected paths were compared, in
+	// which case oldObj and newObj are not populated.
+	partial bool
+
+	// ignoredHits lists the ignored paths that were present and removed.
+	ignoredHits []string
+
+	// invalidEmbedded lists the embedded JSON paths whose new value changed
+	// and is not a JSON object.
+	invalidEmbedded []string
+
+	// variant is the ruleset variant used, and diverged is set when a canary
+	// comparison reached a different decision than the stable ruleset.
+	variant  string
+	diverged bool
+}
+
+// changed reports whether any significant difference was found.
+func (c comparison) changed() bool {
+	return c.metadataChanged || c.specChanged || c.statusChanged
+}
+
+// changedSections lists the top-level sections that differ.
+func (c comparison) changedSections() []string {
+	sections := []string{}
+	if c.metadataChanged {
+		sections = append(sections, "metadata")
+	}
+	if c.specChanged {
+		sections = append(sections, "spec")
 	}
+	if c.statusChanged {
+		sections = append(sections, "status")
+	}
+	return sections
 }
 
-// Helper function to remove lastResync from an object
-func removeLastResync(obj map[string]interface{}) {
-	if status, exists := obj["status"].(map[string]interface{}); exists {
-		delete(status, "lastResync")
+// compareObjects parses the raw old and new objects, removes the fields the
+// ruleset ignores and reports which top-level sections differ.
+func compareObjects(ctx context.Context, rules ruleset, oldRaw, newRaw []byte) (comparison, error) {
+	if isLargeObject(oldRaw, newRaw) {
+		return compareLargeObjects(ctx, rules, oldRaw, newRaw)
 	}
+
+	var cmp comparison
+	for _, raw := range [][]byte{oldRaw, newRaw} {
+		if err := checkComplexity(raw); err != nil {
+			return cmp, err
+		}
+	}
+
+	if err := json.Unmarshal(oldRaw, &cmp.oldObj); err != nil {
+		return cmp, fmt.Errorf("%w: failed to parse old object: %w", errdefs.ErrMalformedReview, err)
+	}
+	if err := json.Unmarshal(newRaw, &cmp.newObj); err != nil {
+		return cmp, fmt.Errorf("%w: failed to parse new object: %w", errdefs.ErrMalformedReview, err)
+	}
+
+	// Compare embedded documents by content rather than as strings, so the
+	// ignore paths can also reach into them
+	cmp.invalidEmbedded = expandEmbeddedJSON(embeddedJSONPaths, cmp.oldObj, cmp.newObj)
+
+	// Remove the ignored paths from both old and new objects
+	cmp.ignoredHits = rules.compiledIgnorePaths().apply(nil, cmp.oldObj, cmp.newObj)
+	removeChangeAnnotation(cmp.oldObj, cmp.newObj)
+
+	cmp.metadataChanged = !reflect.DeepEqual(cmp.oldObj["metadata"], cmp.newObj["metadata"])
+	cmp.specChanged = !reflect.DeepEqual(cmp.oldObj["spec"], cmp.newObj["spec"])
+	cmp.statusChanged = !reflect.DeepEqual(cmp.oldObj["status"], cmp.newObj["status"])
+	return cmp, nil
 }
 
-func sendResponse(w http.ResponseWriter, admissionReviewResp admissionv1.AdmissionReview) {
+func sendResponse(ctx context.Context, w http.ResponseWriter, uid types.UID, admissionReviewResp admissionv1.AdmissionReview) {
+	if err := validateAdmissionReview(uid, admissionReviewResp); err != nil {
+		responseContractViolationsTotal.Inc()
+		loggerFromContext(ctx).Errorf("Invalid admission response: %v", err)
+		if strictResponses {
+			http.Error(w, "invalid admission response", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	responseBytes, err := json.Marshal(admissionReviewResp)
 	if err != nil {
-		log.Errorf("Failed to marshal admission response: %v", err)
+		loggerFromContext(ctx).Errorf("Failed to marshal admission response: %v", err)
 		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
 		return
 	}
 
+	if admissionReviewResp.Response != nil {
+		admissionResponses.put(ctx, admissionReviewResp.Response.UID, responseBytes)
+	}
+
+	writeResponse(w, responseBytes)
+}
+
+func writeResponse(w http.ResponseWriter, responseBytes []byte) {
 	w.Header().Set("Content-Type", "application/json")
 	if _, err := w.Write(responseBytes); err != nil {
 		log.Errorf("Failed to write admission response: %v", err)
@@ -201,47 +403,49 @@ func recordRequestDuration(status string, start time.Time) {
 }
 
 // Function to log metadata differences
-func printMetadataDifferences(oldObj, newObj map[string]interface{}) {
+func printMetadataDifferences(ctx context.Context, oldObj, newObj map[string]interface{}) {
 	oldMetadata, _ := oldObj["metadata"].(map[string]interface{})
 	newMetadata, _ := newObj["metadata"].(map[string]interface{})
-	printDifferences("Metadata", oldMetadata, newMetadata)
+	printDifferences(ctx, "Metadata", oldMetadata, newMetadata)
 }
 
 // Function to log spec differences
-func printSpecDifferences(oldObj, newObj map[string]interface{}) {
+func printSpecDifferences(ctx context.Context, oldObj, newObj map[string]interface{}) {
 	oldSpec, _ := oldObj["spec"].(map[string]interface{})
 	newSpec, _ := newObj["spec"].(map[string]interface{})
-	printDifferences("Spec", oldSpec, newSpec)
+	printDifferences(ctx, "Spec", oldSpec, newSpec)
 }
 
 // Function to log status differences
-func printStatusDifferences(oldObj, newObj map[string]interface{}) {
+func printStatusDifferences(ctx context.Context, oldObj, newObj map[string]interface{}) {
 	oldStatus, _ := oldObj["status"].(map[string]interface{})
 	newStatus, _ := newObj["status"].(map[string]interface{})
-	printDifferences("Status", oldStatus, newStatus)
+	printDifferences(ctx, "Status", oldStatus, newStatus)
 }
 
 // Function to print differences between two objects
-func printDifferences(owner string, oldMap, newMap map[string]interface{}) {
+func printDifferences(ctx context.Context, owner string, oldMap, newMap map[string]interface{}) {
 	if oldMap == nil && newMap == nil {
 		return
 	}
 
-	log.Debug("----- ", owner, " Differences -----")
+	logger := loggerFromContext(ctx)
+
+	logger.Debug("----- ", owner, " Differences -----")
 
 	for key, oldValue := range oldMap {
 		if newValue, exists := newMap[key]; exists {
 			if !reflect.DeepEqual(oldValue, newValue) {
-				log.Debugf("Key: %s\n  Old Value: %v\n  New Value: %v\n", key, oldValue, newValue)
+				logger.Debugf("Key: %s\n  Old Value: %v\n  New Value: %v\n", key, oldValue, newValue)
 			}
 		} else {
-			log.Debugf("Key removed: %s (Old Value: %v)", key, oldValue)
+			logger.Debugf("Key removed: %s (Old Value: %v)", key, oldValue)
 		}
 	}
 
 	for key, newValue := range newMap {
 		if _, exists := oldMap[key]; !exists {
-			log.Debugf("Key added: %s (New Value: %v)", key, newValue)
+			logger.Debugf("Key added: %s (New Value: %v)", key, newValue)
 		}
 	}
 }
@@ -250,48 +454,333 @@ func main() {
 	port := flag.String("port", "8443", "Webhook server port")
 	logLevel := flag.String("log-level", "info", "Log level (debug, info, warn, error, fatal, panic)")
 	flag.Int64Var(&maxRequestBodyBytes, "max-request-body-bytes", maxRequestBodyBytes, "Maximum accepted request body size in bytes")
+	flag.DurationVar(&responseCacheTTL, "response-cache-ttl", responseCacheTTL, "How long to reuse the response for a retried admission request UID (0 disables)")
+	flag.IntVar(&workerCount, "workers", workerCount, "Number of workers processing admission requests")
+	flag.IntVar(&queueSize, "queue-size", queueSize, "Maximum number of admission requests waiting for a worker")
+	flag.BoolVar(&leaderElect, "leader-elect", leaderElect, "Run background tasks only on the replica holding the leader lease")
+	flag.StringVar(&leaderElectionNamespace, "leader-election-namespace", leaderElectionNamespace, "Namespace of the leader election lease")
+	flag.StringVar(&leaderElectionID, "leader-election-id", leaderElectionID, "Name of the leader election lease")
+	flag.BoolVar(&registerWebhook, "register-webhook", registerWebhook, "Create the ValidatingWebhookConfiguration and restore it whenever it drifts")
+	flag.StringVar(&webhookConfigName, "webhook-config-name", webhookConfigName, "Name of the ValidatingWebhookConfiguration to manage")
+	flag.StringVar(&webhookServiceName, "webhook-service-name", webhookServiceName, "Name of the Service fronting the webhook")
+	flag.StringVar(&webhookServiceNamespace, "webhook-service-namespace", webhookServiceNamespace, "Namespace of the Service fronting the webhook")
+	flag.StringVar(&webhookCAFile, "webhook-ca-file", webhookCAFile, "Path to the CA bundle the apiserver uses to verify the webhook")
+	timeoutSeconds := flag.Int("webhook-timeout-seconds", int(webhookTimeoutSeconds), "Timeout the apiserver applies to webhook calls, used for registration and timeout budget metrics")
+	flag.DurationVar(&webhookReconcileInterval, "webhook-reconcile-interval", webhookReconcileInterval, "How often to check the ValidatingWebhookConfiguration for drift")
+	flag.IntVar(&largeObjectThresholdBytes, "large-object-threshold-bytes", largeObjectThresholdBytes, "Objects larger than this are compared by hashing --large-object-paths only (0 disables)")
+	largeObjectPathList := flag.String("large-object-paths", strings.Join(largeObjectPaths, ","), "Comma-separated dot paths hashed and compared for large objects, besides their metadata")
+	flag.DurationVar(&changeIndexWindow, "change-index-window", changeIndexWindow, "How far back /debug/objects counts spec and status changes")
+	flag.Var(&downstreamURLs, "downstream-url", "URL of a downstream validating webhook consulted for locally allowed requests (repeatable)")
+	flag.StringVar(&downstreamCAFile, "downstream-ca-file", downstreamCAFile, "Path to a CA bundle for verifying downstream webhooks")
+	flag.DurationVar(&downstreamTimeout, "downstream-timeout", downstreamTimeout, "Timeout for each downstream webhook call")
+	flag.StringVar(&downstreamFailurePolicy, "downstream-failure-policy", downstreamFailurePolicy, "How to treat unreachable downstream webhooks (Ignore or Fail)")
+	maintenance := flag.Bool("maintenance-mode", false, "Allow every request while still logging the decision that would have been made")
+	embeddedJSONPathList := flag.String("embedded-json-paths", strings.Join(embeddedJSONPaths, ","), "Comma-separated dot paths of string fields holding JSON documents compared by content")
+	flag.BoolVar(&rejectInvalidEmbeddedJSON, "reject-invalid-embedded-json", rejectInvalidEmbeddedJSON, "Deny updates that change an embedded JSON document into something that is not a JSON object")
+	ignoredPathList := flag.String("ignore-paths", strings.Join(ignoredPaths, ","), "Comma-separated dot paths removed from both objects before comparing them")
+	flag.StringVar(&rulesFile, "rules-file", rulesFile, "JSON file with per-kind rules merged over the defaults")
+	flag.StringVar(&decisionMode, "decision-mode", decisionMode, "How no-op updates are handled: deny or allow-warn")
+	flag.BoolVar(&namespaceModeOverrides, "namespace-mode-overrides", namespaceModeOverrides, "Let the "+namespaceModeAnnotation+" namespace annotation override the decision mode")
+	flag.StringVar(&canaryRulesFile, "canary-rules-file", canaryRulesFile, "JSON file with per-kind rules applied only to the canary share of requests")
+	flag.IntVar(&canaryPercent, "canary-percent", canaryPercent, "Percentage of objects, by UID, evaluated with the canary rules")
+	flag.BoolVar(&chaosMode, "chaos-mode", chaosMode, "Inject latency and errors into admission requests (testing only)")
+	flag.IntVar(&chaosLatencyPercent, "chaos-latency-percent", chaosLatencyPercent, "Percentage of requests delayed in chaos mode")
+	flag.DurationVar(&chaosLatency, "chaos-latency", chaosLatency, "Delay injected in chaos mode")
+	flag.IntVar(&chaosErrorPercent, "chaos-error-percent", chaosErrorPercent, "Percentage of requests failed in chaos mode")
+	flag.IntVar(&chaosErrorStatus, "chaos-error-status", chaosErrorStatus, "HTTP status returned for failed requests in chaos mode")
+	flag.BoolVar(&auditAnnotations, "audit-annotations", auditAnnotations, "Explain each decision in apiserver audit annotations")
+	flag.BoolVar(&auditPatch, "audit-patch", auditPatch, "Add the normalized JSON patch of each significant change, up to 4 KiB, to the audit annotations")
+	flag.BoolVar(&strictResponses, "strict-responses", strictResponses, "Fail requests whose AdmissionReview response fails validation instead of sending it")
+	flag.IntVar(&denyLoopThreshold, "deny-loop-threshold", denyLoopThreshold, "Denials of one object within the deny-loop window after which its updates are allowed with a warning (0 disables)")
+	flag.DurationVar(&denyLoopWindow, "deny-loop-window", denyLoopWindow, "Window in which repeated denials of one object are counted")
+	flag.DurationVar(&denyLoopCooldown, "deny-loop-cooldown", denyLoopCooldown, "How long a repeatedly denied object is allowed with a warning")
+	flag.Var(&disabledMetrics, "disable-metric", "Metric name not to expose, or a prefix ending in * (repeatable)")
+	flag.Var(&droppedMetricLabels, "drop-metric-label", "Label to drop from every metric, or metric:label for one metric; affected series are aggregated (repeatable)")
+	flag.IntVar(&maxObjectDepth, "max-object-depth", maxObjectDepth, "Maximum JSON nesting depth of a compared object; deeper objects are allowed without comparison (0 disables)")
+	flag.IntVar(&maxObjectKeys, "max-object-keys", maxObjectKeys, "Maximum number of keys in a compared object; larger objects are allowed without comparison (0 disables)")
+	flag.BoolVar(&verifyOwnerReferences, "verify-owner-references", verifyOwnerReferences, "Deny updates that add or change owner references to owners that do not exist")
+	flag.DurationVar(&ownerLookupTTL, "owner-lookup-ttl", ownerLookupTTL, "How long owner lookups are cached")
+	flag.StringVar(&freezeWindowsFile, "freeze-windows-file", freezeWindowsFile, "JSON file defining change freezes during which spec changes are denied")
+	flag.IntVar(&specChangeRateLimit, "spec-change-rate-limit", specChangeRateLimit, "Maximum spec changes admitted per object per rate window (0 disables)")
+	flag.DurationVar(&specChangeRateWindow, "spec-change-rate-window", specChangeRateWindow, "Window of the spec change rate limit")
+	flag.StringVar(&stateStoreURL, "state-store", stateStoreURL, "Where to keep the response cache, deny-loop counters and spec change buckets: memory://, bolt:///path or redis://host:port/db")
+	flag.StringVar(&authorizerAddress, "authorizer-address", authorizerAddress, "gRPC address of an external authorizer consulted for significant changes")
+	flag.StringVar(&authorizerCAFile, "authorizer-ca-file", authorizerCAFile, "Path to a CA bundle for verifying the external authorizer")
+	flag.BoolVar(&authorizerPlaintext, "authorizer-plaintext", authorizerPlaintext, "Connect to the external authorizer without TLS")
+	flag.DurationVar(&authorizerTimeout, "authorizer-timeout", authorizerTimeout, "Timeout for each external authorizer call")
+	flag.StringVar(&authorizerFailurePolicy, "authorizer-failure-policy", authorizerFailurePolicy, "How to treat an unreachable external authorizer (Ignore or Fail)")
+	flag.DurationVar(&authorizerCacheTTL, "authorizer-cache-ttl", authorizerCacheTTL, "How long to cache external authorizer decisions (0 disables the cache)")
+	flag.StringVar(&rulesURL, "rules-url", rulesURL, "HTTPS endpoint serving per-kind rules merged over the rules file")
+	flag.StringVar(&rulesCAFile, "rules-ca-file", rulesCAFile, "Path to a CA bundle for verifying the rules URL")
+	flag.BoolVar(&rulesAllowHTTP, "rules-allow-http", rulesAllowHTTP, "Allow a plain HTTP rules URL, for testing only")
+	flag.DurationVar(&rulesPollInterval, "rules-poll-interval", rulesPollInterval, "How often to poll the rules URL")
+	flag.StringVar(&rulesCacheFile, "rules-cache-file", rulesCacheFile, "File caching the last rules fetched from the rules URL")
+	flag.BoolVar(&ignoredFieldMetrics, "ignored-field-metrics", ignoredFieldMetrics, "Export per-path ignored field hit counts as Prometheus metrics")
+	flag.DurationVar(&tcpKeepAlive, "tcp-keep-alive", tcpKeepAlive, "Interval between TCP keep-alive probes on client connections (negative disables)")
+	flag.DurationVar(&idleTimeout, "idle-timeout", idleTimeout, "How long an idle client connection is kept open")
+	flag.Int64Var(&maxRequestsPerConn, "max-requests-per-connection", maxRequestsPerConn, "Admission requests after which a client connection is recycled, with a GOAWAY on HTTP/2, so connections spread over new replicas (0 disables)")
+	flag.Int64Var(&memorySoftLimitBytes, "memory-soft-limit-bytes", memorySoftLimitBytes, "Heap size above which memory is freed (0 disables)")
+	flag.Int64Var(&memoryHardLimitBytes, "memory-hard-limit-bytes", memoryHardLimitBytes, "Heap size above which the webhook stops reporting ready and restarts gracefully (0 disables)")
+	flag.DurationVar(&memoryCheckInterval, "memory-check-interval", memoryCheckInterval, "How often the heap is checked against the memory limits")
+	flag.DurationVar(&memoryDrainTimeout, "memory-drain-timeout", memoryDrainTimeout, "How long in-flight requests may take to finish before a restart at the hard memory limit")
+	flag.StringVar(&changeAnnotation, "change-annotation", changeAnnotation, "Annotation stamped with a hash and timestamp of each significant change by /mutate, e.g. grafana-operator-webhook/last-change")
+	flag.StringVar(&snapshotBucketURL, "snapshot-bucket", snapshotBucketURL, "Bucket receiving decision snapshots with diffs, e.g. s3://bucket/prefix?region=eu-west-1, s3://bucket?endpoint=http://minio:9000 or gs://bucket")
+	flag.StringVar(&snapshotCluster, "snapshot-cluster", snapshotCluster, "Cluster name used to partition snapshot keys")
+	flag.DurationVar(&snapshotInterval, "snapshot-interval", snapshotInterval, "How often buffered decisions are uploaded as a snapshot")
+	flag.IntVar(&snapshotMaxRecords, "snapshot-max-records", snapshotMaxRecords, "Maximum number of decisions buffered between snapshots")
+	flag.StringVar(&mirrorURL, "mirror-url", mirrorURL, "URL of a staging webhook receiving a sampled copy of every admission request, fire and forget")
+	flag.StringVar(&mirrorCAFile, "mirror-ca-file", mirrorCAFile, "Path to a CA bundle for verifying the staging webhook")
+	flag.IntVar(&mirrorSamplePercent, "mirror-sample-percent", mirrorSamplePercent, "Percentage of admission requests mirrored to the staging webhook")
+	flag.DurationVar(&mirrorTimeout, "mirror-timeout", mirrorTimeout, "Timeout for each mirrored request")
+	flag.DurationVar(&shutdownDrainTimeout, "shutdown-drain-timeout", shutdownDrainTimeout, "Time allowed for in-flight requests to finish on shutdown")
+	flag.DurationVar(&shutdownFlushTimeout, "shutdown-flush-timeout", shutdownFlushTimeout, "Time allowed for buffered telemetry to be flushed on shutdown, after the drain")
+	flag.StringVar(&adminTokenFile, "admin-token-file", adminTokenFile, "Path to a file holding the bearer token required by the admin endpoints, which are disabled without it")
+	flag.StringVar(&otlpLogsEndpoint, "otlp-logs-endpoint", otlpLogsEndpoint, "OTLP/HTTP logs endpoint receiving every decision, e.g. http://otel-collector:4318/v1/logs")
+	flag.IntVar(&otlpBatchSize, "otlp-batch-size", otlpBatchSize, "Maximum number of decision records per OTLP export")
+	flag.DurationVar(&otlpFlushInterval, "otlp-flush-interval", otlpFlushInterval, "Maximum time decision records wait before being exported")
+	objectSelector := flag.String("object-selector", "", "Label selector restricting which dashboards are evaluated, also used when registering the webhook")
+	flag.StringVar(&tlsCertFile, "tls-cert-file", tlsCertFile, "Path to the TLS certificate file")
+	flag.StringVar(&tlsKeyFile, "tls-key-file", tlsKeyFile, "Path to the TLS private key file")
 	flag.Parse()
 
+	largeObjectPaths = strings.Split(*largeObjectPathList, ",")
+	ignoredPaths = strings.Split(*ignoredPathList, ",")
+	embeddedJSONPaths = nil
+	if *embeddedJSONPathList != "" {
+		embeddedJSONPaths = strings.Split(*embeddedJSONPathList, ",")
+	}
+
+	config, err := validateConfig(startupFlags{
+		logLevel:       *logLevel,
+		port:           *port,
+		timeoutSeconds: *timeoutSeconds,
+		objectSelector: *objectSelector,
+	})
+	var configErr *configError
+	if errors.As(err, &configErr) {
+		log.WithField("problems", configErr.problems).Fatalf("Invalid configuration: %v", err)
+	}
+	log.SetLevel(config.logLevel)
+	webhookTimeoutSeconds = int32(*timeoutSeconds)
+
+	setRuleLayer(ruleSourceDefaults, defaultRuleLayer())
+	if config.rules != nil {
+		setRuleLayer(ruleSourceFile, config.rules)
+	}
+	if config.canaryRules != nil {
+		setRuleLayer(ruleSourceCanary, config.canaryRules)
+	}
+	var remote *remoteRules
+	if rulesURL != "" {
+		var err error
+		if remote, err = newRemoteRules(rulesURL, rulesCAFile, rulesCacheFile); err != nil {
+			log.Fatalf("Failed to configure remote rules: %v", err)
+		}
+		if err := remote.load(context.Background()); err != nil {
+			log.Fatalf("Failed to load remote rules: %v", err)
+		}
+	}
+
+	kindFilter.objectSelector = config.objectSelector
+	freezes = config.freezes
+
+	setMaintenanceMode(*maintenance)
+
+	if namespaceModeOverrides {
+		client, err := newKubeClient()
+		if err != nil {
+			log.Fatalf("Failed to create Kubernetes client: %v", err)
+		}
+		if err := startNamespaceInformer(context.Background(), client); err != nil {
+			log.Fatalf("Failed to start namespace informer: %v", err)
+		}
+	}
+
+	if otlpLogsEndpoint != "" {
+		decisions = newDecisionExporter(otlpLogsEndpoint)
+	}
+
+	if config.snapshotBucket != nil {
+		snapshots = newSnapshotExporter(config.snapshotBucket, snapshotCluster)
+		go snapshots.run(snapshotInterval)
+	}
+
+	if mirrorURL != "" {
+		mirror, err = newRequestMirror(mirrorURL, mirrorCAFile)
+		if err != nil {
+			log.Fatalf("Invalid mirror configuration: %v", err)
+		}
+	}
+
+	if err := configureDownstreams(downstreamCAFile); err != nil {
+		log.Fatalf("Invalid downstream webhook configuration: %v", err)
+	}
+
+	if authorizerAddress != "" {
+		if err := configureAuthorizer(); err != nil {
+			log.Fatalf("Invalid external authorizer configuration: %v", err)
+		}
+		defer externalAuthorizer.Close()
+	}
+
+	if verifyOwnerReferences {
+		kubeConfig, err := newKubeConfig()
+		if err != nil {
+			log.Fatalf("Failed to create Kubernetes client: %v", err)
+		}
+		disc, err := discovery.NewDiscoveryClientForConfig(kubeConfig)
+		if err != nil {
+			log.Fatalf("Failed to create discovery client: %v", err)
+		}
+		dyn, err := dynamic.NewForConfig(kubeConfig)
+		if err != nil {
+			log.Fatalf("Failed to create dynamic client: %v", err)
+		}
+		owners = newOwnerLookup(disc, dyn)
+	}
+
+	stateStore, err := openStateStore()
+	if err != nil {
+		log.Fatalf("Failed to open state store: %v", err)
+	}
+	defer stateStore.Close()
+
+	metricsGatherer, err := newFilteringGatherer(prometheus.DefaultGatherer, disabledMetrics, droppedMetricLabels)
+	if err != nil {
+		log.Fatalf("Invalid metrics settings: %v", err)
+	}
+
+	tlsConfig, err := loadTLSConfig(tlsCertFile, tlsKeyFile)
+	if err != nil {
+		log.Fatalf("Failed to load TLS certificate: %v", err)
+	}
+
 	addr := fmt.Sprintf(":%s", *port)
 	srv := &http.Server{
 		Addr:              addr,
 		Handler:           http.DefaultServeMux,
+		TLSConfig:         tlsConfig,
+		ErrorLog:          newServerErrorLog(),
 		ReadHeaderTimeout: 5 * time.Second,
 		ReadTimeout:       10 * time.Second,
 		WriteTimeout:      10 * time.Second,
-		IdleTimeout:       60 * time.Second,
+		IdleTimeout:       idleTimeout,
+		ConnContext:       server.CountConnRequests,
+		ConnState:         trackConnState,
 	}
 
-	level, err := log.ParseLevel(*logLevel)
-	if err != nil {
-		log.Fatalf("Invalid log level: %s", *logLevel)
+	// The self-test also warms up the compiled rules before the first request
+	if err := runSelfTest(); err != nil {
+		log.Errorf("Self-test failed, not reporting ready: %v", err)
+	} else {
+		log.Info("Self-test passed")
+		ready.Store(true)
 	}
-	log.SetLevel(level)
+
+	// Readiness endpoint
+	http.HandleFunc("/readyz", handleReadyz)
 
 	// Metrics endpoint
-	http.Handle("/metrics", promhttp.Handler())
+	http.Handle("/metrics", promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer, promhttp.HandlerFor(metricsGatherer, promhttp.HandlerOpts{}),
+	))
 
 	// Webhook handler
-	http.HandleFunc("/validate", handleAdmissionReview)
+	middleware := newMiddlewareChain()
+	var admissionHandler http.Handler = http.HandlerFunc(handleAdmissionReview)
+	if chaosMode {
+		admissionHandler = newChaosHandler(admissionHandler)
+	}
+	pool := newWorkerPool(workerCount, queueSize, middleware.Then(admissionHandler))
+	http.Handle("/validate", maintenanceHandler(pool))
+
+	// Change annotation for a mutating webhook
+	if changeAnnotation != "" {
+		http.Handle("/mutate", middleware.ThenFunc(handleMutate))
+	}
+
+	// Batch validation endpoint for CI pipelines
+	http.Handle("/validate-batch", middleware.ThenFunc(handleValidateBatch))
+
+	// Dry-run evaluation API
+	http.Handle("/api/v1/evaluate", middleware.ThenFunc(handleEvaluate))
+
+	// Policy documentation for app teams
+	http.HandleFunc("/policies", handlePolicies)
+
+	// Admin endpoints, only behind a token
+	if config.adminToken != "" {
+		registerAdminHandlers(http.DefaultServeMux, newAdminChain(middleware, config.adminToken))
+	} else {
+		log.Info("Admin endpoints disabled, set --admin-token-file to enable them")
+	}
+
+	// CRD conversion webhook
+	http.Handle("/convert", middleware.ThenFunc(handleConversionReview))
+	listener, err := listen(addr)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", addr, err)
+	}
 	log.Infof("Starting webhook server on %s...", addr)
 
 	go func() {
-		if err := srv.ListenAndServeTLS("/certs/tls.crt", "/certs/tls.key"); err != nil && err != http.ErrServerClosed {
+		if err := srv.ServeTLS(listener, "", ""); err != nil && err != http.ErrServerClosed {
 			log.Fatal("Failed to start webhook server:", err)
 		}
 	}()
 
+	if registerWebhook {
+		client, err := newKubeClient()
+		if err != nil {
+			log.Fatalf("Failed to create Kubernetes client: %v", err)
+		}
+		registerBackgroundTask("webhook-config-reconciler", func(ctx context.Context) {
+			runWebhookConfigReconciler(ctx, client)
+		})
+	}
+
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	backgroundDone := make(chan struct{})
+	go func() {
+		defer close(backgroundDone)
+		startBackgroundTasks(backgroundCtx)
+	}()
+	if remote != nil {
+		go remote.poll(backgroundCtx, rulesPollInterval)
+	}
+
+	dumpConfigOnSignal()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	if memorySoftLimitBytes > 0 || memoryHardLimitBytes > 0 {
+		guard := newMemoryGuard(memorySoftLimitBytes, memoryHardLimitBytes, func() {
+			select {
+			case quit <- syscall.SIGTERM:
+			default:
+			}
+		})
+		go guard.run(backgroundCtx, memoryCheckInterval)
+	}
 	<-quit
 
 	log.Info("Shutting down server...")
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	stopBackground()
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), shutdownDrainTimeout)
+	defer cancelDrain()
 
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("Server forced to shutdown:", err)
+	if err := srv.Shutdown(drainCtx); err != nil {
+		log.Errorf("Server forced to shutdown: %v", err)
+		srv.Close()
 	}
 
+	// Handlers cut off by a forced close may still run; the pool and the
+	// exporters drop what they submit from here on.
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), shutdownFlushTimeout)
+	defer cancelFlush()
+	pool.stop(flushCtx)
+	flushTelemetry(flushCtx, stateStore)
+	<-backgroundDone
+
 	log.Info("Server exiting")
 }
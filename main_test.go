@@ -10,6 +10,7 @@ import (
 	admissionv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 func TestWebhookOperationHandler(t *testing.T) {
@@ -66,9 +67,7 @@ func TestWebhookOperationHandler(t *testing.T) {
 				t.Fatalf("Expected a response, got nil")
 			}
 
-			if admissionResp.Response.UID != reqBody.Request.UID {
-				t.Errorf("Expected UID %s, got %s", reqBody.Request.UID, admissionResp.Response.UID)
-			}
+			assertValidAdmissionReview(t, reqBody.Request.UID, admissionResp)
 
 			if !admissionResp.Response.Allowed {
 				t.Errorf("Expected response to be allowed, but it was denied")
@@ -113,12 +112,12 @@ func TestHandleAdmissionReview_MethodNotAllowed(t *testing.T) {
 
 func TestHandleAdmissionReview_BodyTooLarge(t *testing.T) {
 	// A body exceeding maxRequestBodyBytes must be rejected rather than
-	// read fully into memory.
+	// read fully into memory. The limit is applied by the middleware chain.
 	oversized := bytes.Repeat([]byte("a"), int(maxRequestBodyBytes)+1)
 	req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(oversized))
 	w := httptest.NewRecorder()
 
-	handleAdmissionReview(w, req)
+	newMiddlewareChain().ThenFunc(handleAdmissionReview).ServeHTTP(w, req)
 
 	resp := w.Result()
 	defer resp.Body.Close()
@@ -169,9 +168,7 @@ func TestHandleAdmissionReview_StatusSyncRevisionChange(t *testing.T) {
 		t.Fatalf("Expected a response, got nil")
 	}
 
-	if admissionResp.Response.UID != reqBody.Request.UID {
-		t.Errorf("Expected UID %s, got %s", reqBody.Request.UID, admissionResp.Response.UID)
-	}
+	assertValidAdmissionReview(t, reqBody.Request.UID, admissionResp)
 
 	if !admissionResp.Response.Allowed {
 		t.Errorf("Expected response to be allowed, but it was denied")
@@ -219,11 +216,73 @@ func TestHandleAdmissionReview_StatusReconciledAtChange(t *testing.T) {
 		t.Fatalf("Expected a response, got nil")
 	}
 
-	if admissionResp.Response.UID != reqBody.Request.UID {
-		t.Errorf("Expected UID %s, got %s", reqBody.Request.UID, admissionResp.Response.UID)
-	}
+	assertValidAdmissionReview(t, reqBody.Request.UID, admissionResp)
 
 	if admissionResp.Response.Allowed {
 		t.Errorf("Expected response to be denied, but it was allowed")
 	}
 }
+
+func TestHandleAdmissionReview_MissingObjects(t *testing.T) {
+	object := []byte(`{"metadata": {}, "spec": {}, "status": {}}`)
+
+	tests := []struct {
+		name            string
+		oldObject       []byte
+		object          []byte
+		expectedAllowed bool
+	}{
+		{"both present", object, object, false},
+		{"old absent", nil, object, true},
+		{"new absent", object, nil, true},
+		{"both absent", nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqBody := admissionv1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "admission.k8s.io/v1",
+					Kind:       "AdmissionReview",
+				},
+				Request: &admissionv1.AdmissionRequest{
+					UID:       types.UID("test-uid-missing-objects-" + tt.name),
+					Kind:      metav1.GroupVersionKind{Kind: "GrafanaDashboard"},
+					Operation: admissionv1.Update,
+					OldObject: runtime.RawExtension{Raw: tt.oldObject},
+					Object:    runtime.RawExtension{Raw: tt.object},
+				},
+			}
+
+			reqBytes, err := json.Marshal(reqBody)
+			if err != nil {
+				t.Fatalf("Failed to marshal request: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(reqBytes))
+			w := httptest.NewRecorder()
+
+			handleAdmissionReview(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Expected status code 200, got %d", resp.StatusCode)
+			}
+
+			var admissionResp admissionv1.AdmissionReview
+			if err := json.NewDecoder(resp.Body).Decode(&admissionResp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if admissionResp.Response == nil {
+				t.Fatalf("Expected a response, got nil")
+			}
+
+			if admissionResp.Response.Allowed != tt.expectedAllowed {
+				t.Errorf("Expected allowed=%t, got %t", tt.expectedAllowed, admissionResp.Response.Allowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maintenanceWarning is returned to clients while maintenance mode is on.
+const maintenanceWarning = "grafana-operator-webhook is in maintenance mode, the update was allowed without filtering"
+
+// maintenanceMode makes the webhook allow every request while still logging
+// the decision it would have made. It is set by --maintenance-mode and can be
+// toggled at runtime through /maintenance.
+var maintenanceMode atomic.Bool
+
+var (
+	// Gauge reporting whether maintenance mode is enabled
+	maintenanceModeEnabled = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "grafana_operator_webhook_maintenance_mode",
+			Help: "Whether maintenance mode is enabled (1) or not (0).",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(maintenanceModeEnabled)
+}
+
+func setMaintenanceMode(enabled bool) {
+	if maintenanceMode.Swap(enabled) != enabled {
+		log.Warnf("Maintenance mode set to %t", enabled)
+	}
+	if enabled {
+		maintenanceModeEnabled.Set(1)
+	} else {
+		maintenanceModeEnabled.Set(0)
+	}
+}
+
+// maintenanceHandler applies maintenance mode to /validate where the response
+// is sent, so it covers every answer: a fresh decision, a cached one, a
+// request the worker pool rejects and a request that fails. Failures are
+// answered with an allow for the request UID. A body that is not an
+// AdmissionReview has no UID to answer for and keeps its error.
+func maintenanceHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !maintenanceMode.Load() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var admissionReviewReq admissionv1.AdmissionReview
+		if err := json.Unmarshal(body, &admissionReviewReq); err != nil || admissionReviewReq.Request == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := withLogger(r.Context(), requestLogger(admissionReviewReq.Request))
+
+		recorder := newBufferedResponse()
+		next.ServeHTTP(recorder, r)
+
+		var review admissionv1.AdmissionReview
+		if recorder.status != http.StatusOK || json.Unmarshal(recorder.body.Bytes(), &review) != nil || review.Response == nil {
+			review = admissionv1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Response: &admissionv1.AdmissionResponse{
+					UID: admissionReviewReq.Request.UID,
+					Result: &metav1.Status{
+						Message: strings.TrimSpace(recorder.body.String()),
+						Code:    int32(recorder.status),
+					},
+				},
+			}
+		}
+		applyMaintenanceMode(ctx, review.Response)
+
+		responseBytes, err := json.Marshal(review)
+		if err != nil {
+			loggerFromContext(ctx).Errorf("Failed to marshal admission response: %v", err)
+			http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+			return
+		}
+		writeResponse(w, responseBytes)
+	})
+}
+
+// bufferedResponse holds a response so maintenance mode can rewrite it
+// before it is sent.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(status int) { b.status = status }
+
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+
+// applyMaintenanceMode turns a denial into an allow while maintenance mode is
+// enabled, logging what the webhook would have decided. The audit
+// annotations keep that decision and are marked as overridden.
+func applyMaintenanceMode(ctx context.Context, resp *admissionv1.AdmissionResponse) {
+	if !maintenanceMode.Load() {
+		return
+	}
+
+	if !resp.Allowed {
+		message := ""
+		if resp.Result != nil {
+			message = resp.Result.Message
+		}
+		loggerFromContext(ctx).Infof("Maintenance mode: would have denied request (%s)", message)
+	}
+
+	if resp.AuditAnnotations != nil {
+		resp.AuditAnnotations["maintenance-mode"] = "true"
+	}
+	resp.Allowed = true
+	resp.Result = nil
+	resp.Warnings = append(resp.Warnings, maintenanceWarning)
+}
+
+// handleMaintenance reports the maintenance mode on GET and changes it on
+// POST with the enabled query parameter. Maintenance mode lifts every denial,
+// including the finalizer, freeze and owner reference policies, so it is an
+// admin endpoint: meant for the platform team during an incident or an
+// upgrade of the operator, never for app teams.
+func handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+		if err != nil {
+			http.Error(w, "enabled must be true or false", http.StatusBadRequest)
+			return
+		}
+		setMaintenanceMode(enabled)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	responseBytes, err := json.Marshal(struct {
+		Enabled bool `json:"enabled"`
+	}{maintenanceMode.Load()})
+	if err != nil {
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, responseBytes)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestHandleAdmissionReview_MaintenanceMode(t *testing.T) {
+	defer setMaintenanceMode(false)
+
+	w := httptest.NewRecorder()
+	handleMaintenance(w, httptest.NewRequest(http.MethodPost, "/maintenance?enabled=true", nil))
+	if w.Code != http.StatusOK || !maintenanceMode.Load() {
+		t.Fatalf("Expected maintenance mode to be enabled, got status %d", w.Code)
+	}
+
+	// A no-op update that would be denied is allowed with a warning.
+	reqBytes := maintenanceTestReview(t, "test-uid-maintenance-mode")
+	assertMaintenanceAllowed(t, maintenanceHandler(http.HandlerFunc(handleAdmissionReview)), reqBytes, "test-uid-maintenance-mode")
+}
+
+func TestMaintenanceHandler_CachedDenial(t *testing.T) {
+	defer setMaintenanceMode(false)
+
+	// The no-op denial is answered and cached before maintenance mode is on,
+	// so the retry is a cache hit.
+	reqBytes := maintenanceTestReview(t, "test-uid-maintenance-cached")
+	handler := maintenanceHandler(http.HandlerFunc(handleAdmissionReview))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(reqBytes)))
+	if strings.Contains(w.Body.String(), maintenanceWarning) {
+		t.Fatalf("Expected the denial without maintenance mode, got %s", w.Body)
+	}
+
+	setMaintenanceMode(true)
+	before := testutil.ToFloat64(responseCacheHitsTotal)
+	assertMaintenanceAllowed(t, handler, reqBytes, "test-uid-maintenance-cached")
+	if hits := testutil.ToFloat64(responseCacheHitsTotal) - before; hits != 1 {
+		t.Errorf("Expected a cache hit, got %v", hits)
+	}
+}
+
+func TestMaintenanceHandler_PoolRejection(t *testing.T) {
+	defer setMaintenanceMode(false)
+	setMaintenanceMode(true)
+
+	pool := newWorkerPool(1, 0, http.HandlerFunc(handleAdmissionReview))
+	pool.stop(context.Background())
+	assertMaintenanceAllowed(t, maintenanceHandler(pool), maintenanceTestReview(t, "test-uid-maintenance-503"), "test-uid-maintenance-503")
+
+	// Without a UID there is nothing to allow, so the error stands.
+	w := httptest.NewRecorder()
+	maintenanceHandler(pool).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/validate", strings.NewReader("not json")))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code 503, got %d", w.Code)
+	}
+}
+
+// maintenanceTestReview returns an AdmissionReview of a no-op update.
+func maintenanceTestReview(t *testing.T, uid types.UID) []byte {
+	t.Helper()
+
+	reqBytes, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       uid,
+			Kind:      metav1.GroupVersionKind{Kind: "GrafanaDashboard"},
+			Operation: admissionv1.Update,
+			OldObject: runtime.RawExtension{Raw: []byte(`{"metadata": {}, "spec": {}, "status": {}}`)},
+			Object:    runtime.RawExtension{Raw: []byte(`{"metadata": {}, "spec": {}, "status": {}}`)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+	return reqBytes
+}
+
+func assertMaintenanceAllowed(t *testing.T, handler http.Handler, reqBytes []byte, uid types.UID) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(reqBytes)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d: %s", w.Code, w.Body)
+	}
+
+	var admissionResp admissionv1.AdmissionReview
+	if err := json.NewDecoder(w.Body).Decode(&admissionResp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if admissionResp.Response == nil || admissionResp.Response.UID != uid || !admissionResp.Response.Allowed {
+		t.Fatalf("Expected %s to be allowed in maintenance mode, got %+v", uid, admissionResp.Response)
+	}
+	if len(admissionResp.Response.Warnings) != 1 || admissionResp.Response.Warnings[0] != maintenanceWarning {
+		t.Errorf("Expected the maintenance warning, got %v", admissionResp.Response.Warnings)
+	}
+}
+
+func TestHandleMaintenance_InvalidValue(t *testing.T) {
+	w := httptest.NewRecorder()
+	handleMaintenance(w, httptest.NewRequest(http.MethodPost, "/maintenance?enabled=maybe", nil))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code 400, got %d", w.Code)
+	}
+}
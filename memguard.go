@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"runtime/debug"
+	"runtime/metrics"
+	"time"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/store"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// For soak runs and long-lived replicas, the memory guard watches the heap so
+// the webhook never gets OOM-killed mid-request. Above memorySoftLimitBytes it
+// runs the GC, returns memory to the OS and drops the in-memory histories that
+// only serve debugging, along with the cached responses and authorizer
+// decisions. Above memoryHardLimitBytes it stops reporting ready, waits up to
+// memoryDrainTimeout for the in-flight requests to finish and then shuts down
+// gracefully, letting Kubernetes restart the pod. Zero disables a limit.
+var (
+	memorySoftLimitBytes int64 = 0
+	memoryHardLimitBytes int64 = 0
+	memoryCheckInterval        = 5 * time.Second
+	memoryDrainTimeout         = 10 * time.Second
+)
+
+var (
+	// Counter for memory guard triggers, by limit
+	memoryGuardTriggersTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_memory_guard_triggers_total",
+			Help: "Total number of times the heap crossed a memory guard limit, differentiated by limit.",
+		},
+		[]string{"limit"}, // limit is "soft" or "hard"
+	)
+)
+
+func init() {
+	prometheus.MustRegister(memoryGuardTriggersTotal)
+}
+
+// heapObjectsMetric is the runtime metric for the bytes held by live and
+// not yet swept heap objects, the equivalent of MemStats.HeapAlloc.
+const heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+
+// memoryGuard checks the heap against the limits.
+type memoryGuard struct {
+	soft, hard int64
+
+	// heap returns the current heap size; exit starts a graceful shutdown.
+	heap func() int64
+	exit func()
+
+	// softTriggered is set while the heap stays above the soft limit, so the
+	// caches are freed once per crossing.
+	softTriggered bool
+	exiting       bool
+}
+
+func newMemoryGuard(soft, hard int64, exit func()) *memoryGuard {
+	return &memoryGuard{soft: soft, hard: hard, heap: heapBytes, exit: exit}
+}
+
+// heapBytes returns the bytes held by heap objects.
+func heapBytes() int64 {
+	sample := []metrics.Sample{{Name: heapObjectsMetric}}
+	metrics.Read(sample)
+	return int64(sample[0].Value.Uint64())
+}
+
+func (g *memoryGuard) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			g.check(ctx)
+		}
+	}
+}
+
+// check compares the heap with the limits and acts on the ones crossed.
+func (g *memoryGuard) check(ctx context.Context) {
+	if g.exiting {
+		return
+	}
+
+	heap := g.heap()
+	if g.soft > 0 && heap >= g.soft {
+		if !g.softTriggered {
+			g.softTriggered = true
+			memoryGuardTriggersTotal.WithLabelValues("soft").Inc()
+			log.Warnf("Heap of %d bytes is above the soft limit of %d bytes with %d requests in flight, freeing memory",
+				heap, g.soft, inFlightRequests.Load())
+			freeMemory()
+			heap = g.heap()
+		}
+	} else {
+		g.softTriggered = false
+	}
+
+	if g.hard > 0 && heap >= g.hard {
+		g.exiting = true
+		memoryGuardTriggersTotal.WithLabelValues("hard").Inc()
+		log.Errorf("Heap of %d bytes is above the hard limit of %d bytes, restarting", heap, g.hard)
+		ready.Store(false)
+		drainInFlight(ctx, memoryDrainTimeout)
+		g.exit()
+	}
+}
+
+// freeMemory drops the in-memory debugging histories and, with the default
+// in-memory state store, the cached admission responses and authorizer
+// decisions, then returns as much memory as possible to the OS. The deny-loop
+// counters and spec change buckets are kept: they enforce policy, and
+// dropping them would lift a backoff or refill a rate limit.
+func freeMemory() {
+	lastDecisions.reset()
+	objectChangeIndex.reset()
+	if memory, ok := state.(*store.Memory); ok {
+		memory.DeletePrefix("responses/")
+		memory.DeletePrefix("authorizer/")
+	}
+	debug.FreeOSMemory()
+}
+
+// drainInFlight waits until no admission request is in flight, ctx is done
+// or timeout has passed.
+func drainInFlight(ctx context.Context, timeout time.Duration) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for inFlightRequests.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline.C:
+			log.Warnf("Shutting down with %d requests still in flight", inFlightRequests.Load())
+			return
+		case <-ticker.C:
+		}
+	}
+}
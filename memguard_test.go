@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/store"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMemoryGuard_SoftLimit(t *testing.T) {
+	defer func(s store.Store) { state = s }(state)
+	state = store.NewMemory()
+	ctx := context.Background()
+	for _, key := range []string{"responses/guarded", "authorizer/guarded", "spec-changes/guarded"} {
+		if err := state.Set(ctx, key, []byte("value"), time.Minute); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+	}
+	lastDecisions.entries[decisionKey("GrafanaDashboard", "ns", "guarded")] = explainedDecision{Time: time.Now()}
+
+	heap := int64(50)
+	g := newMemoryGuard(100, 0, func() { t.Errorf("Expected no exit below the hard limit") })
+	g.heap = func() int64 { return heap }
+
+	before := testutil.ToFloat64(memoryGuardTriggersTotal.WithLabelValues("soft"))
+	g.check(context.Background())
+	heap = 150
+	g.check(context.Background())
+	g.check(context.Background())
+	if got := testutil.ToFloat64(memoryGuardTriggersTotal.WithLabelValues("soft")) - before; got != 1 {
+		t.Errorf("Expected 1 soft trigger while above the limit, got %v", got)
+	}
+	if _, ok := lastDecisions.get("GrafanaDashboard", "ns", "guarded"); ok {
+		t.Errorf("Expected the decision log to be freed")
+	}
+	for _, key := range []string{"responses/guarded", "authorizer/guarded"} {
+		if _, err := state.Get(ctx, key); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Expected %s to be freed, got %v", key, err)
+		}
+	}
+	if _, err := state.Get(ctx, "spec-changes/guarded"); err != nil {
+		t.Errorf("Expected the spec change bucket to be kept, got %v", err)
+	}
+
+	heap = 50
+	g.check(context.Background())
+	heap = 150
+	g.check(context.Background())
+	if got := testutil.ToFloat64(memoryGuardTriggersTotal.WithLabelValues("soft")) - before; got != 2 {
+		t.Errorf("Expected the soft limit to trigger again after recovering, got %v", got)
+	}
+}
+
+func TestMemoryGuard_HardLimit(t *testing.T) {
+	defer func(r bool) { ready.Store(r) }(ready.Load())
+	ready.Store(true)
+
+	exits := 0
+	g := newMemoryGuard(0, 100, func() { exits++ })
+	g.heap = func() int64 { return 200 }
+
+	g.check(context.Background())
+	g.check(context.Background())
+	if exits != 1 {
+		t.Errorf("Expected 1 exit, got %d", exits)
+	}
+	if ready.Load() {
+		t.Errorf("Expected readiness to be turned off")
+	}
+}
+
+func TestDrainInFlight(t *testing.T) {
+	inFlightRequests.Add(1)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		inFlightRequests.Add(-1)
+	}()
+
+	start := time.Now()
+	drainInFlight(context.Background(), 5*time.Second)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected to return once the request finished, took %s", elapsed)
+	}
+
+	inFlightRequests.Add(1)
+	defer inFlightRequests.Add(-1)
+	start = time.Now()
+	drainInFlight(context.Background(), 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected to give up after the timeout, took %s", elapsed)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// disabledMetrics lists metric names that are not exposed. A trailing "*"
+// matches every metric with that prefix, e.g. "go_*".
+var disabledMetrics stringSliceFlag
+
+// droppedMetricLabels lists labels removed from the exposed metrics, either
+// as "label" for every metric or as "metric:label" for one. Series that
+// become identical are aggregated, so counts stay correct while clusters
+// with little Prometheus capacity avoid high-cardinality labels.
+var droppedMetricLabels stringSliceFlag
+
+// filteringGatherer applies disabledMetrics and droppedMetricLabels to the
+// metric families of an underlying gatherer.
+type filteringGatherer struct {
+	gatherer   prometheus.Gatherer
+	disabled   []string
+	dropLabels map[string]map[string]bool // metric name, or "" for all
+}
+
+func newFilteringGatherer(gatherer prometheus.Gatherer, disabled, dropped []string) (*filteringGatherer, error) {
+	g := &filteringGatherer{gatherer: gatherer, disabled: disabled, dropLabels: map[string]map[string]bool{}}
+	for _, entry := range dropped {
+		metric, label, found := strings.Cut(entry, ":")
+		if !found {
+			metric, label = "", entry
+		}
+		if label == "" {
+			return nil, fmt.Errorf("invalid dropped metric label %q", entry)
+		}
+		if g.dropLabels[metric] == nil {
+			g.dropLabels[metric] = map[string]bool{}
+		}
+		g.dropLabels[metric][label] = true
+	}
+	return g, nil
+}
+
+func (g *filteringGatherer) isDisabled(name string) bool {
+	for _, pattern := range g.disabled {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *filteringGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+
+	filtered := families[:0]
+	for _, family := range families {
+		if g.isDisabled(family.GetName()) {
+			continue
+		}
+		drop := map[string]bool{}
+		for label := range g.dropLabels[""] {
+			drop[label] = true
+		}
+		for label := range g.dropLabels[family.GetName()] {
+			drop[label] = true
+		}
+		if len(drop) > 0 {
+			family.Metric = aggregateWithoutLabels(family.GetType(), family.Metric, drop)
+		}
+		filtered = append(filtered, family)
+	}
+	return filtered, err
+}
+
+// aggregateWithoutLabels removes the labels in drop from every metric and
+// merges metrics whose remaining labels are equal.
+func aggregateWithoutLabels(metricType dto.MetricType, metrics []*dto.Metric, drop map[string]bool) []*dto.Metric {
+	merged := map[string]*dto.Metric{}
+	var keys []string
+
+	for _, m := range metrics {
+		var labels []*dto.LabelPair
+		var key strings.Builder
+		for _, pair := range m.Label {
+			if drop[pair.GetName()] {
+				continue
+			}
+			labels = append(labels, pair)
+			fmt.Fprintf(&key, "%s=%q,", pair.GetName(), pair.GetValue())
+		}
+		m.Label = labels
+
+		existing, ok := merged[key.String()]
+		if !ok {
+			merged[key.String()] = m
+			keys = append(keys, key.String())
+			continue
+		}
+		mergeMetric(metricType, existing, m)
+	}
+
+	sort.Strings(keys)
+	result := make([]*dto.Metric, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, merged[key])
+	}
+	return result
+}
+
+// mergeMetric adds the value of src to dst. Gauges are summed as well, which
+// is right for the gauges this webhook exposes per label value. Summary
+// quantiles cannot be merged and are dropped.
+func mergeMetric(metricType dto.MetricType, dst, src *dto.Metric) {
+	switch metricType {
+	case dto.MetricType_COUNTER:
+		value := dst.GetCounter().GetValue() + src.GetCounter().GetValue()
+		dst.Counter.Value = &value
+	case dto.MetricType_GAUGE:
+		value := dst.GetGauge().GetValue() + src.GetGauge().GetValue()
+		dst.Gauge.Value = &value
+	case dto.MetricType_UNTYPED:
+		value := dst.GetUntyped().GetValue() + src.GetUntyped().GetValue()
+		dst.Untyped.Value = &value
+	case dto.MetricType_HISTOGRAM:
+		count := dst.GetHistogram().GetSampleCount() + src.GetHistogram().GetSampleCount()
+		sum := dst.GetHistogram().GetSampleSum() + src.GetHistogram().GetSampleSum()
+		dst.Histogram.SampleCount, dst.Histogram.SampleSum = &count, &sum
+		for i, bucket := range dst.Histogram.Bucket {
+			if i < len(src.GetHistogram().GetBucket()) {
+				cumulative := bucket.GetCumulativeCount() + src.Histogram.Bucket[i].GetCumulativeCount()
+				bucket.CumulativeCount = &cumulative
+			}
+		}
+	case dto.MetricType_SUMMARY:
+		count := dst.GetSummary().GetSampleCount() + src.GetSummary().GetSampleCount()
+		sum := dst.GetSummary().GetSampleSum() + src.GetSummary().GetSampleSum()
+		dst.Summary.SampleCount, dst.Summary.SampleSum = &count, &sum
+		dst.Summary.Quantile = nil
+	}
+}
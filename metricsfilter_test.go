@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestFilteringGatherer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_requests_total", Help: "test"}, []string{"namespace", "result"})
+	latency := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_latency_seconds", Help: "test", Buckets: []float64{1}}, []string{"namespace"})
+	hidden := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_hidden_total", Help: "test"})
+	registry.MustRegister(requests, latency, hidden)
+
+	requests.WithLabelValues("a", "allowed").Add(1)
+	requests.WithLabelValues("b", "allowed").Add(2)
+	requests.WithLabelValues("b", "denied").Add(4)
+	latency.WithLabelValues("a").Observe(0.5)
+	latency.WithLabelValues("b").Observe(2)
+
+	gatherer, err := newFilteringGatherer(registry, []string{"test_hidden*"}, []string{"namespace"})
+	if err != nil {
+		t.Fatalf("Failed to create gatherer: %v", err)
+	}
+	families, err := gatherer.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather: %v", err)
+	}
+
+	if len(families) != 2 {
+		t.Fatalf("Expected 2 metric families, got %d", len(families))
+	}
+	for _, family := range families {
+		switch family.GetName() {
+		case "test_requests_total":
+			if len(family.Metric) != 2 {
+				t.Fatalf("Expected 2 series after dropping namespace, got %d", len(family.Metric))
+			}
+			for _, m := range family.Metric {
+				if len(m.Label) != 1 || m.Label[0].GetName() != "result" {
+					t.Errorf("Expected only the result label, got %v", m.Label)
+				}
+			}
+			if v := family.Metric[0].GetCounter().GetValue(); v != 3 {
+				t.Errorf("Expected allowed requests to sum to 3, got %v", v)
+			}
+		case "test_latency_seconds":
+			if len(family.Metric) != 1 {
+				t.Fatalf("Expected 1 series after dropping namespace, got %d", len(family.Metric))
+			}
+			h := family.Metric[0].GetHistogram()
+			if h.GetSampleCount() != 2 || h.GetSampleSum() != 2.5 || h.GetBucket()[0].GetCumulativeCount() != 1 {
+				t.Errorf("Unexpected merged histogram: %v", h)
+			}
+		default:
+			t.Errorf("Unexpected metric family %s", family.GetName())
+		}
+	}
+}
+
+func TestNewFilteringGatherer_InvalidLabel(t *testing.T) {
+	if _, err := newFilteringGatherer(prometheus.NewRegistry(), nil, []string{"metric:"}); err == nil {
+		t.Errorf("Expected an error for an empty label, got nil")
+	}
+}
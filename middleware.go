@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/server"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// Counter for panics recovered while serving requests
+	handlerPanicsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_handler_panics_total",
+			Help: "Total number of panics recovered while serving requests, differentiated by path.",
+		},
+		[]string{"path"},
+	)
+
+	// Counter for requests served by the webhook handlers, by path and code
+	httpRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_http_requests_total",
+			Help: "Total number of requests served by the webhook handlers, differentiated by path and status code.",
+		},
+		[]string{"path", "code"},
+	)
+
+	// Histogram for the time spent in the webhook handlers, by path
+	httpRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "grafana_operator_webhook_http_request_duration_seconds",
+			Help:    "Time spent in the webhook handlers, differentiated by path.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"path"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(handlerPanicsTotal, httpRequestsTotal, httpRequestDuration)
+}
+
+// newMiddlewareChain returns the middleware shared by the webhook handlers.
+// The admission handler runs on worker goroutines, where an unrecovered panic
+// would take down the whole process, so recovery is always the outermost
+// stage. Request bodies are limited to maxRequestBodyBytes in the limits
+// stage, so the handlers do not each need to.
+func newMiddlewareChain() *server.Chain {
+	chain := server.NewChain().
+		Use(server.StageLimits, server.MaxBodyBytes(maxRequestBodyBytes)).
+		Use(server.StageMetrics, server.Metrics(func(e server.AccessEntry) {
+			httpRequestsTotal.WithLabelValues(e.Path, strconv.Itoa(e.Status)).Inc()
+			httpRequestDuration.WithLabelValues(e.Path).Observe(e.Duration.Seconds())
+		}))
+	if maxRequestsPerConn > 0 {
+		chain.Use(server.StageLimits, server.MaxRequestsPerConn(maxRequestsPerConn, connectionsRecycledTotal.Inc))
+	}
+	return chain.
+		Use(server.StageRecovery, server.Recovery(func(r *http.Request, recovered interface{}) {
+			handlerPanicsTotal.WithLabelValues(r.URL.Path).Inc()
+			log.Errorf("Recovered panic serving %s: %v\n%s", r.URL.Path, recovered, debug.Stack())
+		})).
+		Use(server.StageAccessLog, server.AccessLog(func(e server.AccessEntry) {
+			log.WithFields(log.Fields{
+				"method":   e.Method,
+				"path":     e.Path,
+				"status":   e.Status,
+				"bytes":    e.Bytes,
+				"duration": e.Duration,
+			}).Debug("Request served")
+		}))
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNewMiddlewareChain(t *testing.T) {
+	defer func(n int64) { maxRequestBodyBytes = n }(maxRequestBodyBytes)
+	maxRequestBodyBytes = 4
+
+	handler := newMiddlewareChain().ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, "failed to read request body", http.StatusRequestEntityTooLarge)
+		}
+	})
+
+	before := testutil.ToFloat64(httpRequestsTotal.WithLabelValues("/chain-test", "413"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/chain-test", strings.NewReader("0123456789")))
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected the limits stage to cap the body, got status code %d", w.Code)
+	}
+	if got := testutil.ToFloat64(httpRequestsTotal.WithLabelValues("/chain-test", "413")) - before; got != 1 {
+		t.Errorf("Expected the metrics stage to count 1 request, got %v", got)
+	}
+}
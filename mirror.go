@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// With --mirror-url a sampled copy of the admission requests is forwarded to
+// a staging instance running a newer version, so an upgrade can be validated
+// against production traffic before it is promoted. Mirroring is fire and
+// forget: the copy is sent after the response and its outcome only shows up
+// in metrics, where the staging decision is compared with the production one.
+// When the staging instance falls behind, copies are dropped.
+var (
+	mirrorURL           = ""
+	mirrorCAFile        = ""
+	mirrorSamplePercent = 100
+	mirrorTimeout       = 2 * time.Second
+)
+
+// mirrorQueueSize and mirrorWorkers bound the copies waiting to be sent and
+// the concurrent calls to the staging instance.
+const (
+	mirrorQueueSize = 100
+	mirrorWorkers   = 4
+)
+
+var (
+	// Counter for mirrored admission requests, by result
+	mirrorRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_mirror_requests_total",
+			Help: "Total number of admission requests mirrored to the staging webhook, differentiated by result.",
+		},
+		[]string{"result"}, // result is "match", "mismatch", "error" or "dropped"
+	)
+)
+
+func init() {
+	prometheus.MustRegister(mirrorRequestsTotal)
+}
+
+// mirroredRequest is an AdmissionReview body and the production decision.
+type mirroredRequest struct {
+	body    []byte
+	allowed bool
+}
+
+// requestMirror sends sampled admission requests to the staging webhook.
+type requestMirror struct {
+	url      string
+	client   *http.Client
+	requests chan mirroredRequest
+	roll     func() int // returns 0-99
+	closed   atomic.Bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// mirror is nil unless --mirror-url is set.
+var mirror *requestMirror
+
+func newRequestMirror(url, caFile string) (*requestMirror, error) {
+	tlsConfig, err := clientTLSConfig(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("mirror CA bundle: %w", err)
+	}
+
+	m := &requestMirror{
+		url: url,
+		client: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+			Timeout:   mirrorTimeout,
+		},
+		requests: make(chan mirroredRequest, mirrorQueueSize),
+		roll:     func() int { return rand.IntN(100) },
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go m.run()
+	return m, nil
+}
+
+// send queues a copy of body, answered with allowed in production, if it is
+// sampled. It never blocks the admission path.
+func (m *requestMirror) send(body []byte, allowed bool) {
+	if m.roll() >= mirrorSamplePercent {
+		return
+	}
+	if m.closed.Load() {
+		mirrorRequestsTotal.WithLabelValues("dropped").Inc()
+		return
+	}
+
+	select {
+	case m.requests <- mirroredRequest{body: body, allowed: allowed}:
+	default:
+		mirrorRequestsTotal.WithLabelValues("dropped").Inc()
+	}
+}
+
+func (m *requestMirror) run() {
+	defer close(m.done)
+
+	workers := make(chan struct{}, mirrorWorkers)
+	start := func(req mirroredRequest) {
+		workers <- struct{}{}
+		go func() {
+			defer func() { <-workers }()
+			m.forward(req)
+		}()
+	}
+
+	for {
+		select {
+		case req := <-m.requests:
+			start(req)
+		case <-m.stop:
+			// The requests channel is never closed, as handlers cut off by
+			// a forced shutdown may still send; drain what is queued.
+			for {
+				select {
+				case req := <-m.requests:
+					start(req)
+				default:
+					for range mirrorWorkers {
+						workers <- struct{}{}
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// forward sends req to the staging webhook and compares its decision.
+func (m *requestMirror) forward(req mirroredRequest) {
+	resp, err := callWebhook(context.Background(), m.client, m.url, req.body)
+	switch {
+	case err != nil:
+		mirrorRequestsTotal.WithLabelValues("error").Inc()
+		log.Debugf("Mirroring to %s failed: %v", m.url, err)
+	case resp.Allowed != req.allowed:
+		mirrorRequestsTotal.WithLabelValues("mismatch").Inc()
+		log.Infof("Mirror %s decided allowed=%t where production decided allowed=%t", m.url, resp.Allowed, req.allowed)
+	default:
+		mirrorRequestsTotal.WithLabelValues("match").Inc()
+	}
+}
+
+// shutdown stops accepting copies and waits until the queued ones have been
+// sent or ctx expires. Copies sent afterwards are dropped.
+func (m *requestMirror) shutdown(ctx context.Context) {
+	m.closed.Store(true)
+	close(m.stop)
+	select {
+	case <-m.done:
+	case <-ctx.Done():
+		log.Warn("Timed out mirroring admission requests")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+func TestRequestMirror(t *testing.T) {
+	denying := newDownstream(t, false)
+
+	body, err := json.Marshal(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{UID: "test-uid-mirror"}})
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	m, err := newRequestMirror(denying.URL, "")
+	if err != nil {
+		t.Fatalf("Failed to create mirror: %v", err)
+	}
+	matches := testutil.ToFloat64(mirrorRequestsTotal.WithLabelValues("match"))
+	mismatches := testutil.ToFloat64(mirrorRequestsTotal.WithLabelValues("mismatch"))
+
+	m.send(body, true)
+	m.send(body, false)
+	m.shutdown(context.Background())
+
+	if got := testutil.ToFloat64(mirrorRequestsTotal.WithLabelValues("match")) - matches; got != 1 {
+		t.Errorf("Expected 1 matching decision, got %v", got)
+	}
+	if got := testutil.ToFloat64(mirrorRequestsTotal.WithLabelValues("mismatch")) - mismatches; got != 1 {
+		t.Errorf("Expected 1 mismatching decision, got %v", got)
+	}
+}
+
+func TestRequestMirror_Sampling(t *testing.T) {
+	defer func(percent int) { mirrorSamplePercent = percent }(mirrorSamplePercent)
+	mirrorSamplePercent = 10
+
+	m := &requestMirror{requests: make(chan mirroredRequest, 1)}
+	for _, tt := range []struct {
+		roll     int
+		expected int
+	}{
+		{roll: 10, expected: 0},
+		{roll: 9, expected: 1},
+	} {
+		m.roll = func() int { return tt.roll }
+		m.send([]byte("{}"), true)
+		if len(m.requests) != tt.expected {
+			t.Errorf("Expected %d queued requests after rolling %d, got %d", tt.expected, tt.roll, len(m.requests))
+		}
+	}
+}
+
+func TestRequestMirror_DropsWhenFull(t *testing.T) {
+	m := &requestMirror{
+		requests: make(chan mirroredRequest, 1),
+		roll:     func() int { return 0 },
+	}
+	dropped := testutil.ToFloat64(mirrorRequestsTotal.WithLabelValues("dropped"))
+
+	m.send([]byte("{}"), true)
+	m.send([]byte("{}"), true)
+
+	if got := testutil.ToFloat64(mirrorRequestsTotal.WithLabelValues("dropped")) - dropped; got != 1 {
+		t.Errorf("Expected 1 dropped request, got %v", got)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/errdefs"
+	log "github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// With --change-annotation set, /mutate can be registered in a
+// MutatingWebhookConfiguration. Every significant change it sees is stamped
+// with the annotation, holding a short hash of the change summary and when
+// the change was made, e.g.
+//
+//	grafana-operator-webhook/last-change: 3f2a9c1b07de@2026-10-17T09:30:00Z
+//
+// so controllers and humans can tell when an object last changed in a
+// meaningful way. The annotation itself is never compared, and /mutate never
+// denies: the decision is left to /validate, which runs after it.
+var changeAnnotation = ""
+
+// changeHashLength is the number of hex digits of the change summary hash
+// kept in the annotation.
+const changeHashLength = 12
+
+// handleMutate answers an AdmissionReview from a mutating webhook with a
+// patch stamping the change annotation on significant changes.
+func handleMutate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var admissionReviewReq admissionv1.AdmissionReview
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := json.Unmarshal(body, &admissionReviewReq); err != nil {
+		http.Error(w, "failed to unmarshal request", http.StatusBadRequest)
+		return
+	}
+	if admissionReviewReq.Request == nil {
+		http.Error(w, "admission review request is empty", http.StatusBadRequest)
+		return
+	}
+
+	ctx := withLogger(r.Context(), requestLogger(admissionReviewReq.Request))
+	resp, err := mutateRequest(ctx, admissionReviewReq.Request, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), errdefs.HTTPStatus(err))
+		return
+	}
+
+	// Mutating responses are not cached: a retried /mutate call is cheap to
+	// answer again, and the cache is kept for /validate.
+	responseBytes, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Response: resp,
+	})
+	if err != nil {
+		log.Errorf("Failed to marshal admission response: %v", err)
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, responseBytes)
+}
+
+// mutateRequest allows req, with a patch stamping the change annotation if
+// it is a significant change made at now. It only uses evaluateRequest, which
+// has no side effects, so the admission counters of /validate see each
+// change once.
+func mutateRequest(ctx context.Context, req *admissionv1.AdmissionRequest, now time.Time) (*admissionv1.AdmissionResponse, error) {
+	evaluated, cmp, err := evaluateRequest(ctx, req)
+	if errors.Is(err, errdefs.ErrOversizedObject) {
+		return allowOversized(ctx, req, err), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &admissionv1.AdmissionResponse{UID: req.UID, Allowed: true}
+	if changeAnnotation == "" || !evaluated.Allowed || cmp == nil || !cmp.changed() {
+		return resp, nil
+	}
+
+	var obj struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(req.Object.Raw, &obj); err != nil {
+		return nil, err
+	}
+
+	value := changeSummaryHash(cmp) + "@" + now.UTC().Format(time.RFC3339)
+	operation := jsonPatchOperation{
+		Op:    "add",
+		Path:  "/metadata/annotations",
+		Value: map[string]string{changeAnnotation: value},
+	}
+	if obj.Metadata.Annotations != nil {
+		operation.Path += "/" + escapeJSONPointer(changeAnnotation)
+		operation.Value = value
+	}
+
+	patch, err := json.Marshal([]jsonPatchOperation{operation})
+	if err != nil {
+		return nil, err
+	}
+	patchType := admissionv1.PatchTypeJSONPatch
+	resp.Patch, resp.PatchType = patch, &patchType
+	loggerFromContext(ctx).Debugf("Stamping change annotation %s", value)
+	return resp, nil
+}
+
+// changeSummaryHash returns a short hash of what changed: the differences,
+// or only the categories when the objects were compared partially.
+func changeSummaryHash(cmp *comparison) string {
+	var summary interface{} = cmp.categories()
+	if !cmp.partial {
+		summary = diffObjects(cmp.oldObj, cmp.newObj)
+	}
+	encoded, _ := json.Marshal(summary)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])[:changeHashLength]
+}
+
+// removeChangeAnnotation removes the change annotation from objs, so that
+// stamping it is not a change in itself.
+func removeChangeAnnotation(objs ...map[string]interface{}) {
+	if changeAnnotation == "" {
+		return
+	}
+	for _, obj := range objs {
+		metadata, _ := obj["metadata"].(map[string]interface{})
+		annotations, _ := metadata["annotations"].(map[string]interface{})
+		if _, ok := annotations[changeAnnotation]; !ok {
+			continue
+		}
+		delete(annotations, changeAnnotation)
+		if len(annotations) == 0 {
+			delete(metadata, "annotations")
+		}
+	}
+}
+
+// escapeJSONPointer escapes s for use as a JSON Pointer reference token.
+func escapeJSONPointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
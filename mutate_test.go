@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func mutateTestRequest(oldObj, newObj string) *admissionv1.AdmissionRequest {
+	return &admissionv1.AdmissionRequest{
+		UID:       "mutate-uid",
+		Kind:      metav1.GroupVersionKind{Kind: "GrafanaDashboard"},
+		Namespace: "ns",
+		Name:      "dash",
+		Operation: admissionv1.Update,
+		OldObject: runtime.RawExtension{Raw: []byte(oldObj)},
+		Object:    runtime.RawExtension{Raw: []byte(newObj)},
+	}
+}
+
+func TestMutateRequest(t *testing.T) {
+	defer func(a string) { changeAnnotation = a }(changeAnnotation)
+	changeAnnotation = "grafana-operator-webhook/last-change"
+	now := time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		oldObj        string
+		newObj        string
+		expectedPatch string
+	}{
+		{
+			name:          "no annotations",
+			oldObj:        `{"metadata": {"name": "dash"}, "spec": {"folder": "a"}}`,
+			newObj:        `{"metadata": {"name": "dash"}, "spec": {"folder": "b"}}`,
+			expectedPatch: `^\[\{"op":"add","path":"/metadata/annotations","value":\{"grafana-operator-webhook/last-change":"[0-9a-f]{12}@2026-10-17T09:30:00Z"\}\}\]$`,
+		},
+		{
+			name:          "existing annotations",
+			oldObj:        `{"metadata": {"annotations": {"owner": "x", "grafana-operator-webhook/last-change": "old"}}, "spec": {"folder": "a"}}`,
+			newObj:        `{"metadata": {"annotations": {"owner": "x", "grafana-operator-webhook/last-change": "old"}}, "spec": {"folder": "b"}}`,
+			expectedPatch: `^\[\{"op":"add","path":"/metadata/annotations/grafana-operator-webhook~1last-change","value":"[0-9a-f]{12}@2026-10-17T09:30:00Z"\}\]$`,
+		},
+		{
+			name:   "no-op update",
+			oldObj: `{"metadata": {"generation": 1}, "spec": {"folder": "a"}}`,
+			newObj: `{"metadata": {"generation": 2}, "spec": {"folder": "a"}}`,
+		},
+		{
+			name:   "annotation removed",
+			oldObj: `{"metadata": {"annotations": {"grafana-operator-webhook/last-change": "old"}}, "spec": {"folder": "a"}}`,
+			newObj: `{"metadata": {}, "spec": {"folder": "a"}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := mutateRequest(context.Background(), mutateTestRequest(test.oldObj, test.newObj), now)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !resp.Allowed {
+				t.Errorf("Expected the request to be allowed")
+			}
+			if test.expectedPatch == "" {
+				if resp.Patch != nil || resp.PatchType != nil {
+					t.Errorf("Expected no patch, got %s", resp.Patch)
+				}
+				return
+			}
+			if !regexp.MustCompile(test.expectedPatch).Match(resp.Patch) {
+				t.Errorf("Expected a patch matching %s, got %s", test.expectedPatch, resp.Patch)
+			}
+			if resp.PatchType == nil || *resp.PatchType != admissionv1.PatchTypeJSONPatch {
+				t.Errorf("Expected a JSONPatch patch type, got %v", resp.PatchType)
+			}
+		})
+	}
+}
+
+func TestMutateRequest_Disabled(t *testing.T) {
+	defer func(a string) { changeAnnotation = a }(changeAnnotation)
+	changeAnnotation = ""
+
+	resp, err := mutateRequest(context.Background(), mutateTestRequest(`{"spec": {"folder": "a"}}`, `{"spec": {"folder": "b"}}`), time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !resp.Allowed || resp.Patch != nil {
+		t.Errorf("Expected an allowed response without a patch, got %+v", resp)
+	}
+}
+
+func TestChangeSummaryHash(t *testing.T) {
+	a := &comparison{oldObj: map[string]interface{}{"spec": "a"}, newObj: map[string]interface{}{"spec": "b"}, specChanged: true}
+	b := &comparison{oldObj: map[string]interface{}{"spec": "a"}, newObj: map[string]interface{}{"spec": "c"}, specChanged: true}
+
+	if changeSummaryHash(a) != changeSummaryHash(a) {
+		t.Errorf("Expected the hash to be stable")
+	}
+	if changeSummaryHash(a) == changeSummaryHash(b) {
+		t.Errorf("Expected different changes to hash differently")
+	}
+	if len(changeSummaryHash(a)) != changeHashLength {
+		t.Errorf("Expected a %d digit hash, got %s", changeHashLength, changeSummaryHash(a))
+	}
+}
+
+func TestHandleMutate(t *testing.T) {
+	defer func(a string) { changeAnnotation = a }(changeAnnotation)
+	changeAnnotation = "last-change"
+
+	reqBytes, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request:  mutateTestRequest(`{"spec": {"folder": "a"}}`, `{"spec": {"folder": "b"}}`),
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	processed := testutil.ToFloat64(processedTotal.WithLabelValues("true"))
+	rollout := testutil.ToFloat64(rulesetRequestsTotal.WithLabelValues(rulesetStable))
+	w := httptest.NewRecorder()
+	handleMutate(w, httptest.NewRequest(http.MethodPost, "/mutate", bytes.NewReader(reqBytes)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", w.Code)
+	}
+	if got := testutil.ToFloat64(processedTotal.WithLabelValues("true")) - processed; got != 0 {
+		t.Errorf("Expected /mutate to leave the admission counters to /validate, got %v processed", got)
+	}
+	if got := testutil.ToFloat64(rulesetRequestsTotal.WithLabelValues(rulesetStable)) - rollout; got != 0 {
+		t.Errorf("Expected /mutate to leave the ruleset counters to /validate, got %v", got)
+	}
+
+	var review admissionv1.AdmissionReview
+	if err := json.NewDecoder(w.Body).Decode(&review); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if review.Response == nil || review.Response.UID != "mutate-uid" || len(review.Response.Patch) == 0 {
+		t.Errorf("Expected a patched response for mutate-uid, got %+v", review.Response)
+	}
+
+	w = httptest.NewRecorder()
+	handleMutate(w, httptest.NewRequest(http.MethodGet, "/mutate", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status code 405, got %d", w.Code)
+	}
+}
+
+func TestRemoveChangeAnnotation(t *testing.T) {
+	defer func(a string) { changeAnnotation = a }(changeAnnotation)
+	changeAnnotation = "last-change"
+
+	obj := map[string]interface{}{"metadata": map[string]interface{}{"annotations": map[string]interface{}{"last-change": "x"}}}
+	removeChangeAnnotation(obj)
+	if _, ok := obj["metadata"].(map[string]interface{})["annotations"]; ok {
+		t.Errorf("Expected the emptied annotations to be removed, got %v", obj)
+	}
+}
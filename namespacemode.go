@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Decision modes. In deny mode no-op updates are rejected; in allow-warn mode
+// they are allowed with a warning naming the decision that was skipped.
+const (
+	decisionModeDeny      = "deny"
+	decisionModeAllowWarn = "allow-warn"
+)
+
+// namespaceModeAnnotation on a namespace overrides the decision mode for
+// objects in that namespace.
+const namespaceModeAnnotation = "grafana-operator-webhook/mode"
+
+// decisionMode is the mode for namespaces without the annotation.
+var decisionMode = decisionModeDeny
+
+// namespaceModeOverrides enables reading namespaceModeAnnotation through a
+// namespace informer.
+var namespaceModeOverrides = false
+
+// namespaceLister serves namespaces from the informer cache. It is nil unless
+// namespaceModeOverrides is enabled.
+var namespaceLister corelisters.NamespaceLister
+
+var (
+	// Counter for denials turned into warnings by the decision mode
+	decisionModeSoftenedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_decision_mode_softened_total",
+			Help: "Total number of denials allowed with a warning because of the decision mode, differentiated by whether a namespace override applied.",
+		},
+		[]string{"source"}, // source is "global" or "namespace"
+	)
+)
+
+func init() {
+	prometheus.MustRegister(decisionModeSoftenedTotal)
+}
+
+func validDecisionMode(mode string) bool {
+	return mode == decisionModeDeny || mode == decisionModeAllowWarn
+}
+
+// startNamespaceInformer starts a namespace informer and waits for its cache
+// to sync before setting namespaceLister.
+func startNamespaceInformer(ctx context.Context, client kubernetes.Interface) error {
+	factory := informers.NewSharedInformerFactory(client, 0)
+	namespaces := factory.Core().V1().Namespaces()
+	informer := namespaces.Informer()
+
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return fmt.Errorf("namespace informer cache did not sync")
+	}
+	namespaceLister = namespaces.Lister()
+	return nil
+}
+
+// decisionModeFor returns the decision mode for namespace and whether it
+// came from a namespace annotation. Unknown annotation values are ignored.
+func decisionModeFor(namespace string) (string, bool) {
+	if namespaceLister == nil || namespace == "" {
+		return decisionMode, false
+	}
+
+	ns, err := namespaceLister.Get(namespace)
+	if err != nil {
+		return decisionMode, false
+	}
+	mode, ok := ns.Annotations[namespaceModeAnnotation]
+	if !ok || !validDecisionMode(mode) {
+		return decisionMode, false
+	}
+	return mode, true
+}
+
+// applyDecisionMode allows a denied no-op update with a warning when the
+// decision mode of its namespace is allow-warn.
+func applyDecisionMode(ctx context.Context, namespace string, resp *admissionv1.AdmissionResponse) {
+	if !isNoopDenial(resp) {
+		return
+	}
+
+	mode, overridden := decisionModeFor(namespace)
+	if mode != decisionModeAllowWarn {
+		return
+	}
+
+	message := ""
+	if resp.Result != nil {
+		message = resp.Result.Message
+	}
+	loggerFromContext(ctx).Infof("Decision mode %s: allowing request that would have been denied (%s)", mode, message)
+
+	source := "global"
+	if overridden {
+		source = "namespace"
+	}
+	decisionModeSoftenedTotal.WithLabelValues(source).Inc()
+
+	resp.Allowed = true
+	resp.Result = nil
+	resp.Warnings = append(resp.Warnings, fmt.Sprintf("grafana-operator-webhook would have denied this update: %s", message))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+func TestApplyDecisionMode_NamespaceOverride(t *testing.T) {
+	defer func(l corelisters.NamespaceLister) { namespaceLister = l }(namespaceLister)
+
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "migrating",
+			Annotations: map[string]string{namespaceModeAnnotation: decisionModeAllowWarn},
+		}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "strict"}},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := startNamespaceInformer(ctx, client); err != nil {
+		t.Fatalf("Failed to start namespace informer: %v", err)
+	}
+
+	denied := func() *admissionv1.AdmissionResponse {
+		return &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result:  &metav1.Status{Status: metav1.StatusSuccess, Message: "Update successful."},
+		}
+	}
+
+	resp := denied()
+	applyDecisionMode(ctx, "migrating", resp)
+	if !resp.Allowed || len(resp.Warnings) != 1 {
+		t.Errorf("Expected allow-warn namespace to allow with a warning, got allowed=%t warnings=%v", resp.Allowed, resp.Warnings)
+	}
+
+	resp = denied()
+	applyDecisionMode(ctx, "strict", resp)
+	if resp.Allowed {
+		t.Errorf("Expected namespace without annotation to keep the global deny mode")
+	}
+
+	resp = denied()
+	applyDecisionMode(ctx, "unknown", resp)
+	if resp.Allowed {
+		t.Errorf("Expected unknown namespace to keep the global deny mode")
+	}
+}
+
+func TestApplyDecisionMode_Global(t *testing.T) {
+	defer func(m string) { decisionMode = m }(decisionMode)
+	decisionMode = decisionModeAllowWarn
+
+	resp := &admissionv1.AdmissionResponse{Allowed: false, Result: &metav1.Status{Status: metav1.StatusSuccess}}
+	applyDecisionMode(context.Background(), "default", resp)
+	if !resp.Allowed {
+		t.Errorf("Expected global allow-warn mode to allow the request")
+	}
+
+	resp = &admissionv1.AdmissionResponse{Allowed: false, Result: &metav1.Status{Status: metav1.StatusFailure}}
+	applyDecisionMode(context.Background(), "default", resp)
+	if resp.Allowed {
+		t.Errorf("Expected allow-warn mode to keep policy denials")
+	}
+}
+
+func TestApplyDecisionMode_KeepsOtherDenials(t *testing.T) {
+	defer func(m string) { decisionMode = m }(decisionMode)
+	decisionMode = decisionModeAllowWarn
+
+	tests := []struct {
+		name string
+		resp *admissionv1.AdmissionResponse
+	}{
+		{"change freeze", &admissionv1.AdmissionResponse{Result: &metav1.Status{Status: metav1.StatusFailure, Code: 403, Message: "frozen"}}},
+		{"invalid embedded json", &admissionv1.AdmissionResponse{Result: &metav1.Status{Status: metav1.StatusFailure, Code: 422}}},
+		{"rate limit", &admissionv1.AdmissionResponse{Result: &metav1.Status{Status: metav1.StatusFailure, Code: 429}}},
+		{"no result", &admissionv1.AdmissionResponse{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applyDecisionMode(context.Background(), "default", tt.resp)
+			if tt.resp.Allowed || len(tt.resp.Warnings) != 0 {
+				t.Errorf("Expected the denial to be left alone, got allowed=%t warnings=%v", tt.resp.Allowed, tt.resp.Warnings)
+			}
+		})
+	}
+}
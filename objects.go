@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// changeIndexWindow is how far back the per-object change history reaches.
+var changeIndexWindow = 24 * time.Hour
+
+// objectChanges holds the times at which significant spec and status changes
+// of one object were admitted, oldest first.
+type objectChanges struct {
+	spec   []time.Time
+	status []time.Time
+}
+
+// changeIndex tracks how volatile each object is. It only keeps timestamps of
+// changes the comparison found significant, which is far cheaper than keeping
+// the diffs themselves.
+type changeIndex struct {
+	mu      sync.Mutex
+	objects map[string]*objectChanges
+}
+
+var objectChangeIndex = newChangeIndex()
+
+func newChangeIndex() *changeIndex {
+	return &changeIndex{objects: make(map[string]*objectChanges)}
+}
+
+// reset forgets the change history of every object.
+func (c *changeIndex) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.objects = make(map[string]*objectChanges)
+}
+
+// record notes the spec and status changes found for the object at now.
+func (c *changeIndex) record(namespace, name string, cmp comparison, now time.Time) {
+	if !cmp.specChanged && !cmp.statusChanged {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := namespace + "/" + name
+	entry, ok := c.objects[key]
+	if !ok {
+		entry = &objectChanges{}
+		c.objects[key] = entry
+	}
+	if cmp.specChanged {
+		entry.spec = append(prune(entry.spec, now), now)
+	}
+	if cmp.statusChanged {
+		entry.status = append(prune(entry.status, now), now)
+	}
+}
+
+// objectVolatility is the /debug/objects view of one object.
+type objectVolatility struct {
+	Namespace     string `json:"namespace"`
+	Name          string `json:"name"`
+	SpecChanges   int    `json:"specChanges"`
+	StatusChanges int    `json:"statusChanges"`
+}
+
+// snapshot returns the change counts within the window for every object that
+// changed in it, most volatile first, and drops objects that did not.
+func (c *changeIndex) snapshot(now time.Time) []objectVolatility {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := []objectVolatility{}
+	for key, entry := range c.objects {
+		entry.spec = prune(entry.spec, now)
+		entry.status = prune(entry.status, now)
+		if len(entry.spec) == 0 && len(entry.status) == 0 {
+			delete(c.objects, key)
+			continue
+		}
+
+		namespace, name, _ := strings.Cut(key, "/")
+		result = append(result, objectVolatility{
+			Namespace:     namespace,
+			Name:          name,
+			SpecChanges:   len(entry.spec),
+			StatusChanges: len(entry.status),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		ti := result[i].SpecChanges + result[i].StatusChanges
+		tj := result[j].SpecChanges + result[j].StatusChanges
+		if ti != tj {
+			return ti > tj
+		}
+		return result[i].Namespace+"/"+result[i].Name < result[j].Namespace+"/"+result[j].Name
+	})
+	return result
+}
+
+// prune drops timestamps that fell out of the window.
+func prune(times []time.Time, now time.Time) []time.Time {
+	cutoff := now.Add(-changeIndexWindow)
+	i := sort.Search(len(times), func(i int) bool { return times[i].After(cutoff) })
+	return times[i:]
+}
+
+// handleDebugObjects serves the per-object change counts, optionally
+// filtered by the namespace and name query parameters.
+func handleDebugObjects(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	namespace := r.URL.Query().Get("namespace")
+	name := r.URL.Query().Get("name")
+
+	objects := []objectVolatility{}
+	for _, obj := range objectChangeIndex.snapshot(time.Now()) {
+		if (namespace == "" || obj.Namespace == namespace) && (name == "" || obj.Name == name) {
+			objects = append(objects, obj)
+		}
+	}
+
+	responseBytes, err := json.Marshal(struct {
+		Window  string             `json:"window"`
+		Objects []objectVolatility `json:"objects"`
+	}{changeIndexWindow.String(), objects})
+	if err != nil {
+		log.Errorf("Failed to marshal debug objects: %v", err)
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, responseBytes)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChangeIndex_CountsWithinWindow(t *testing.T) {
+	c := newChangeIndex()
+	now := time.Now()
+
+	c.record("ns", "volatile", comparison{specChanged: true}, now.Add(-25*time.Hour))
+	c.record("ns", "volatile", comparison{specChanged: true, statusChanged: true}, now.Add(-time.Hour))
+	c.record("ns", "volatile", comparison{statusChanged: true}, now)
+	c.record("ns", "calm", comparison{specChanged: true}, now)
+	c.record("ns", "stale", comparison{statusChanged: true}, now.Add(-48*time.Hour))
+	c.record("ns", "metadata-only", comparison{metadataChanged: true}, now)
+
+	got := c.snapshot(now)
+	expected := []objectVolatility{
+		{Namespace: "ns", Name: "volatile", SpecChanges: 1, StatusChanges: 2},
+		{Namespace: "ns", Name: "calm", SpecChanges: 1, StatusChanges: 0},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d objects, got %+v", len(expected), got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], got[i])
+		}
+	}
+
+	if _, ok := c.objects["ns/stale"]; ok {
+		t.Errorf("Expected objects without changes in the window to be dropped")
+	}
+}
+
+func TestHandleDebugObjects_Filter(t *testing.T) {
+	defer func(index *changeIndex) { objectChangeIndex = index }(objectChangeIndex)
+	objectChangeIndex = newChangeIndex()
+	objectChangeIndex.record("a", "one", comparison{specChanged: true}, time.Now())
+	objectChangeIndex.record("b", "two", comparison{specChanged: true}, time.Now())
+
+	w := httptest.NewRecorder()
+	handleDebugObjects(w, httptest.NewRequest(http.MethodGet, "/debug/objects?namespace=b", nil))
+
+	var resp struct {
+		Objects []objectVolatility `json:"objects"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Objects) != 1 || resp.Objects[0].Name != "two" {
+		t.Errorf("Expected only b/two, got %+v", resp.Objects)
+	}
+}
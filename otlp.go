@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// With --otlp-logs-endpoint every admission decision is also emitted as an
+// OTLP log record, so decisions can be routed through an OpenTelemetry
+// collector without scraping stdout. Records are sent as OTLP/HTTP JSON in
+// batches; when the exporter falls behind records are dropped rather than
+// slowing down admission.
+var (
+	otlpLogsEndpoint  = ""
+	otlpBatchSize     = 100
+	otlpFlushInterval = 5 * time.Second
+)
+
+var (
+	// Counter for decision log records the exporter could not deliver
+	otlpDroppedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_otlp_dropped_total",
+			Help: "Total number of decision log records dropped because the OTLP exporter queue was full or the export failed.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(otlpDroppedTotal)
+}
+
+// OTLP/HTTP JSON encoding of the logs signal, limited to the fields used here.
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string         `json:"timeUnixNano"`
+	SeverityNumber int            `json:"severityNumber"`
+	SeverityText   string         `json:"severityText"`
+	Body           otlpAnyValue   `json:"body"`
+	Attributes     []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpResourceLogs struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+func otlpString(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &value}}
+}
+
+func otlpBool(key string, value bool) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{BoolValue: &value}}
+}
+
+// decisionExporter batches decision log records and posts them to an OTLP
+// logs endpoint.
+type decisionExporter struct {
+	endpoint string
+	client   *http.Client
+	records  chan otlpLogRecord
+	closed   atomic.Bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// decisions is nil unless --otlp-logs-endpoint is set.
+var decisions *decisionExporter
+
+func newDecisionExporter(endpoint string) *decisionExporter {
+	e := &decisionExporter{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		records:  make(chan otlpLogRecord, otlpBatchSize*10),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// emitDecision queues a log record for the decision made for req. It never
+// blocks the admission path.
+func emitDecision(req *admissionv1.AdmissionRequest, resp *admissionv1.AdmissionResponse, cmp *comparison) {
+	if decisions == nil {
+		return
+	}
+	if decisions.closed.Load() {
+		otlpDroppedTotal.Inc()
+		return
+	}
+
+	attributes := []otlpKeyValue{
+		otlpString("admission.uid", string(req.UID)),
+		otlpString("admission.operation", string(req.Operation)),
+		otlpString("admission.kind", req.Kind.Kind),
+		otlpString("k8s.namespace.name", req.Namespace),
+		otlpString("k8s.object.name", req.Name),
+		otlpBool("decision.allowed", resp.Allowed),
+	}
+	if cmp != nil {
+		attributes = append(attributes,
+			otlpString("decision.changed_sections", strings.Join(cmp.changedSections(), ",")),
+			otlpString("decision.change_categories", strings.Join(cmp.categories(), ",")),
+		)
+	}
+
+	record := otlpLogRecord{
+		TimeUnixNano:   strconv.FormatInt(time.Now().UnixNano(), 10),
+		SeverityNumber: 9, // INFO
+		SeverityText:   "INFO",
+		Attributes:     attributes,
+	}
+	body := "admission decision"
+	record.Body.StringValue = &body
+
+	select {
+	case decisions.records <- record:
+	default:
+		otlpDroppedTotal.Inc()
+	}
+}
+
+func (e *decisionExporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]otlpLogRecord, 0, otlpBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(batch); err != nil {
+			log.Errorf("Failed to export %d decision records: %v", len(batch), err)
+			otlpDroppedTotal.Add(float64(len(batch)))
+		}
+		batch = batch[:0]
+	}
+
+	add := func(record otlpLogRecord) {
+		batch = append(batch, record)
+		if len(batch) >= otlpBatchSize {
+			flush()
+		}
+	}
+
+	for {
+		select {
+		case record := <-e.records:
+			add(record)
+		case <-ticker.C:
+			flush()
+		case <-e.stop:
+			// The records channel is never closed, as handlers cut off by
+			// a forced shutdown may still emit; drain what is queued.
+			for {
+				select {
+				case record := <-e.records:
+					add(record)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *decisionExporter) export(records []otlpLogRecord) error {
+	resourceLogs := otlpResourceLogs{ScopeLogs: []otlpScopeLogs{{LogRecords: records}}}
+	resourceLogs.Resource.Attributes = []otlpKeyValue{otlpString("service.name", "grafana-operator-webhook")}
+	resourceLogs.ScopeLogs[0].Scope.Name = "grafana-operator-webhook"
+
+	body, err := json.Marshal(otlpLogsRequest{ResourceLogs: []otlpResourceLogs{resourceLogs}})
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// shutdown stops accepting records and waits until the queued ones have been
+// exported or ctx expires. Records emitted afterwards are dropped.
+func (e *decisionExporter) shutdown(ctx context.Context) {
+	e.closed.Store(true)
+	close(e.stop)
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		log.Warn("Timed out flushing decision records")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDecisionExporter_ExportsOnShutdown(t *testing.T) {
+	received := make(chan otlpLogsRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req otlpLogsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode export: %v", err)
+		}
+		received <- req
+	}))
+	defer collector.Close()
+
+	defer func(e *decisionExporter) { decisions = e }(decisions)
+	decisions = newDecisionExporter(collector.URL)
+
+	emitDecision(
+		&admissionv1.AdmissionRequest{UID: "uid", Operation: admissionv1.Update, Kind: metav1.GroupVersionKind{Kind: "GrafanaDashboard"}, Namespace: "ns", Name: "dash"},
+		&admissionv1.AdmissionResponse{UID: "uid", Allowed: true},
+		&comparison{specChanged: true},
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	decisions.shutdown(ctx)
+
+	select {
+	case req := <-received:
+		records := req.ResourceLogs[0].ScopeLogs[0].LogRecords
+		if len(records) != 1 {
+			t.Fatalf("Expected 1 record, got %d", len(records))
+		}
+		attributes := map[string]otlpAnyValue{}
+		for _, kv := range records[0].Attributes {
+			attributes[kv.Key] = kv.Value
+		}
+		if v := attributes["decision.allowed"].BoolValue; v == nil || !*v {
+			t.Errorf("Expected decision.allowed=true, got %+v", attributes["decision.allowed"])
+		}
+		if v := attributes["decision.changed_sections"].StringValue; v == nil || *v != "spec" {
+			t.Errorf("Expected decision.changed_sections=spec, got %+v", attributes["decision.changed_sections"])
+		}
+	default:
+		t.Fatalf("Expected the record to be exported on shutdown")
+	}
+}
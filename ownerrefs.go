@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+)
+
+// verifyOwnerReferences makes the webhook deny updates that add or change an
+// ownerReference to an owner that does not exist. Owner references cannot
+// cross namespaces, so a namespaced owner must exist in the namespace of the
+// object; otherwise the garbage collector deletes the object.
+var verifyOwnerReferences = false
+
+// ownerLookupTTL is how long the result of an owner lookup is cached.
+var ownerLookupTTL = 30 * time.Second
+
+// ownerLookup reports whether the owner a reference points at exists in
+// namespace with the referenced UID.
+type ownerLookup func(ctx context.Context, namespace string, ref metav1.OwnerReference) (bool, error)
+
+// owners is the lookup used for admission requests. It is nil unless
+// verifyOwnerReferences is enabled.
+var owners ownerLookup
+
+var (
+	// Counter for owner reference checks, by result
+	ownerReferenceChecksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_owner_reference_checks_total",
+			Help: "Total number of owner references verified, differentiated by result.",
+		},
+		[]string{"result"}, // result is "valid", "invalid" or "error"
+	)
+)
+
+func init() {
+	prometheus.MustRegister(ownerReferenceChecksTotal)
+}
+
+// newOwnerLookup looks owners up with a dynamic client, mapping kinds to
+// resources through cached discovery, and caches the results.
+func newOwnerLookup(disc discovery.DiscoveryInterface, client dynamic.Interface) ownerLookup {
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(disc))
+
+	lookup := func(ctx context.Context, namespace string, ref metav1.OwnerReference) (bool, error) {
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil {
+			return false, nil
+		}
+		mapping, err := mapper.RESTMapping(gv.WithKind(ref.Kind).GroupKind(), gv.Version)
+		if meta.IsNoMatchError(err) {
+			mapper.Reset()
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		resource := client.Resource(mapping.Resource)
+		var getter dynamic.ResourceInterface = resource
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			getter = resource.Namespace(namespace)
+		}
+
+		owner, err := getter.Get(ctx, ref.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return owner.GetUID() == ref.UID, nil
+	}
+	return cachedOwnerLookup(lookup, ownerLookupTTL)
+}
+
+type ownerLookupResult struct {
+	exists  bool
+	expires time.Time
+}
+
+// cachedOwnerLookup caches successful lookups for ttl.
+func cachedOwnerLookup(lookup ownerLookup, ttl time.Duration) ownerLookup {
+	var mu sync.Mutex
+	cache := map[string]ownerLookupResult{}
+
+	return func(ctx context.Context, namespace string, ref metav1.OwnerReference) (bool, error) {
+		key := fmt.Sprintf("%s/%s/%s/%s/%s", ref.APIVersion, ref.Kind, namespace, ref.Name, ref.UID)
+		now := time.Now()
+
+		mu.Lock()
+		result, ok := cache[key]
+		mu.Unlock()
+		if ok && now.Before(result.expires) {
+			return result.exists, nil
+		}
+
+		exists, err := lookup(ctx, namespace, ref)
+		if err != nil {
+			return false, err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for k, r := range cache {
+			if now.After(r.expires) {
+				delete(cache, k)
+			}
+		}
+		cache[key] = ownerLookupResult{exists: exists, expires: now.Add(ttl)}
+		return exists, nil
+	}
+}
+
+// objectOwnerReferences decodes only metadata.ownerReferences from a raw
+// object.
+func objectOwnerReferences(raw []byte) ([]metav1.OwnerReference, error) {
+	var obj struct {
+		Metadata struct {
+			OwnerReferences []metav1.OwnerReference `json:"ownerReferences"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	return obj.Metadata.OwnerReferences, nil
+}
+
+// changedOwnerReferences returns the references of newRefs that are not
+// present unchanged in oldRefs.
+func changedOwnerReferences(oldRefs, newRefs []metav1.OwnerReference) []metav1.OwnerReference {
+	var changed []metav1.OwnerReference
+	for _, ref := range newRefs {
+		found := false
+		for _, old := range oldRefs {
+			if old.UID == ref.UID && old.APIVersion == ref.APIVersion && old.Kind == ref.Kind && old.Name == ref.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			changed = append(changed, ref)
+		}
+	}
+	return changed
+}
+
+// applyOwnerReferencePolicy denies a request that adds or changes an owner
+// reference to an owner that does not exist. Lookup failures fail open.
+func applyOwnerReferencePolicy(ctx context.Context, req *admissionv1.AdmissionRequest, resp *admissionv1.AdmissionResponse) {
+	if owners == nil || !resp.Allowed || !kindFilter.matches(req) || len(req.Object.Raw) == 0 {
+		return
+	}
+
+	var oldRefs []metav1.OwnerReference
+	if len(req.OldObject.Raw) > 0 {
+		var err error
+		if oldRefs, err = objectOwnerReferences(req.OldObject.Raw); err != nil {
+			return
+		}
+	}
+	newRefs, err := objectOwnerReferences(req.Object.Raw)
+	if err != nil {
+		return
+	}
+
+	for _, ref := range changedOwnerReferences(oldRefs, newRefs) {
+		exists, err := owners(ctx, req.Namespace, ref)
+		if err != nil {
+			ownerReferenceChecksTotal.WithLabelValues("error").Inc()
+			loggerFromContext(ctx).Errorf("Failed to look up owner %s %s: %v", ref.Kind, ref.Name, err)
+			continue
+		}
+		if exists {
+			ownerReferenceChecksTotal.WithLabelValues("valid").Inc()
+			continue
+		}
+
+		ownerReferenceChecksTotal.WithLabelValues("invalid").Inc()
+		message := fmt.Sprintf("owner %s %s with UID %s does not exist in namespace %s", ref.Kind, ref.Name, ref.UID, req.Namespace)
+		loggerFromContext(ctx).Warnf("Denied invalid owner reference: %s", message)
+
+		resp.Allowed = false
+		resp.Warnings = nil
+		resp.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Reason:  metav1.StatusReasonInvalid,
+			Message: message,
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestApplyOwnerReferencePolicy(t *testing.T) {
+	defer func(l ownerLookup) { owners = l }(owners)
+	owners = func(ctx context.Context, namespace string, ref metav1.OwnerReference) (bool, error) {
+		return namespace == "team-a" && ref.Name == "folder" && ref.UID == "folder-uid", nil
+	}
+
+	existing := `{"apiVersion": "grafana.integreatly.org/v1beta1", "kind": "GrafanaFolder", "name": "folder", "uid": "folder-uid"}`
+	missing := `{"apiVersion": "grafana.integreatly.org/v1beta1", "kind": "GrafanaFolder", "name": "gone", "uid": "gone-uid"}`
+
+	tests := []struct {
+		name          string
+		namespace     string
+		oldRefs       string
+		newRefs       string
+		expectAllowed bool
+	}{
+		{"existing owner added", "team-a", `[]`, `[` + existing + `]`, true},
+		{"missing owner added", "team-a", `[]`, `[` + missing + `]`, false},
+		{"owner in another namespace", "team-b", `[]`, `[` + existing + `]`, false},
+		{"unchanged missing owner", "team-a", `[` + missing + `]`, `[` + missing + `]`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &admissionv1.AdmissionRequest{
+				UID:       "owner-uid",
+				Namespace: tt.namespace,
+				Kind:      metav1.GroupVersionKind{Group: "grafana.integreatly.org", Version: "v1beta1", Kind: "GrafanaDashboard"},
+				Resource:  metav1.GroupVersionResource{Group: "grafana.integreatly.org", Version: "v1beta1", Resource: "grafanadashboards"},
+				Operation: admissionv1.Update,
+				OldObject: runtime.RawExtension{Raw: []byte(`{"metadata": {"ownerReferences": ` + tt.oldRefs + `}}`)},
+				Object:    runtime.RawExtension{Raw: []byte(`{"metadata": {"ownerReferences": ` + tt.newRefs + `}}`)},
+			}
+			resp := &admissionv1.AdmissionResponse{UID: req.UID, Allowed: true}
+
+			applyOwnerReferencePolicy(context.Background(), req, resp)
+
+			if resp.Allowed != tt.expectAllowed {
+				t.Errorf("Expected allowed=%t, got %t (%v)", tt.expectAllowed, resp.Allowed, resp.Result)
+			}
+		})
+	}
+}
+
+func TestCachedOwnerLookup(t *testing.T) {
+	calls := 0
+	lookup := cachedOwnerLookup(func(ctx context.Context, namespace string, ref metav1.OwnerReference) (bool, error) {
+		calls++
+		return true, nil
+	}, time.Minute)
+
+	ref := metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "owner", UID: "uid"}
+	for i := 0; i < 3; i++ {
+		if exists, err := lookup(context.Background(), "ns", ref); !exists || err != nil {
+			t.Fatalf("Expected owner to exist, got %t, %v", exists, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 lookup, got %d", calls)
+	}
+
+	lookup(context.Background(), "other", ref)
+	if calls != 2 {
+		t.Errorf("Expected a separate lookup for another namespace, got %d calls", calls)
+	}
+}
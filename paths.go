@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/errdefs"
+)
+
+// Ignore paths are written as dot paths extended with list selectors:
+//
+//	metadata.managedFields
+//	status.conditions[*].lastTransitionTime
+//	spec.datasources[0].inputName
+//	status.resources[?(@.kind=='ReplicaSet')].status
+//
+// A filter compares a dot path below the list element with a quoted string,
+// a number, true, false or null, using == or !=.
+
+type stepKind int
+
+const (
+	stepField stepKind = iota
+	stepWildcard
+	stepIndex
+	stepFilter
+)
+
+// pathStep is one element of a parsed path.
+type pathStep struct {
+	kind  stepKind
+	field string // stepField
+
+	index int // stepIndex
+
+	filterPath  []string    // stepFilter
+	filterNotEq bool        // stepFilter
+	filterValue interface{} // stepFilter
+}
+
+// fieldPath is a parsed ignore path.
+type fieldPath []pathStep
+
+// parsePath parses an ignore path expression.
+func parsePath(expr string) (fieldPath, error) {
+	var path fieldPath
+	rest := expr
+	expectField := true
+
+	for len(rest) > 0 {
+		switch {
+		case rest[0] == '[':
+			if len(path) == 0 {
+				return nil, &errdefs.RuleError{Rule: expr, Err: errors.New("selector without a field")}
+			}
+			end := strings.IndexByte(rest, ']')
+			if strings.HasPrefix(rest, "[?(") {
+				end = strings.Index(rest, ")]")
+				if end >= 0 {
+					end++
+				}
+			}
+			if end < 0 {
+				return nil, &errdefs.RuleError{Rule: expr, Err: errors.New("unterminated selector")}
+			}
+			step, err := parseSelector(rest[1:end])
+			if err != nil {
+				return nil, &errdefs.RuleError{Rule: expr, Err: err}
+			}
+			path = append(path, step)
+			rest = rest[end+1:]
+			expectField = false
+		case rest[0] == '.':
+			if expectField {
+				return nil, &errdefs.RuleError{Rule: expr, Err: errors.New("empty field name")}
+			}
+			rest = rest[1:]
+			expectField = true
+		default:
+			if !expectField {
+				return nil, &errdefs.RuleError{Rule: expr, Err: fmt.Errorf("expected '.' or '[' before %q", rest)}
+			}
+			end := strings.IndexAny(rest, ".[]")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, &errdefs.RuleError{Rule: expr, Err: fmt.Errorf("unexpected %q", rest[0])}
+			}
+			path = append(path, pathStep{kind: stepField, field: rest[:end]})
+			rest = rest[end:]
+			expectField = false
+		}
+	}
+
+	if len(path) == 0 || expectField {
+		return nil, &errdefs.RuleError{Rule: expr, Err: errors.New("empty field name")}
+	}
+	return path, nil
+}
+
+func parseSelector(selector string) (pathStep, error) {
+	if selector == "*" {
+		return pathStep{kind: stepWildcard}, nil
+	}
+
+	if strings.HasPrefix(selector, "?(") && strings.HasSuffix(selector, ")") {
+		return parseFilter(selector[2 : len(selector)-1])
+	}
+
+	index, err := strconv.Atoi(selector)
+	if err != nil || index < 0 {
+		return pathStep{}, fmt.Errorf("invalid selector [%s]", selector)
+	}
+	return pathStep{kind: stepIndex, index: index}, nil
+}
+
+func parseFilter(filter string) (pathStep, error) {
+	op, notEq := "==", false
+	pos := strings.Index(filter, "==")
+	if ne := strings.Index(filter, "!="); ne >= 0 && (pos < 0 || ne < pos) {
+		op, notEq, pos = "!=", true, ne
+	}
+	if pos < 0 {
+		return pathStep{}, fmt.Errorf("filter %q: expected == or !=", filter)
+	}
+
+	left := strings.TrimSpace(filter[:pos])
+	right := strings.TrimSpace(filter[pos+len(op):])
+
+	if !strings.HasPrefix(left, "@.") || len(left) == 2 {
+		return pathStep{}, fmt.Errorf("filter %q: left side must be @.<field>", filter)
+	}
+	fields := strings.Split(left[2:], ".")
+	for _, field := range fields {
+		if field == "" {
+			return pathStep{}, fmt.Errorf("filter %q: empty field name", filter)
+		}
+	}
+
+	value, err := parseLiteral(right)
+	if err != nil {
+		return pathStep{}, fmt.Errorf("filter %q: %w", filter, err)
+	}
+	return pathStep{kind: stepFilter, filterPath: fields, filterNotEq: notEq, filterValue: value}, nil
+}
+
+// parseLiteral parses a filter value into the type encoding/json would
+// decode it to.
+func parseLiteral(literal string) (interface{}, error) {
+	switch {
+	case len(literal) >= 2 && (literal[0] == '\'' && literal[len(literal)-1] == '\'' || literal[0] == '"' && literal[len(literal)-1] == '"'):
+		return literal[1 : len(literal)-1], nil
+	case literal == "true":
+		return true, nil
+	case literal == "false":
+		return false, nil
+	case literal == "null":
+		return nil, nil
+	}
+
+	number, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q", literal)
+	}
+	return number, nil
+}
+
+// matches reports whether a list element passes the filter step.
+func (s pathStep) matches(element interface{}) bool {
+	value := element
+	for _, field := range s.filterPath {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			value = nil
+			break
+		}
+		value = obj[field]
+	}
+
+	// Literals are never objects or lists, which are not comparable
+	equal := false
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+	default:
+		equal = value == s.filterValue
+	}
+	return equal != s.filterNotEq
+}
+
+// remove deletes every value selected by the path from obj and reports
+// whether anything was removed. List elements selected by a final selector
+// are dropped from their list.
+func (p fieldPath) remove(obj map[string]interface{}) bool {
+	_, removed := removeSteps(obj, p)
+	return removed
+}
+
+func removeSteps(value interface{}, steps fieldPath) (interface{}, bool) {
+	step, rest := steps[0], steps[1:]
+
+	if step.kind == stepField {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return value, false
+		}
+		child, exists := obj[step.field]
+		if !exists {
+			return value, false
+		}
+		if len(rest) == 0 {
+			delete(obj, step.field)
+			return obj, true
+		}
+		child, removed := removeSteps(child, rest)
+		obj[step.field] = child
+		return obj, removed
+	}
+
+	list, ok := value.([]interface{})
+	if !ok {
+		return value, false
+	}
+
+	// Kept elements are compacted in place, which the caller owns, so lists
+	// are traversed without allocating
+	removed := false
+	kept := 0
+	for i, element := range list {
+		selected := step.kind == stepWildcard ||
+			step.kind == stepIndex && i == step.index ||
+			step.kind == stepFilter && step.matches(element)
+		if selected {
+			if len(rest) == 0 {
+				removed = true
+				continue
+			}
+			var elementRemoved bool
+			element, elementRemoved = removeSteps(element, rest)
+			removed = removed || elementRemoved
+		}
+		list[kept] = element
+		kept++
+	}
+	if kept == len(list) {
+		// Boxing a new slice header would allocate
+		return value, removed
+	}
+	clear(list[kept:])
+	return list[:kept], removed
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/errdefs"
+)
+
+func TestParsePath_Grammar(t *testing.T) {
+	valid := map[string]fieldPath{
+		"metadata.generation": {
+			{kind: stepField, field: "metadata"},
+			{kind: stepField, field: "generation"},
+		},
+		"status.conditions[*].lastTransitionTime": {
+			{kind: stepField, field: "status"},
+			{kind: stepField, field: "conditions"},
+			{kind: stepWildcard},
+			{kind: stepField, field: "lastTransitionTime"},
+		},
+		"spec.datasources[2]": {
+			{kind: stepField, field: "spec"},
+			{kind: stepField, field: "datasources"},
+			{kind: stepIndex, index: 2},
+		},
+		"status.resources[?(@.kind=='ReplicaSet')].status": {
+			{kind: stepField, field: "status"},
+			{kind: stepField, field: "resources"},
+			{kind: stepFilter, filterPath: []string{"kind"}, filterValue: "ReplicaSet"},
+			{kind: stepField, field: "status"},
+		},
+		`items[?(@.meta.ready != true)]`: {
+			{kind: stepField, field: "items"},
+			{kind: stepFilter, filterPath: []string{"meta", "ready"}, filterNotEq: true, filterValue: true},
+		},
+		"matrix[*][0]": {
+			{kind: stepField, field: "matrix"},
+			{kind: stepWildcard},
+			{kind: stepIndex, index: 0},
+		},
+	}
+
+	for expr, expected := range valid {
+		path, err := parsePath(expr)
+		if err != nil {
+			t.Errorf("Expected %q to parse, got %v", expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(path, expected) {
+			t.Errorf("Expected %q to parse to %+v, got %+v", expr, expected, path)
+		}
+	}
+
+	invalid := []string{
+		"",
+		".metadata",
+		"metadata.",
+		"metadata..name",
+		"[*]",
+		"status.conditions[",
+		"status.conditions[]",
+		"status.conditions[-1]",
+		"status.conditions[x]",
+		"status.conditions[*]name",
+		"status.resources[?(kind=='ReplicaSet')]",
+		"status.resources[?(@.kind)]",
+		"status.resources[?(@.kind==ReplicaSet)]",
+		"status.resources[?(@.=='x')]",
+	}
+	for _, expr := range invalid {
+		if _, err := parsePath(expr); !errors.Is(err, errdefs.ErrRuleCompile) {
+			t.Errorf("Expected %q to be rejected with ErrRuleCompile, got %v", expr, err)
+		}
+	}
+}
+
+func TestRemoveIgnoredPaths_Selectors(t *testing.T) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(`{
+		"status": {
+			"conditions": [
+				{"type": "Ready", "lastTransitionTime": "t1"},
+				{"type": "Synced", "lastTransitionTime": "t2"}
+			],
+			"resources": [
+				{"kind": "ReplicaSet", "status": "Healthy"},
+				{"kind": "Service", "status": "Healthy"}
+			],
+			"replicas": [1, 2, 3]
+		}
+	}`), &obj); err != nil {
+		t.Fatal(err)
+	}
+
+	hits := removeIgnoredPaths([]string{
+		"status.conditions[*].lastTransitionTime",
+		"status.resources[?(@.kind=='ReplicaSet')].status",
+		"status.replicas[1]",
+		"status.resources[?(@.kind=='Pod')].status",
+	}, obj)
+
+	if len(hits) != 3 {
+		t.Errorf("Expected 3 hits, got %v", hits)
+	}
+
+	var expected map[string]interface{}
+	json.Unmarshal([]byte(`{
+		"status": {
+			"conditions": [{"type": "Ready"}, {"type": "Synced"}],
+			"resources": [{"kind": "ReplicaSet"}, {"kind": "Service", "status": "Healthy"}],
+			"replicas": [1, 3]
+		}
+	}`), &expected)
+	if !reflect.DeepEqual(obj, expected) {
+		t.Errorf("Expected %v, got %v", expected, obj)
+	}
+}
@@ -0,0 +1,72 @@
+// Package authorizer is a client for external authorizers, decision services
+// that implement the Authorizer service in authorizer.proto. Messages are
+// encoded with protowire, so no generated code is needed.
+package authorizer
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// ReviewMethod is the full gRPC method name of Authorizer.Review.
+const ReviewMethod = "/grafanaoperatorwebhook.authorizer.v1.Authorizer/Review"
+
+// Codec encodes the messages of this package. It is named "proto", as it
+// produces the protobuf wire format; servers generated from
+// authorizer.proto can decode it.
+type Codec struct{}
+
+// Marshal implements encoding.Codec.
+func (Codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("unsupported message type %T", v)
+	}
+	return m.marshal(), nil
+}
+
+// Unmarshal implements encoding.Codec.
+func (Codec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("unsupported message type %T", v)
+	}
+	return m.unmarshal(data)
+}
+
+// Name implements encoding.Codec.
+func (Codec) Name() string {
+	return "proto"
+}
+
+// Client calls an external authorizer.
+type Client struct {
+	conn *grpc.ClientConn
+}
+
+// NewClient returns a client for the authorizer at target. The connection is
+// established lazily.
+func NewClient(target string, creds credentials.TransportCredentials) (*Client, error) {
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create authorizer client for %s: %w", target, err)
+	}
+	return &Client{conn: conn}, nil
+}
+
+// Review asks the authorizer for its decision on req.
+func (c *Client) Review(ctx context.Context, req *ReviewRequest) (*Decision, error) {
+	decision := &Decision{}
+	if err := c.conn.Invoke(ctx, ReviewMethod, req, decision, grpc.ForceCodec(Codec{})); err != nil {
+		return nil, err
+	}
+	return decision, nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,96 @@
+package authorizer
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestMessages_RoundTrip(t *testing.T) {
+	req := &ReviewRequest{
+		UID:             "uid",
+		Kind:            "GrafanaDashboard",
+		Namespace:       "ns",
+		Name:            "d",
+		Operation:       "UPDATE",
+		Username:        "alice",
+		Groups:          []string{"system:authenticated", "sre"},
+		ChangedSections: []string{"spec"},
+		OldObject:       []byte(`{"spec": {}}`),
+		Object:          []byte(`{"spec": {"json": "{}"}}`),
+	}
+	var decodedReq ReviewRequest
+	if err := decodedReq.unmarshal(req.marshal()); err != nil {
+		t.Fatalf("Failed to decode review request: %v", err)
+	}
+	if !reflect.DeepEqual(&decodedReq, req) {
+		t.Errorf("Expected %+v, got %+v", req, decodedReq)
+	}
+
+	decision := &Decision{Message: "no", Code: 403, Warnings: []string{"w"}}
+	var decodedDecision Decision
+	if err := decodedDecision.unmarshal(decision.marshal()); err != nil {
+		t.Fatalf("Failed to decode decision: %v", err)
+	}
+	if !reflect.DeepEqual(&decodedDecision, decision) {
+		t.Errorf("Expected %+v, got %+v", decision, decodedDecision)
+	}
+}
+
+func TestDecision_SkipsUnknownFields(t *testing.T) {
+	b := protowire.AppendTag(nil, 99, protowire.BytesType)
+	b = protowire.AppendString(b, "future")
+	b = appendVarint(b, 1, 1)
+
+	var d Decision
+	if err := d.unmarshal(b); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !d.Allowed {
+		t.Errorf("Expected the known field to be decoded")
+	}
+
+	if err := d.unmarshal([]byte{0x0a, 0x05, 'x'}); err == nil {
+		t.Errorf("Expected a truncated message to be rejected")
+	}
+}
+
+func TestClient_Review(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	srv := grpc.NewServer(grpc.ForceServerCodec(Codec{}), grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+		if method, _ := grpc.MethodFromServerStream(stream); method != ReviewMethod {
+			t.Errorf("Expected method %s, got %s", ReviewMethod, method)
+		}
+		var req ReviewRequest
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		return stream.SendMsg(&Decision{Allowed: req.Username == "alice"})
+	}))
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	client, err := NewClient(lis.Addr().String(), insecure.NewCredentials())
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	for username, expected := range map[string]bool{"alice": true, "bob": false} {
+		decision, err := client.Review(context.Background(), &ReviewRequest{Username: username})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if decision.Allowed != expected {
+			t.Errorf("Expected allowed=%t for %s, got %t", expected, username, decision.Allowed)
+		}
+	}
+}
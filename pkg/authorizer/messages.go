@@ -0,0 +1,181 @@
+package authorizer
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ReviewRequest is the ReviewRequest message of authorizer.proto.
+type ReviewRequest struct {
+	UID              string
+	Group            string
+	Version          string
+	Kind             string
+	Namespace        string
+	Name             string
+	Operation        string
+	Username         string
+	Groups           []string
+	ChangedSections  []string
+	ChangeCategories []string
+	OldObject        []byte
+	Object           []byte
+}
+
+// Decision is the Decision message of authorizer.proto.
+type Decision struct {
+	Allowed  bool
+	Message  string
+	Code     int32
+	Warnings []string
+}
+
+// message is implemented by the messages the codec can encode.
+type message interface {
+	marshal() []byte
+	unmarshal(b []byte) error
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendStrings(b []byte, num protowire.Number, values []string) []byte {
+	for _, s := range values {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendString(b, s)
+	}
+	return b
+}
+
+func appendBytes(b []byte, num protowire.Number, value []byte) []byte {
+	if len(value) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, value)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// consumeFields calls field for every field in b. field returns the number of
+// bytes it consumed, or zero to skip an unknown field.
+func consumeFields(b []byte, field func(num protowire.Number, typ protowire.Type, b []byte) int) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		n = field(num, typ, b)
+		if n == 0 {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return fmt.Errorf("field %d: %w", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func (r *ReviewRequest) marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, r.UID)
+	b = appendString(b, 2, r.Group)
+	b = appendString(b, 3, r.Version)
+	b = appendString(b, 4, r.Kind)
+	b = appendString(b, 5, r.Namespace)
+	b = appendString(b, 6, r.Name)
+	b = appendString(b, 7, r.Operation)
+	b = appendString(b, 8, r.Username)
+	b = appendStrings(b, 9, r.Groups)
+	b = appendStrings(b, 10, r.ChangedSections)
+	b = appendStrings(b, 11, r.ChangeCategories)
+	b = appendBytes(b, 12, r.OldObject)
+	b = appendBytes(b, 13, r.Object)
+	return b
+}
+
+func (r *ReviewRequest) unmarshal(b []byte) error {
+	*r = ReviewRequest{}
+	text := map[protowire.Number]*string{
+		1: &r.UID, 2: &r.Group, 3: &r.Version, 4: &r.Kind, 5: &r.Namespace,
+		6: &r.Name, 7: &r.Operation, 8: &r.Username,
+	}
+	lists := map[protowire.Number]*[]string{9: &r.Groups, 10: &r.ChangedSections, 11: &r.ChangeCategories}
+	raw := map[protowire.Number]*[]byte{12: &r.OldObject, 13: &r.Object}
+
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		if typ != protowire.BytesType {
+			return 0
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return n
+		}
+		switch {
+		case text[num] != nil:
+			*text[num] = string(v)
+		case lists[num] != nil:
+			*lists[num] = append(*lists[num], string(v))
+		case raw[num] != nil:
+			*raw[num] = append([]byte(nil), v...)
+		}
+		return n
+	})
+}
+
+func (d *Decision) marshal() []byte {
+	var b []byte
+	if d.Allowed {
+		b = appendVarint(b, 1, 1)
+	}
+	b = appendString(b, 2, d.Message)
+	b = appendVarint(b, 3, uint64(int64(d.Code)))
+	b = appendStrings(b, 4, d.Warnings)
+	return b
+}
+
+func (d *Decision) unmarshal(b []byte) error {
+	*d = Decision{}
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch {
+		case (num == 1 || num == 3) && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return n
+			}
+			if num == 1 {
+				d.Allowed = v != 0
+			} else {
+				d.Code = int32(v)
+			}
+			return n
+		case (num == 2 || num == 4) && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			if n < 0 {
+				return n
+			}
+			if num == 2 {
+				d.Message = v
+			} else {
+				d.Warnings = append(d.Warnings, v)
+			}
+			return n
+		}
+		return 0
+	})
+}
@@ -0,0 +1,65 @@
+// Package errdefs defines the classes of errors the webhook reports, so
+// callers can map them to behaviour and HTTP statuses with errors.Is and
+// errors.As instead of matching messages.
+package errdefs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrMalformedReview is returned for review requests and objects that
+	// cannot be decoded.
+	ErrMalformedReview = errors.New("malformed review")
+
+	// ErrUnsupportedKind is returned for objects of a kind the webhook
+	// cannot handle.
+	ErrUnsupportedKind = errors.New("unsupported kind")
+
+	// ErrRuleCompile is returned for rules that do not compile.
+	ErrRuleCompile = errors.New("rule does not compile")
+
+	// ErrOversizedObject is returned for requests and objects that exceed
+	// the size or complexity limits.
+	ErrOversizedObject = errors.New("object too large or complex")
+)
+
+// RuleError describes a rule that does not compile. It matches
+// ErrRuleCompile.
+type RuleError struct {
+	Rule string
+	Err  error
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("rule %q: %v", e.Rule, e.Err)
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrRuleCompile.
+func (e *RuleError) Is(target error) bool {
+	return target == ErrRuleCompile
+}
+
+// HTTPStatus returns the HTTP status for err: 400 for malformed reviews and
+// unsupported kinds, 413 for oversized objects, 422 for rules that do not
+// compile, and 500 for anything else.
+func HTTPStatus(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrMalformedReview), errors.Is(err, ErrUnsupportedKind):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrOversizedObject):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrRuleCompile):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,45 @@
+package errdefs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{nil, http.StatusOK},
+		{fmt.Errorf("%w: failed to parse old object", ErrMalformedReview), http.StatusBadRequest},
+		{fmt.Errorf("%w: no converter", ErrUnsupportedKind), http.StatusBadRequest},
+		{fmt.Errorf("%w: more than 10 keys", ErrOversizedObject), http.StatusRequestEntityTooLarge},
+		{fmt.Errorf("kind A: %w", &RuleError{Rule: "a[", Err: errors.New("unterminated selector")}), http.StatusUnprocessableEntity},
+		{io.ErrUnexpectedEOF, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if status := HTTPStatus(tt.err); status != tt.expected {
+			t.Errorf("Expected status %d for %v, got %d", tt.expected, tt.err, status)
+		}
+	}
+}
+
+func TestRuleError(t *testing.T) {
+	cause := errors.New("unterminated selector")
+	err := fmt.Errorf("kind A: %w", &RuleError{Rule: "a[", Err: cause})
+
+	if !errors.Is(err, ErrRuleCompile) || !errors.Is(err, cause) {
+		t.Errorf("Expected %v to match ErrRuleCompile and its cause", err)
+	}
+	var ruleErr *RuleError
+	if !errors.As(err, &ruleErr) || ruleErr.Rule != "a[" {
+		t.Errorf("Expected a RuleError for a[, got %v", err)
+	}
+	if expected := `kind A: rule "a[": unterminated selector`; err.Error() != expected {
+		t.Errorf("Expected message %q, got %q", expected, err.Error())
+	}
+}
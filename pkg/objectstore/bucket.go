@@ -0,0 +1,151 @@
+// Package objectstore uploads objects to S3 and S3-compatible stores, such as
+// MinIO or Google Cloud Storage with HMAC keys. It only implements PutObject,
+// signed with AWS Signature Version 4, to avoid pulling in a cloud SDK.
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Bucket is a bucket, and optionally a key prefix within it, objects are
+// uploaded to.
+type Bucket struct {
+	endpoint  *url.URL
+	pathStyle bool
+	name      string
+	prefix    string
+	region    string
+	creds     credentials
+	client    *http.Client
+}
+
+// credentials are the access key the requests are signed with.
+type credentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// Open returns the bucket described by rawURL:
+//
+//	s3://bucket/prefix?region=eu-west-1
+//	s3://bucket/prefix?endpoint=http://minio:9000
+//	gs://bucket/prefix
+//
+// The access key is read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN; for Google Cloud Storage these hold an HMAC key.
+func Open(rawURL string) (*Bucket, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bucket URL: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("bucket URL %q has no bucket", rawURL)
+	}
+
+	b := &Bucket{
+		name:   u.Host,
+		prefix: strings.Trim(u.Path, "/"),
+		region: u.Query().Get("region"),
+		creds: credentials{
+			accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		},
+		client: &http.Client{Timeout: time.Minute},
+	}
+	if b.creds.accessKeyID == "" || b.creds.secretAccessKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+
+	endpoint := u.Query().Get("endpoint")
+	switch u.Scheme {
+	case "s3":
+		if b.region == "" {
+			b.region = os.Getenv("AWS_REGION")
+		}
+		if b.region == "" {
+			b.region = "us-east-1"
+		}
+		if endpoint == "" {
+			// Virtual-hosted addressing, unless the bucket name would not
+			// match the wildcard certificate.
+			endpoint = "https://s3." + b.region + ".amazonaws.com"
+			b.pathStyle = strings.Contains(b.name, ".")
+		} else {
+			b.pathStyle = true
+		}
+	case "gs":
+		if b.region == "" {
+			b.region = "auto"
+		}
+		if endpoint == "" {
+			endpoint = "https://storage.googleapis.com"
+		}
+		b.pathStyle = true
+	default:
+		return nil, fmt.Errorf("unsupported bucket %q, expected s3 or gs", u.Scheme)
+	}
+
+	b.endpoint, err = url.Parse(endpoint)
+	if err != nil || b.endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", endpoint)
+	}
+	return b, nil
+}
+
+// objectURL returns the URL of the object stored under key.
+func (b *Bucket) objectURL(key string) *url.URL {
+	if b.prefix != "" {
+		key = b.prefix + "/" + key
+	}
+
+	u := *b.endpoint
+	path := "/" + key
+	if b.pathStyle {
+		path = "/" + b.name + path
+	} else {
+		u.Host = b.name + "." + u.Host
+	}
+	// Keys are sent encoded the way they are signed.
+	u.Path = strings.TrimSuffix(b.endpoint.Path, "/") + path
+	u.RawPath = strings.TrimSuffix(b.endpoint.EscapedPath(), "/") + uriEncode(path, false)
+	return &u
+}
+
+// Put uploads body under key, relative to the prefix of the bucket.
+func (b *Bucket) Put(ctx context.Context, key string, body []byte, contentType, contentEncoding string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, b.objectURL(key).String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+
+	sum := sha256.Sum256(body)
+	sign(req, hex.EncodeToString(sum[:]), b.region, b.creds, time.Now())
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+	return nil
+}
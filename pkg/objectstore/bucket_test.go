@@ -0,0 +1,110 @@
+package objectstore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_REGION", "")
+
+	tests := []struct {
+		rawURL   string
+		region   string
+		expected string
+	}{
+		{"s3://bucket/snapshots/?region=eu-west-1", "eu-west-1", "https://bucket.s3.eu-west-1.amazonaws.com/snapshots/a=b/c.gz"},
+		{"s3://my.bucket", "us-east-1", "https://s3.us-east-1.amazonaws.com/my.bucket/a=b/c.gz"},
+		{"s3://bucket/p?endpoint=http://minio:9000", "us-east-1", "http://minio:9000/bucket/p/a=b/c.gz"},
+		{"gs://bucket/p", "auto", "https://storage.googleapis.com/bucket/p/a=b/c.gz"},
+	}
+	for _, test := range tests {
+		b, err := Open(test.rawURL)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", test.rawURL, err)
+			continue
+		}
+		if b.region != test.region {
+			t.Errorf("Expected region %s for %s, got %s", test.region, test.rawURL, b.region)
+		}
+		if got := b.objectURL("a=b/c.gz"); got.String() != strings.ReplaceAll(test.expected, "a=b", "a%3Db") {
+			t.Errorf("Expected %s for %s, got %s", test.expected, test.rawURL, got)
+		}
+	}
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	for _, rawURL := range []string{"azure://container", "s3://", "s3://bucket?endpoint=minio"} {
+		if _, err := Open(rawURL); err == nil {
+			t.Errorf("Expected an error for %q", rawURL)
+		}
+	}
+
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	if _, err := Open("s3://bucket"); err == nil {
+		t.Errorf("Expected an error without credentials")
+	}
+}
+
+func TestPut(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	var path, encoding, authorization, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("Expected PUT, got %s", r.Method)
+		}
+		path, encoding, authorization = r.URL.EscapedPath(), r.Header.Get("Content-Encoding"), r.Header.Get("Authorization")
+		raw, _ := io.ReadAll(r.Body)
+		body = string(raw)
+	}))
+	defer server.Close()
+
+	b, err := Open("s3://bucket/prefix?endpoint=" + server.URL)
+	if err != nil {
+		t.Fatalf("Failed to open bucket: %v", err)
+	}
+	if err := b.Put(context.Background(), "date=2026-01-02/x.ndjson.gz", []byte("data"), "application/x-ndjson", "gzip"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if path != "/bucket/prefix/date%3D2026-01-02/x.ndjson.gz" {
+		t.Errorf("Expected the object path, got %s", path)
+	}
+	if encoding != "gzip" || body != "data" {
+		t.Errorf("Expected the gzip encoded body, got %s %q", encoding, body)
+	}
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=key/") ||
+		!strings.Contains(authorization, "content-encoding;content-type;host;x-amz-content-sha256;x-amz-date") {
+		t.Errorf("Expected a signed request, got %s", authorization)
+	}
+}
+
+func TestPut_Error(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	b, err := Open("s3://bucket?endpoint=" + server.URL)
+	if err != nil {
+		t.Fatalf("Failed to open bucket: %v", err)
+	}
+	err = b.Put(context.Background(), "x", nil, "text/plain", "")
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("Expected the access denied error, got %v", err)
+	}
+}
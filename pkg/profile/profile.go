@@ -0,0 +1,74 @@
+// Package profile is the registry of kind profiles. A profile holds what the
+// webhook needs to know about one kind. Profiles register themselves from an
+// init function in their own package, so a build contains exactly the
+// profiles it imports, and a fork can add a private profile in a file of its
+// own.
+package profile
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Profile describes one kind the webhook can validate.
+type Profile struct {
+	Group    string
+	Version  string
+	Resource string
+	Kind     string
+
+	// IgnorePaths are removed from both objects before comparing them,
+	// because they change without user intent.
+	IgnorePaths []string
+
+	// EmbeddedJSONPaths are string fields holding a JSON document, which
+	// are compared by content.
+	EmbeddedJSONPaths []string
+
+	// SourceFields say where an object comes from and where it goes.
+	// Changes to them are counted per field.
+	SourceFields []string
+}
+
+var (
+	mu       sync.RWMutex
+	profiles = map[string]Profile{}
+)
+
+// Register adds p to the registry. It panics if p has no kind or a profile
+// for the same kind is already registered, as both are build mistakes.
+func Register(p Profile) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if p.Kind == "" {
+		panic("profile: kind is required")
+	}
+	if _, exists := profiles[p.Kind]; exists {
+		panic(fmt.Sprintf("profile: %s registered twice", p.Kind))
+	}
+	profiles[p.Kind] = p
+}
+
+// Lookup returns the profile for kind.
+func Lookup(kind string) (Profile, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	p, ok := profiles[kind]
+	return p, ok
+}
+
+// Kinds returns the kinds of all registered profiles, sorted.
+func Kinds() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	kinds := make([]string, 0, len(profiles))
+	for kind := range profiles {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
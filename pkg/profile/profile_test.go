@@ -0,0 +1,28 @@
+package profile
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	Register(Profile{Kind: "Widget", IgnorePaths: []string{"status.observedAt"}})
+
+	p, ok := Lookup("Widget")
+	if !ok || len(p.IgnorePaths) != 1 {
+		t.Errorf("Expected the registered profile, got %+v (found=%t)", p, ok)
+	}
+	if _, ok := Lookup("Gadget"); ok {
+		t.Errorf("Expected no profile for an unregistered kind")
+	}
+	if !slices.Contains(Kinds(), "Widget") {
+		t.Errorf("Expected Widget in %v", Kinds())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected registering a kind twice to panic")
+		}
+	}()
+	Register(Profile{Kind: "Widget"})
+}
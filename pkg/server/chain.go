@@ -0,0 +1,82 @@
+// Package server composes the HTTP middleware that wraps the webhook
+// handlers. Cross-cutting concerns are registered per stage and always
+// applied in the same order, so the outermost middleware is the recovery
+// stage and the innermost is the metrics stage, regardless of the order in
+// which they were added.
+package server
+
+import "net/http"
+
+// Middleware wraps an http.Handler.
+type Middleware func(http.Handler) http.Handler
+
+// Stage is a position in the middleware chain.
+type Stage int
+
+// Stages from outermost to innermost.
+const (
+	StageRecovery Stage = iota
+	StageAccessLog
+	StageAuth
+	StageLimits
+	StageMetrics
+
+	numStages
+)
+
+var stageNames = [numStages]string{"recovery", "access-log", "auth", "limits", "metrics"}
+
+func (s Stage) String() string {
+	if s < 0 || s >= numStages {
+		return "unknown"
+	}
+	return stageNames[s]
+}
+
+// Chain builds handlers wrapped in middleware ordered by stage. Within a
+// stage, middleware runs in the order it was added. The zero value is an
+// empty chain.
+type Chain struct {
+	stages [numStages][]Middleware
+}
+
+// NewChain returns an empty chain.
+func NewChain() *Chain {
+	return &Chain{}
+}
+
+// Use adds middleware to a stage and returns the chain for further calls.
+// It panics on an unknown stage, which is a programming error.
+func (c *Chain) Use(stage Stage, mw ...Middleware) *Chain {
+	if stage < 0 || stage >= numStages {
+		panic("server: unknown middleware stage")
+	}
+	c.stages[stage] = append(c.stages[stage], mw...)
+	return c
+}
+
+// Clone returns a copy of the chain that can be extended without affecting
+// the original.
+func (c *Chain) Clone() *Chain {
+	clone := &Chain{}
+	for stage, mws := range c.stages {
+		clone.stages[stage] = append([]Middleware(nil), mws...)
+	}
+	return clone
+}
+
+// Then wraps h in every middleware of the chain.
+func (c *Chain) Then(h http.Handler) http.Handler {
+	for stage := numStages - 1; stage >= 0; stage-- {
+		mws := c.stages[stage]
+		for i := len(mws) - 1; i >= 0; i-- {
+			h = mws[i](h)
+		}
+	}
+	return h
+}
+
+// ThenFunc wraps f in every middleware of the chain.
+func (c *Chain) ThenFunc(f http.HandlerFunc) http.Handler {
+	return c.Then(f)
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func tracing(name string, trace *[]string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*trace = append(*trace, name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestChain_OrdersByStage(t *testing.T) {
+	var trace []string
+	chain := NewChain().
+		Use(StageAuth, tracing("auth", &trace)).
+		Use(StageRecovery, tracing("recovery", &trace)).
+		Use(StageLimits, tracing("limits-1", &trace), tracing("limits-2", &trace)).
+		Use(StageMetrics, tracing("metrics", &trace)).
+		Use(StageAccessLog, tracing("access-log", &trace))
+
+	handler := chain.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "handler")
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	expected := []string{"recovery", "access-log", "auth", "limits-1", "limits-2", "metrics", "handler"}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected order %v, got %v", expected, trace)
+	}
+}
+
+func TestChain_Clone(t *testing.T) {
+	var trace []string
+	base := NewChain().Use(StageRecovery, tracing("recovery", &trace))
+	extended := base.Clone().Use(StageAuth, tracing("auth", &trace))
+
+	noop := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	base.Then(noop).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if expected := []string{"recovery"}; !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected the original chain to be unchanged, got %v", trace)
+	}
+
+	trace = nil
+	extended.Then(noop).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if expected := []string{"recovery", "auth"}; !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected %v, got %v", expected, trace)
+	}
+}
+
+func TestChain_UnknownStagePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected a panic for an unknown stage")
+		}
+	}()
+	NewChain().Use(Stage(42))
+}
+
+func TestRecovery(t *testing.T) {
+	var recovered interface{}
+	handler := Recovery(func(r *http.Request, v interface{}) { recovered = v })(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("boom") }),
+	)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code 500, got %d", w.Code)
+	}
+	if recovered != "boom" {
+		t.Errorf("Expected the panic value to be reported, got %v", recovered)
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var entry AccessEntry
+	handler := AccessLog(func(e AccessEntry) { entry = e })(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte("short and stout"))
+		}),
+	)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/validate", nil))
+
+	if entry.Method != http.MethodPost || entry.Path != "/validate" || entry.Status != http.StatusTeapot || entry.Bytes != 15 {
+		t.Errorf("Unexpected access entry: %+v", entry)
+	}
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	handler := MaxBodyBytes(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 16)
+		if _, err := r.Body.Read(buf); err == nil {
+			t.Errorf("Expected reading past the limit to fail")
+		}
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader("0123456789")))
+}
+
+func TestBearerToken(t *testing.T) {
+	handler := BearerToken("s3cret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		expected      int
+	}{
+		{"valid token", "Bearer s3cret", http.StatusTeapot},
+		{"missing header", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer guess", http.StatusUnauthorized},
+		{"wrong scheme", "Basic s3cret", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status code %d, got %d", tt.expected, w.Code)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("Expected a Bearer challenge, got %q", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	var entry AccessEntry
+	handler := Metrics(func(e AccessEntry) { entry = e })(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "too large", http.StatusRequestEntityTooLarge)
+		}),
+	)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/validate", nil))
+
+	if entry.Path != "/validate" || entry.Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("Unexpected metrics entry: %+v", entry)
+	}
+}
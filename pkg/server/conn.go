@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+type connRequestsKey struct{}
+
+// CountConnRequests is an http.Server ConnContext hook that gives every
+// connection the request counter MaxRequestsPerConn relies on.
+func CountConnRequests(ctx context.Context, _ net.Conn) context.Context {
+	return context.WithValue(ctx, connRequestsKey{}, new(atomic.Int64))
+}
+
+// MaxRequestsPerConn asks the client to reconnect once a connection has
+// served n requests, by answering the nth with "Connection: close". An
+// HTTP/1.1 connection is then closed after the response, and an HTTP/2
+// connection is sent a GOAWAY, so the client finishes its streams and opens
+// a new connection that may land on another replica. onRecycle is called for
+// every recycled connection. Requests on connections without a counter from
+// CountConnRequests are not limited.
+func MaxRequestsPerConn(n int64, onRecycle func()) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requests, ok := r.Context().Value(connRequestsKey{}).(*atomic.Int64); ok && requests.Add(1) == n {
+				w.Header().Set("Connection", "close")
+				if onRecycle != nil {
+					onRecycle()
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
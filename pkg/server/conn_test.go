@@ -0,0 +1,68 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"testing"
+	"time"
+)
+
+func TestMaxRequestsPerConn(t *testing.T) {
+	recycled := 0
+	handler := MaxRequestsPerConn(3, func() { recycled++ })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, http2 := range []bool{false, true} {
+		recycled = 0
+		server := httptest.NewUnstartedServer(handler)
+		server.Config.ConnContext = CountConnRequests
+		server.EnableHTTP2 = http2
+		server.StartTLS()
+
+		client := server.Client()
+		for i := 1; i <= 4; i++ {
+			reused := false
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+				GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused },
+			}))
+			if i == 4 {
+				// Give the client time to process the GOAWAY
+				time.Sleep(50 * time.Millisecond)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Request %d failed: %v", i, err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			if http2 && resp.ProtoMajor != 2 {
+				t.Fatalf("Expected HTTP/2, got %s", resp.Proto)
+			}
+			if !http2 && resp.Close != (i == 3) {
+				t.Errorf("Expected only request 3 to close the connection, request %d did: %t", i, resp.Close)
+			}
+			if expected := i == 2 || i == 3; reused != expected {
+				t.Errorf("Expected request %d with HTTP/2 %t to reuse the connection: %t, got %t", i, http2, expected, reused)
+			}
+		}
+		server.Close()
+
+		if recycled != 1 {
+			t.Errorf("Expected 1 recycled connection with HTTP/2 %t, got %d", http2, recycled)
+		}
+	}
+}
+
+func TestMaxRequestsPerConn_WithoutCounter(t *testing.T) {
+	recycled := 0
+	handler := MaxRequestsPerConn(1, func() { recycled++ })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if recycled != 0 || w.Header().Get("Connection") != "" {
+		t.Errorf("Expected requests without a counter not to be limited")
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Recovery turns a panic in the wrapped handler into a 500 response and
+// reports it to onPanic. http.ErrAbortHandler is re-raised so the server can
+// abort the connection as intended.
+func Recovery(onPanic func(r *http.Request, recovered interface{})) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+				if onPanic != nil {
+					onPanic(r, recovered)
+				}
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// BearerToken rejects requests that do not carry token in an
+// "Authorization: Bearer" header with 401. The token is compared in constant
+// time. It must not be empty.
+func BearerToken(token string) Middleware {
+	expected := []byte(token)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(presented), expected) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// AccessEntry describes one served request.
+type AccessEntry struct {
+	Method   string
+	Path     string
+	Status   int
+	Bytes    int
+	Duration time.Duration
+}
+
+// AccessLog reports every request to logf once it has been served.
+func AccessLog(logf func(AccessEntry)) Middleware {
+	return observeServed(logf)
+}
+
+// Metrics reports every request to observe once it has been served. In the
+// metrics stage it sees only requests that passed auth and the limits, and
+// its duration covers the handler alone.
+func Metrics(observe func(AccessEntry)) Middleware {
+	return observeServed(observe)
+}
+
+func observeServed(report func(AccessEntry)) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			report(AccessEntry{
+				Method:   r.Method,
+				Path:     r.URL.Path,
+				Status:   rec.status,
+				Bytes:    rec.bytes,
+				Duration: time.Since(start),
+			})
+		})
+	}
+}
+
+// MaxBodyBytes limits request bodies to n bytes. Reading past the limit
+// fails with an *http.MaxBytesError, on which handlers answer 413.
+func MaxBodyBytes(n int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// statusRecorder captures the status code and size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Flush forwards to the underlying writer so streaming handlers keep working
+// behind AccessLog.
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
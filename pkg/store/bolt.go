@@ -0,0 +1,141 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var boltBucket = []byte("state")
+
+// Bolt is a Store kept in a bbolt file, so state survives restarts. The file
+// is locked by one process at a time, so it cannot be shared by replicas.
+// Each value is prefixed with its expiry in Unix nanoseconds, zero meaning
+// none.
+type Bolt struct {
+	db *bolt.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// OpenBolt opens or creates the bbolt file at path.
+func OpenBolt(path string) (*Bolt, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize %s: %w", path, err)
+	}
+	return &Bolt{db: db}, nil
+}
+
+// Get implements Store.
+func (b *Bolt) Get(_ context.Context, key string) ([]byte, error) {
+	var value []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		v, ok := decodeBoltValue(tx.Bucket(boltBucket).Get([]byte(key)), time.Now())
+		if !ok {
+			return ErrNotFound
+		}
+		value = bytes.Clone(v)
+		return nil
+	})
+	return value, err
+}
+
+// Set implements Store.
+func (b *Bolt) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return b.Update(ctx, key, ttl, func([]byte) ([]byte, error) {
+		return value, nil
+	})
+}
+
+// Update implements Store.
+func (b *Bolt) Update(_ context.Context, key string, ttl time.Duration, fn func(old []byte) ([]byte, error)) error {
+	now := time.Now()
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		old, ok := decodeBoltValue(bucket.Get([]byte(key)), now)
+		if ok {
+			old = bytes.Clone(old)
+		}
+		value, err := fn(old)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(key), encodeBoltValue(value, expiry(now, ttl)))
+	})
+	if err != nil {
+		return err
+	}
+	return b.sweep(now)
+}
+
+// Close implements Store.
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
+
+// sweep deletes expired keys at most once per sweepInterval.
+func (b *Bolt) sweep(now time.Time) error {
+	b.mu.Lock()
+	if now.Sub(b.lastSweep) < sweepInterval {
+		b.mu.Unlock()
+		return nil
+	}
+	b.lastSweep = now
+	b.mu.Unlock()
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltBucket).Cursor()
+		for k, v := c.First(); k != nil; {
+			if _, ok := decodeBoltValue(v, now); ok {
+				k, v = c.Next()
+				continue
+			}
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			// Delete moves the cursor to the next key
+			k, v = c.Seek(k)
+		}
+		return nil
+	})
+}
+
+func encodeBoltValue(value []byte, expires time.Time) []byte {
+	buf := make([]byte, 8+len(value))
+	if !expires.IsZero() {
+		binary.BigEndian.PutUint64(buf, uint64(expires.UnixNano()))
+	}
+	copy(buf[8:], value)
+	return buf
+}
+
+// decodeBoltValue returns the value in raw if it exists and has not expired
+// at now.
+func decodeBoltValue(raw []byte, now time.Time) ([]byte, bool) {
+	if len(raw) < 8 {
+		return nil, false
+	}
+	var expires time.Time
+	if nanos := binary.BigEndian.Uint64(raw); nanos != 0 {
+		expires = time.Unix(0, int64(nanos))
+	}
+	if expired(expires, now) {
+		return nil, false
+	}
+	return raw[8:], true
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often expired keys are dropped from local stores.
+const sweepInterval = time.Minute
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// Memory is a Store held in process memory.
+type Memory struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+// NewMemory returns an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]memoryEntry)}
+}
+
+// Get implements Store.
+func (m *Memory) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok || expired(entry.expires, time.Now()) {
+		return nil, ErrNotFound
+	}
+	return entry.value, nil
+}
+
+// Set implements Store.
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(now)
+	m.entries[key] = memoryEntry{value: value, expires: expiry(now, ttl)}
+	return nil
+}
+
+// Update implements Store.
+func (m *Memory) Update(_ context.Context, key string, ttl time.Duration, fn func(old []byte) ([]byte, error)) error {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(now)
+
+	var old []byte
+	if entry, ok := m.entries[key]; ok && !expired(entry.expires, now) {
+		old = entry.value
+	}
+	value, err := fn(old)
+	if err != nil {
+		return err
+	}
+	m.entries[key] = memoryEntry{value: value, expires: expiry(now, ttl)}
+	return nil
+}
+
+// DeletePrefix drops every key starting with prefix, so memory held by
+// entries that are only worth keeping as a cache can be freed before they
+// expire.
+func (m *Memory) DeletePrefix(prefix string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key := range m.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(m.entries, key)
+		}
+	}
+}
+
+// Close implements Store.
+func (m *Memory) Close() error {
+	return nil
+}
+
+// sweep drops expired entries at most once per sweepInterval.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	for key, entry := range m.entries {
+		if expired(entry.expires, now) {
+			delete(m.entries, key)
+		}
+	}
+	m.lastSweep = now
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisUpdateAttempts bounds how often Update retries a transaction that
+// lost a race with another replica.
+const redisUpdateAttempts = 10
+
+// Redis is a Store shared by every replica connected to the same Redis.
+type Redis struct {
+	client *redis.Client
+}
+
+// OpenRedis connects to the Redis server described by rawURL.
+func OpenRedis(rawURL string) (*Redis, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	return &Redis{client: redis.NewClient(opts)}, nil
+}
+
+// Get implements Store.
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+// Set implements Store.
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, max(ttl, 0)).Err()
+}
+
+// Update implements Store. It watches key and retries if another client
+// modified it before the transaction was committed.
+func (r *Redis) Update(ctx context.Context, key string, ttl time.Duration, fn func(old []byte) ([]byte, error)) error {
+	txf := func(tx *redis.Tx) error {
+		old, err := tx.Get(ctx, key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			old = nil
+		} else if err != nil {
+			return err
+		}
+
+		value, err := fn(old)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, value, max(ttl, 0))
+			return nil
+		})
+		return err
+	}
+
+	for range redisUpdateAttempts {
+		err := r.client.Watch(ctx, txf, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("update of %s conflicted %d times", key, redisUpdateAttempts)
+}
+
+// Close implements Store.
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,74 @@
+// Package store keeps the state the webhook shares between requests, such as
+// cached responses and rate limit buckets. The in-memory backend needs no
+// dependencies and keeps state per replica; the bbolt backend keeps it across
+// restarts, and the Redis backend shares it between replicas.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// ErrNotFound is returned by Get for keys that do not exist or have expired.
+var ErrNotFound = errors.New("key not found")
+
+// Store is a key-value store with per-key expiry. A ttl of zero keeps the key
+// until it is overwritten.
+type Store interface {
+	// Get returns the value of key, or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Set stores value for key.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Update atomically replaces the value of key with the result of fn,
+	// which receives the current value, or nil if there is none. fn may be
+	// called more than once if the key is modified concurrently. If fn
+	// returns an error the value is left unchanged.
+	Update(ctx context.Context, key string, ttl time.Duration, fn func(old []byte) ([]byte, error)) error
+
+	// Close releases the resources held by the store.
+	Close() error
+}
+
+// Open returns the store described by rawURL:
+//
+//	memory://
+//	bolt:///var/lib/grafana-operator-webhook/state.db
+//	redis://redis:6379/0
+func Open(rawURL string) (Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid store URL: %w", err)
+	}
+
+	switch u.Scheme {
+	case "memory":
+		return NewMemory(), nil
+	case "bolt":
+		if u.Path == "" {
+			return nil, fmt.Errorf("bolt store URL %q has no path", rawURL)
+		}
+		return OpenBolt(u.Path)
+	case "redis", "rediss":
+		return OpenRedis(rawURL)
+	default:
+		return nil, fmt.Errorf("unsupported store %q, expected memory, bolt or redis", u.Scheme)
+	}
+}
+
+// expiry returns when a key stored at now with ttl expires, or the zero time.
+func expiry(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+// expired reports whether a key expiring at expires has expired at now.
+func expired(expires, now time.Time) bool {
+	return !expires.IsZero() && !now.Before(expires)
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testStore runs the conformance tests every backend must pass. Keys are
+// prefixed with the test name, so a shared Redis can be reused across runs.
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	prefix := t.Name() + "/" + strconv.FormatInt(time.Now().UnixNano(), 36) + "/"
+
+	t.Run("missing key", func(t *testing.T) {
+		if _, err := s.Get(ctx, prefix+"missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("set and get", func(t *testing.T) {
+		if err := s.Set(ctx, prefix+"key", []byte("value"), 0); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		value, err := s.Get(ctx, prefix+"key")
+		if err != nil || string(value) != "value" {
+			t.Errorf("Expected value, got %q, %v", value, err)
+		}
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		if err := s.Set(ctx, prefix+"expiring", []byte("value"), 50*time.Millisecond); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if _, err := s.Get(ctx, prefix+"expiring"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected the key to expire, got %v", err)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		key := prefix + "updated"
+		for _, expectedOld := range []string{"", "1"} {
+			err := s.Update(ctx, key, time.Minute, func(old []byte) ([]byte, error) {
+				if string(old) != expectedOld {
+					t.Errorf("Expected old value %q, got %q", expectedOld, old)
+				}
+				return []byte("1"), nil
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+
+		failure := errors.New("failure")
+		err := s.Update(ctx, key, time.Minute, func([]byte) ([]byte, error) {
+			return []byte("2"), failure
+		})
+		if !errors.Is(err, failure) {
+			t.Errorf("Expected the error from fn, got %v", err)
+		}
+		if value, _ := s.Get(ctx, key); string(value) != "1" {
+			t.Errorf("Expected a failed update to keep the value, got %q", value)
+		}
+	})
+
+	t.Run("concurrent updates", func(t *testing.T) {
+		key := prefix + "counter"
+		var wg sync.WaitGroup
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := s.Update(ctx, key, time.Minute, func(old []byte) ([]byte, error) {
+					n, _ := strconv.Atoi(string(old))
+					return []byte(strconv.Itoa(n + 1)), nil
+				})
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if value, _ := s.Get(ctx, key); string(value) != "20" {
+			t.Errorf("Expected 20 updates, got %q", value)
+		}
+	})
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestBolt(t *testing.T) {
+	s, err := Open("bolt://" + filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	testStore(t, s)
+}
+
+// TestRedis runs against the server in GRAFANA_OPERATOR_WEBHOOK_TEST_REDIS_URL.
+func TestRedis(t *testing.T) {
+	rawURL := os.Getenv("GRAFANA_OPERATOR_WEBHOOK_TEST_REDIS_URL")
+	if rawURL == "" {
+		t.Skip("GRAFANA_OPERATOR_WEBHOOK_TEST_REDIS_URL is not set")
+	}
+	s, err := Open(rawURL)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	testStore(t, s)
+}
+
+func TestOpen_Unsupported(t *testing.T) {
+	for _, rawURL := range []string{"etcd://localhost", "bolt://"} {
+		if _, err := Open(rawURL); err == nil {
+			t.Errorf("Expected an error for %q", rawURL)
+		}
+	}
+}
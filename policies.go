@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// policyDocument is the /policies view of the active rules, written for the
+// teams whose objects the webhook admits rather than for its operators.
+type policyDocument struct {
+	GeneratedAt   time.Time          `json:"generatedAt"`
+	Kinds         []kindPolicy       `json:"kinds"`
+	DecisionModes decisionModePolicy `json:"decisionModes"`
+	Enforcement   []enforcedPolicy   `json:"enforcement"`
+	Freezes       []freezePolicy     `json:"freezes,omitempty"`
+}
+
+// kindPolicy is what the webhook compares and enforces for one kind.
+type kindPolicy struct {
+	Kind              string            `json:"kind"`
+	IgnoredFields     []ignoredField    `json:"ignoredFields"`
+	FinalizerPolicies []finalizerPolicy `json:"finalizerPolicies,omitempty"`
+	Canary            *canaryPolicy     `json:"canary,omitempty"`
+}
+
+// ignoredField is a field whose changes alone do not make an update
+// significant, and the rule source that configured it.
+type ignoredField struct {
+	Path   string `json:"path"`
+	Source string `json:"source"`
+}
+
+// canaryPolicy is a ruleset being rolled out to a share of the requests.
+type canaryPolicy struct {
+	Percent       int            `json:"percent"`
+	IgnoredFields []ignoredField `json:"ignoredFields"`
+}
+
+// decisionModePolicy describes how no-op updates are answered.
+type decisionModePolicy struct {
+	Default             string            `json:"default"`
+	NamespaceAnnotation string            `json:"namespaceAnnotation,omitempty"`
+	Modes               map[string]string `json:"modes"`
+}
+
+// enforcedPolicy is one enabled check, in plain words.
+type enforcedPolicy struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// freezePolicy is one change freeze window.
+type freezePolicy struct {
+	Name       string                `json:"name"`
+	Schedule   string                `json:"schedule,omitempty"`
+	Duration   string                `json:"duration,omitempty"`
+	TimeZone   string                `json:"timeZone,omitempty"`
+	Start      *time.Time            `json:"start,omitempty"`
+	End        *time.Time            `json:"end,omitempty"`
+	Namespaces []string              `json:"namespaces,omitempty"`
+	Selector   *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// buildPolicyDocument renders the rules in memory at now.
+func buildPolicyDocument(now time.Time) policyDocument {
+	doc := policyDocument{
+		GeneratedAt: now,
+		Kinds:       []kindPolicy{},
+		DecisionModes: decisionModePolicy{
+			Default: decisionMode,
+			Modes: map[string]string{
+				decisionModeDeny:      "No-op updates are rejected with a success status, so the client does not retry and nothing is written.",
+				decisionModeAllowWarn: "No-op updates are allowed with a warning naming the decision that was skipped.",
+			},
+		},
+		Enforcement: enabledPolicies(),
+	}
+	if namespaceModeOverrides {
+		doc.DecisionModes.NamespaceAnnotation = namespaceModeAnnotation
+	}
+
+	ruleLayersMu.RLock()
+	for kind, rules := range mergedRules {
+		policy := kindPolicy{
+			Kind:              kind,
+			IgnoredFields:     ignoredFields(rules),
+			FinalizerPolicies: rules.FinalizerPolicies,
+		}
+		if canary, ok := canaryRules[kind]; ok && canaryPercent > 0 {
+			policy.Canary = &canaryPolicy{Percent: canaryPercent, IgnoredFields: ignoredFields(canary)}
+		}
+		doc.Kinds = append(doc.Kinds, policy)
+	}
+	ruleLayersMu.RUnlock()
+	sort.Slice(doc.Kinds, func(i, j int) bool { return doc.Kinds[i].Kind < doc.Kinds[j].Kind })
+
+	if freezes != nil {
+		for _, w := range freezes.Windows {
+			doc.Freezes = append(doc.Freezes, freezePolicy{
+				Name:       w.Name,
+				Schedule:   w.Schedule,
+				Duration:   w.Duration,
+				TimeZone:   w.TimeZone,
+				Start:      w.Start,
+				End:        w.End,
+				Namespaces: w.Namespaces,
+				Selector:   w.Selector,
+			})
+		}
+	}
+	return doc
+}
+
+func ignoredFields(rules mergedKindRules) []ignoredField {
+	fields := make([]ignoredField, 0, len(rules.IgnorePaths))
+	for _, path := range rules.IgnorePaths {
+		fields = append(fields, ignoredField{Path: path, Source: rules.Sources[path]})
+	}
+	return fields
+}
+
+// enabledPolicies describes the checks the current flags enable.
+func enabledPolicies() []enforcedPolicy {
+	policies := []enforcedPolicy{{
+		Name:        "no-op-updates",
+		Description: "Updates that change nothing but ignored fields are answered according to the decision mode.",
+	}}
+	if specChangeRateLimit > 0 {
+		policies = append(policies, enforcedPolicy{
+			Name:        "spec-change-rate-limit",
+			Description: fmt.Sprintf("At most %d spec changes per object are admitted every %s.", specChangeRateLimit, specChangeRateWindow),
+		})
+	}
+	if freezes != nil && len(freezes.Windows) > 0 {
+		description := fmt.Sprintf("Spec changes are denied during the freeze windows. Setting the %s annotation to \"true\" bypasses a freeze.", freezes.BreakGlassAnnotation)
+		if len(freezes.BreakGlassGroups) > 0 {
+			description += fmt.Sprintf(" Members of %s bypass freezes as well.", strings.Join(freezes.BreakGlassGroups, ", "))
+		}
+		if freezes.CalendarURL != "" {
+			description += " Calendar: " + freezes.CalendarURL
+		}
+		policies = append(policies, enforcedPolicy{Name: "change-freezes", Description: description})
+	}
+	if rejectInvalidEmbeddedJSON {
+		policies = append(policies, enforcedPolicy{
+			Name:        "embedded-json",
+			Description: fmt.Sprintf("Updates whose embedded JSON documents (%s) do not parse are denied.", strings.Join(embeddedJSONPaths, ", ")),
+		})
+	}
+	if verifyOwnerReferences {
+		policies = append(policies, enforcedPolicy{
+			Name:        "owner-references",
+			Description: "Updates adding owner references to objects that do not exist are denied.",
+		})
+	}
+	if maxObjectDepth > 0 || maxObjectKeys > 0 {
+		policies = append(policies, enforcedPolicy{
+			Name:        "object-complexity",
+			Description: fmt.Sprintf("Objects nested deeper than %d levels or with more than %d keys are admitted without comparison (0 means no limit).", maxObjectDepth, maxObjectKeys),
+		})
+	}
+	if denyLoopThreshold > 0 {
+		policies = append(policies, enforcedPolicy{
+			Name:        "deny-loop-backoff",
+			Description: fmt.Sprintf("Once an object's no-op updates are denied %d times within %s, they are allowed with a warning for %s.", denyLoopThreshold, denyLoopWindow, denyLoopCooldown),
+		})
+	}
+	if len(downstreamURLs) > 0 {
+		policies = append(policies, enforcedPolicy{
+			Name:        "downstream-webhooks",
+			Description: fmt.Sprintf("Allowed updates are also checked by %d downstream webhooks.", len(downstreamURLs)),
+		})
+	}
+	if authorizerAddress != "" {
+		policies = append(policies, enforcedPolicy{
+			Name:        "external-authorizer",
+			Description: "Allowed updates are also checked by an external authorizer.",
+		})
+	}
+	if maintenanceMode.Load() {
+		policies = append(policies, enforcedPolicy{
+			Name:        "maintenance-mode",
+			Description: "The webhook is in maintenance mode and allows every request.",
+		})
+	}
+	return policies
+}
+
+var policiesTemplate = template.Must(template.New("policies").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>grafana-operator-webhook policies</title></head>
+<body>
+<h1>Admission policies</h1>
+<p>Generated from the active rules at {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}.</p>
+{{range .Kinds}}
+<h2>{{.Kind}}</h2>
+<p>Changes to these fields alone do not make an update significant:</p>
+<ul>{{range .IgnoredFields}}<li><code>{{.Path}}</code> ({{.Source}})</li>{{end}}</ul>
+{{if .FinalizerPolicies}}<p>Finalizer policies:</p>
+<ul>{{range .FinalizerPolicies}}<li><code>{{.Finalizer}}</code>{{if .AddUsers}}, added by {{range $i, $u := .AddUsers}}{{if $i}}, {{end}}{{$u}}{{end}}{{end}}{{if .RemoveUsers}}, removed by {{range $i, $u := .RemoveUsers}}{{if $i}}, {{end}}{{$u}}{{end}}{{end}}</li>{{end}}</ul>{{end}}
+{{with .Canary}}<p>A canary ruleset applies to {{.Percent}}% of requests, ignoring:</p>
+<ul>{{range .IgnoredFields}}<li><code>{{.Path}}</code> ({{.Source}})</li>{{end}}</ul>{{end}}
+{{end}}
+<h2>Decision modes</h2>
+<p>Default mode: <strong>{{.DecisionModes.Default}}</strong>.{{with .DecisionModes.NamespaceAnnotation}} A namespace selects another mode with the <code>{{.}}</code> annotation.{{end}}</p>
+<dl>{{range $mode, $description := .DecisionModes.Modes}}<dt>{{$mode}}</dt><dd>{{$description}}</dd>{{end}}</dl>
+<h2>Enforcement</h2>
+<dl>{{range .Enforcement}}<dt>{{.Name}}</dt><dd>{{.Description}}</dd>{{end}}</dl>
+{{if .Freezes}}<h2>Change freezes</h2>
+<ul>{{range .Freezes}}<li><strong>{{.Name}}</strong>{{if .Schedule}}: {{.Schedule}} for {{.Duration}}{{with .TimeZone}} ({{.}}){{end}}{{end}}{{if .Start}}: {{.Start}} to {{.End}}{{end}}{{if .Namespaces}}, in {{range $i, $ns := .Namespaces}}{{if $i}}, {{end}}{{$ns}}{{end}}{{end}}</li>{{end}}</ul>{{end}}
+</body>
+</html>
+`))
+
+// handlePolicies serves the active policies to the app teams, as HTML for
+// browsers and as JSON otherwise. ?format=html or ?format=json overrides the
+// Accept header.
+func handlePolicies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	doc := buildPolicyDocument(time.Now())
+
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		format = "html"
+	}
+	if format == "html" {
+		var buf bytes.Buffer
+		if err := policiesTemplate.Execute(&buf, doc); err != nil {
+			log.Errorf("Failed to render policies: %v", err)
+			http.Error(w, "failed to render response", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			log.Errorf("Failed to write policies: %v", err)
+		}
+		return
+	}
+
+	responseBytes, err := json.Marshal(doc)
+	if err != nil {
+		log.Errorf("Failed to marshal policies: %v", err)
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, responseBytes)
+}
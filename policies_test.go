@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildPolicyDocument(t *testing.T) {
+	defer func(limit int) { specChangeRateLimit = limit }(specChangeRateLimit)
+	defer func(f *freezeConfig) { freezes = f }(freezes)
+	specChangeRateLimit = 3
+	freezes = &freezeConfig{
+		BreakGlassAnnotation: defaultBreakGlassAnnotation,
+		Windows:              []freezeWindow{{Name: "year-end", Schedule: "0 0 20 12 *", Duration: "336h"}},
+	}
+
+	doc := buildPolicyDocument(time.Now())
+
+	if len(doc.Kinds) == 0 || doc.Kinds[0].Kind != kindFilter.kind {
+		t.Fatalf("Expected the %s policy, got %+v", kindFilter.kind, doc.Kinds)
+	}
+	fields := doc.Kinds[0].IgnoredFields
+	if len(fields) == 0 || fields[0].Source != ruleSourceDefaults {
+		t.Errorf("Expected the default ignored fields with their source, got %+v", fields)
+	}
+	if doc.DecisionModes.Default != decisionMode || len(doc.DecisionModes.Modes) != 2 {
+		t.Errorf("Expected the decision modes to be described, got %+v", doc.DecisionModes)
+	}
+
+	enforced := map[string]bool{}
+	for _, policy := range doc.Enforcement {
+		enforced[policy.Name] = true
+	}
+	for _, name := range []string{"no-op-updates", "spec-change-rate-limit", "change-freezes"} {
+		if !enforced[name] {
+			t.Errorf("Expected %s to be listed, got %+v", name, doc.Enforcement)
+		}
+	}
+	if len(doc.Freezes) != 1 || doc.Freezes[0].Name != "year-end" {
+		t.Errorf("Expected the year-end freeze, got %+v", doc.Freezes)
+	}
+}
+
+func TestHandlePolicies(t *testing.T) {
+	w := httptest.NewRecorder()
+	handlePolicies(w, httptest.NewRequest(http.MethodGet, "/policies", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected JSON, got %s", ct)
+	}
+	var doc policyDocument
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(doc.Kinds) == 0 {
+		t.Errorf("Expected the kinds to be documented")
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/policies", nil)
+	r.Header.Set("Accept", "text/html,application/xhtml+xml")
+	w = httptest.NewRecorder()
+	handlePolicies(w, r)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Expected HTML for a browser, got %s", ct)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "<h2>"+kindFilter.kind+"</h2>") || !strings.Contains(body, "no-op-updates") {
+		t.Errorf("Expected the kind and its enforcement in the page, got %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	handlePolicies(w, httptest.NewRequest(http.MethodGet, "/policies?format=html", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Expected HTML for format=html, got %s", ct)
+	}
+}
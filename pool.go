@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Admission requests are accepted into a bounded queue and processed by a
+// fixed number of workers, so an admission storm translates into queueing and
+// fast rejections instead of an unbounded number of concurrent diffs.
+var (
+	workerCount = 16
+	queueSize   = 128
+)
+
+// inFlightRequests counts the admission requests accepted into the queue and
+// not yet answered, busyWorkers the ones a worker is handling.
+var inFlightRequests, busyWorkers atomic.Int64
+
+var (
+	// Gauge for the number of admission requests accepted and not yet answered
+	inFlightGauge = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "grafana_operator_webhook_in_flight_requests",
+			Help: "Number of admission requests queued or being processed.",
+		},
+		func() float64 { return float64(inFlightRequests.Load()) },
+	)
+
+	// Gauge for the number of workers handling an admission request
+	busyWorkersGauge = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "grafana_operator_webhook_busy_workers",
+			Help: "Number of workers handling an admission request.",
+		},
+		func() float64 { return float64(busyWorkers.Load()) },
+	)
+
+	// Gauge for the number of admission requests waiting for a worker
+	queueDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "grafana_operator_webhook_queue_depth",
+			Help: "Number of admission requests waiting in the queue for a worker.",
+		},
+	)
+
+	// Histogram for the time admission requests spend waiting for a worker
+	queueWaitDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "grafana_operator_webhook_queue_wait_duration_seconds",
+			Help:    "Time admission requests spend in the queue before a worker picks them up, in seconds.",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+
+	// Counter for admission requests rejected because the queue was full
+	queueRejectedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_queue_rejected_total",
+			Help: "Total number of admission requests rejected because the queue was full.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(inFlightGauge)
+	prometheus.MustRegister(busyWorkersGauge)
+	prometheus.MustRegister(queueDepth)
+	prometheus.MustRegister(queueWaitDuration)
+	prometheus.MustRegister(queueRejectedTotal)
+}
+
+type job struct {
+	w        http.ResponseWriter
+	r        *http.Request
+	enqueued time.Time
+	done     chan struct{}
+}
+
+// workerPool is an http.Handler that runs the wrapped handler on a fixed set
+// of worker goroutines fed by a bounded queue.
+type workerPool struct {
+	handler http.Handler
+	jobs    chan *job
+	workers sync.WaitGroup
+
+	// mu guards stopped and the sends on jobs against stop closing it. A
+	// forced shutdown leaves handlers running that may still get here.
+	mu      sync.RWMutex
+	stopped bool
+}
+
+func newWorkerPool(workers, size int, handler http.Handler) *workerPool {
+	p := &workerPool{
+		handler: handler,
+		jobs:    make(chan *job, size),
+	}
+	p.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *workerPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	enqueued := time.Now()
+	ctx, cancel := withRequestDeadline(r.Context(), enqueued)
+	defer cancel()
+	j := &job{w: w, r: r.WithContext(ctx), enqueued: enqueued, done: make(chan struct{})}
+
+	p.mu.RLock()
+	if p.stopped {
+		p.mu.RUnlock()
+		http.Error(w, "webhook is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	select {
+	case p.jobs <- j:
+		p.mu.RUnlock()
+		queueDepth.Inc()
+		inFlightRequests.Add(1)
+		defer inFlightRequests.Add(-1)
+	default:
+		p.mu.RUnlock()
+		queueRejectedTotal.Inc()
+		log.Warn("Admission queue is full, rejecting request")
+		http.Error(w, "webhook is overloaded", http.StatusServiceUnavailable)
+		return
+	}
+
+	// The ResponseWriter is only valid until ServeHTTP returns, so always
+	// wait for the worker to finish with it.
+	<-j.done
+	observeTimeoutBudget(j.enqueued)
+}
+
+func (p *workerPool) work() {
+	defer p.workers.Done()
+
+	for j := range p.jobs {
+		queueDepth.Dec()
+		queueWaitDuration.Observe(time.Since(j.enqueued).Seconds())
+
+		// The apiserver has already given up on requests that timed out
+		// while queued; don't spend a worker on them.
+		if err := j.r.Context().Err(); err != nil {
+			http.Error(j.w, "request canceled while queued", http.StatusServiceUnavailable)
+		} else {
+			busyWorkers.Add(1)
+			p.handler.ServeHTTP(j.w, j.r)
+			busyWorkers.Add(-1)
+		}
+		close(j.done)
+	}
+}
+
+// stop rejects further requests and waits until the workers have answered
+// the queued ones or ctx expires.
+func (p *workerPool) stop(ctx context.Context) {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.jobs)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Warn("Timed out waiting for admission workers")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool_ServesThroughWorkers(t *testing.T) {
+	pool := newWorkerPool(2, 4, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer pool.stop(context.Background())
+
+	w := httptest.NewRecorder()
+	pool.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/validate", nil))
+
+	if w.Code != http.StatusTeapot {
+		t.Errorf("Expected status code 418, got %d", w.Code)
+	}
+}
+
+func TestWorkerPool_BoundsRequestsByTheWebhookTimeout(t *testing.T) {
+	var deadline time.Time
+	var ok bool
+	pool := newWorkerPool(1, 1, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+	}))
+	defer pool.stop(context.Background())
+
+	before := time.Now()
+	pool.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/validate", nil))
+
+	timeout := time.Duration(webhookTimeoutSeconds) * time.Second
+	if !ok || deadline.Before(before.Add(timeout-responseMargin)) || !deadline.Before(before.Add(timeout)) {
+		t.Errorf("Expected a deadline within the webhook timeout, got %s, %t", deadline, ok)
+	}
+}
+
+func TestWorkerPool_RejectsWhenQueueFull(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	pool := newWorkerPool(1, 1, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	defer pool.stop(context.Background())
+
+	// Occupy the only worker, then fill the only queue slot.
+	done := make(chan struct{}, 2)
+	serve := func() {
+		pool.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/validate", nil))
+		done <- struct{}{}
+	}
+	go serve()
+	<-started
+	go serve()
+	for len(pool.jobs) == 0 {
+		runtime.Gosched() // wait until the second request is queued
+	}
+
+	w := httptest.NewRecorder()
+	pool.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/validate", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code 503, got %d", w.Code)
+	}
+
+	close(release)
+	<-started
+	<-done
+	<-done
+}
+
+func TestWorkerPool_RejectsAfterStop(t *testing.T) {
+	pool := newWorkerPool(1, 1, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	pool.stop(context.Background())
+	pool.stop(context.Background())
+
+	w := httptest.NewRecorder()
+	pool.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/validate", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code 503 after stop, got %d", w.Code)
+	}
+}
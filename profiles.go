@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/profile"
+
+	// Kind profiles compiled into the webhook. Forks import their own
+	// profiles from a separate file.
+	_ "github.com/hsiaoairplane/grafana-operator-webhook/profiles/grafana"
+)
+
+// kindProfile names the registered profile the webhook is built for. A fork
+// compiling in another profile selects it with
+// -ldflags "-X main.kindProfile=<Kind>".
+var kindProfile = "GrafanaDashboard"
+
+// activeProfile provides the kind, the built-in ignore paths, the embedded
+// JSON paths and the source fields.
+var activeProfile = mustLookupProfile(kindProfile)
+
+func mustLookupProfile(kind string) profile.Profile {
+	p, ok := profile.Lookup(kind)
+	if !ok {
+		panic(fmt.Sprintf("no profile registered for %s, compiled in: %s", kind, strings.Join(profile.Kinds(), ", ")))
+	}
+	return p
+}
@@ -0,0 +1,34 @@
+// Package grafana registers the profile for Grafana Operator dashboards.
+package grafana
+
+import "github.com/hsiaoairplane/grafana-operator-webhook/pkg/profile"
+
+func init() {
+	profile.Register(profile.Profile{
+		Group:    "grafana.integreatly.org",
+		Version:  "v1beta1",
+		Resource: "grafanadashboards",
+		Kind:     "GrafanaDashboard",
+
+		IgnorePaths: []string{
+			"metadata.managedFields",
+			"metadata.generation",
+			"status.lastResync",
+		},
+
+		// The dashboard model
+		EmbeddedJSONPaths: []string{"spec.json"},
+
+		// Where the dashboard comes from, and the folder it goes to
+		SourceFields: []string{
+			"spec.url",
+			"spec.grafanaCom.id",
+			"spec.grafanaCom.revision",
+			"spec.configMapRef.name",
+			"spec.configMapRef.key",
+			"spec.folder",
+			"spec.folderUID",
+			"spec.folderRef",
+		},
+	})
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/store"
+	"github.com/prometheus/client_golang/prometheus"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// specChangeRateLimit caps how many spec changes per specChangeRateWindow
+// are admitted for one object, to contain automation rewriting a spec in a
+// loop. Changes are counted with a token bucket per object holding up to the
+// limit; zero disables the limit. Buckets are kept in the state store.
+var (
+	specChangeRateLimit  = 0
+	specChangeRateWindow = time.Hour
+)
+
+var (
+	// Counter for spec changes denied by the per-object rate limit
+	specChangeRateLimitedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_spec_change_rate_limited_total",
+			Help: "Total number of spec changes denied because the object exceeded its spec change rate limit.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(specChangeRateLimitedTotal)
+}
+
+type tokenBucket struct {
+	Tokens float64   `json:"tokens"`
+	Last   time.Time `json:"last"`
+}
+
+// specChangeLimiter holds a token bucket per object.
+type specChangeLimiter struct {
+	store store.Store
+}
+
+var specChanges = newSpecChangeLimiter(state)
+
+func newSpecChangeLimiter(s store.Store) *specChangeLimiter {
+	return &specChangeLimiter{store: s}
+}
+
+// allow takes a token for key at now, refilling limit tokens per window, and
+// reports whether one was available. Otherwise it returns how long until the
+// next token.
+func (l *specChangeLimiter) allow(ctx context.Context, key string, limit int, window time.Duration, now time.Time) (allowed bool, retryAfter time.Duration) {
+	capacity := float64(limit)
+	rate := capacity / window.Seconds()
+
+	// Buckets that have refilled completely carry no state, so they expire
+	// after a window
+	err := l.store.Update(ctx, "spec-changes/"+key, window, func(old []byte) ([]byte, error) {
+		bucket := tokenBucket{Tokens: capacity, Last: now}
+		if old != nil {
+			if err := json.Unmarshal(old, &bucket); err != nil {
+				return nil, err
+			}
+		}
+		bucket.Tokens = min(capacity, bucket.Tokens+now.Sub(bucket.Last).Seconds()*rate)
+		bucket.Last = now
+
+		allowed, retryAfter = bucket.Tokens >= 1, 0
+		if allowed {
+			bucket.Tokens--
+		} else {
+			retryAfter = time.Duration((1 - bucket.Tokens) / rate * float64(time.Second))
+		}
+		return json.Marshal(bucket)
+	})
+	if err != nil {
+		stateStoreFailed(ctx, "spec change bucket update", err)
+		return true, 0
+	}
+	return allowed, retryAfter
+}
+
+// refund gives back the token taken for key by allow, up to the capacity of
+// limit tokens.
+func (l *specChangeLimiter) refund(ctx context.Context, key string, limit int, window time.Duration, now time.Time) {
+	capacity := float64(limit)
+	rate := capacity / window.Seconds()
+
+	err := l.store.Update(ctx, "spec-changes/"+key, window, func(old []byte) ([]byte, error) {
+		bucket := tokenBucket{Tokens: capacity, Last: now}
+		if old != nil {
+			if err := json.Unmarshal(old, &bucket); err != nil {
+				return nil, err
+			}
+		}
+		bucket.Tokens = min(capacity, bucket.Tokens+now.Sub(bucket.Last).Seconds()*rate+1)
+		bucket.Last = now
+		return json.Marshal(bucket)
+	})
+	if err != nil {
+		stateStoreFailed(ctx, "spec change bucket refund", err)
+	}
+}
+
+// specChangeKey is the key of the token bucket of the object of req.
+func specChangeKey(req *admissionv1.AdmissionRequest) string {
+	return fmt.Sprintf("%s/%s/%s", req.Kind.Kind, req.Namespace, req.Name)
+}
+
+// applySpecChangeRateLimit denies a spec change once the object has used up
+// its spec change budget. It reports whether it took a token, which
+// refundSpecChange gives back if a later stage denies the change.
+func applySpecChangeRateLimit(ctx context.Context, req *admissionv1.AdmissionRequest, cmp *comparison, resp *admissionv1.AdmissionResponse, now time.Time) bool {
+	if specChangeRateLimit <= 0 || cmp == nil || !cmp.specChanged || !resp.Allowed {
+		return false
+	}
+
+	allowed, retryAfter := specChanges.allow(ctx, specChangeKey(req), specChangeRateLimit, specChangeRateWindow, now)
+	if allowed {
+		return true
+	}
+
+	specChangeRateLimitedTotal.Inc()
+	message := fmt.Sprintf("more than %d spec changes per %s, retry in %s", specChangeRateLimit, specChangeRateWindow, retryAfter.Round(time.Second))
+	loggerFromContext(ctx).Warnf("Denied spec change: %s", message)
+
+	resp.Allowed = false
+	resp.Result = &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Reason:  metav1.StatusReasonTooManyRequests,
+		Message: message,
+		Code:    http.StatusTooManyRequests,
+		Details: &metav1.StatusDetails{RetryAfterSeconds: int32(retryAfter.Seconds()) + 1},
+	}
+	return false
+}
+
+// refundSpecChange gives back the token of a spec change that was finally
+// denied, so a change that is never written does not use up the budget of
+// the object.
+func refundSpecChange(ctx context.Context, req *admissionv1.AdmissionRequest, now time.Time) {
+	specChanges.refund(ctx, specChangeKey(req), specChangeRateLimit, specChangeRateWindow, now)
+}
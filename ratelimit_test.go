@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/store"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSpecChangeLimiter(t *testing.T) {
+	l := newSpecChangeLimiter(store.NewMemory())
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if allowed, _ := l.allow(context.Background(), "ns/a", 3, time.Hour, now); !allowed {
+			t.Fatalf("Expected change %d to be allowed", i+1)
+		}
+	}
+	allowed, retryAfter := l.allow(context.Background(), "ns/a", 3, time.Hour, now)
+	if allowed {
+		t.Errorf("Expected the fourth change within the window to be denied")
+	}
+	if retryAfter != 20*time.Minute {
+		t.Errorf("Expected to retry in 20m, got %s", retryAfter)
+	}
+
+	if allowed, _ := l.allow(context.Background(), "ns/b", 3, time.Hour, now); !allowed {
+		t.Errorf("Expected other objects to have their own budget")
+	}
+	if allowed, _ := l.allow(context.Background(), "ns/a", 3, time.Hour, now.Add(20*time.Minute)); !allowed {
+		t.Errorf("Expected a token to be refilled after 20m")
+	}
+}
+
+func TestApplySpecChangeRateLimit(t *testing.T) {
+	defer func(l int) { specChangeRateLimit = l }(specChangeRateLimit)
+	specChangeRateLimit = 1
+
+	req := &admissionv1.AdmissionRequest{Namespace: "ns", Name: "rate-limited"}
+	now := time.Now()
+
+	resp := &admissionv1.AdmissionResponse{Allowed: true}
+	applySpecChangeRateLimit(context.Background(), req, &comparison{specChanged: true}, resp, now)
+	if !resp.Allowed {
+		t.Fatalf("Expected the first spec change to be allowed")
+	}
+
+	resp = &admissionv1.AdmissionResponse{Allowed: true}
+	applySpecChangeRateLimit(context.Background(), req, &comparison{metadataChanged: true}, resp, now)
+	if !resp.Allowed {
+		t.Errorf("Expected metadata changes not to be limited")
+	}
+
+	resp = &admissionv1.AdmissionResponse{Allowed: true}
+	applySpecChangeRateLimit(context.Background(), req, &comparison{specChanged: true}, resp, now)
+	if resp.Allowed || resp.Result.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the second spec change to be denied with 429, got %+v", resp.Result)
+	}
+}
+
+func TestFinalizeResponse_RefundsDeniedSpecChange(t *testing.T) {
+	defer func(l int, p string, urls stringSliceFlag) {
+		specChangeRateLimit, downstreamFailurePolicy, downstreamURLs = l, p, urls
+	}(specChangeRateLimit, downstreamFailurePolicy, downstreamURLs)
+	defer func(l *specChangeLimiter) { specChanges = l }(specChanges)
+	specChanges = newSpecChangeLimiter(store.NewMemory())
+	specChangeRateLimit = 1
+
+	body, err := json.Marshal(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{UID: "refund-uid"}})
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+	req := &admissionv1.AdmissionRequest{UID: "refund-uid", Kind: metav1.GroupVersionKind{Kind: "GrafanaDashboard"}, Namespace: "ns", Name: "refunded"}
+	cmp := &comparison{specChanged: true}
+
+	// A downstream webhook denies the first change after it took the token
+	downstreamURLs = stringSliceFlag{newDownstream(t, false).URL}
+	resp := &admissionv1.AdmissionResponse{UID: "refund-uid", Allowed: true}
+	finalizeResponse(context.Background(), req, cmp, body, resp)
+	if resp.Allowed {
+		t.Fatalf("Expected the downstream webhook to deny the change")
+	}
+
+	downstreamURLs = nil
+	resp = &admissionv1.AdmissionResponse{UID: "refund-uid", Allowed: true}
+	finalizeResponse(context.Background(), req, cmp, body, resp)
+	if !resp.Allowed {
+		t.Errorf("Expected the denied change not to use up the budget, got %+v", resp.Result)
+	}
+
+	resp = &admissionv1.AdmissionResponse{UID: "refund-uid", Allowed: true}
+	finalizeResponse(context.Background(), req, cmp, body, resp)
+	if resp.Allowed || resp.Result.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the next change to be rate limited, got %+v", resp.Result)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// rulesURL is an optional HTTPS endpoint serving a rule layer. It is polled
+// every rulesPollInterval and merged above the rules file.
+var rulesURL = ""
+
+// rulesPollInterval is how often rulesURL is polled.
+var rulesPollInterval = time.Minute
+
+// rulesCAFile is a CA bundle trusted for rulesURL in addition to the system
+// roots.
+var rulesCAFile = ""
+
+// rulesAllowHTTP allows a plain HTTP rulesURL. Without TLS anyone on the path
+// can change which updates are treated as no-ops, so it is for testing only.
+var rulesAllowHTTP = false
+
+// rulesCacheFile keeps the last rule layer fetched from rulesURL, so the
+// webhook starts with the central rules when the endpoint is unreachable.
+var rulesCacheFile = ""
+
+var (
+	// Counter for remote rules fetches, by outcome
+	remoteRulesFetchesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_remote_rules_fetches_total",
+			Help: "Total number of fetches from the remote rules endpoint, differentiated by result.",
+		},
+		[]string{"result"}, // result is "updated", "not_modified" or "error"
+	)
+)
+
+func init() {
+	prometheus.MustRegister(remoteRulesFetchesTotal)
+}
+
+// remoteRules fetches the rule layer served at url, using the ETag of the
+// last response to skip unchanged documents.
+type remoteRules struct {
+	url       string
+	cacheFile string
+	client    *http.Client
+	etag      string
+}
+
+func newRemoteRules(url, caFile, cacheFile string) (*remoteRules, error) {
+	tlsConfig, err := clientTLSConfig(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("rules CA bundle: %w", err)
+	}
+
+	return &remoteRules{
+		url:       url,
+		cacheFile: cacheFile,
+		client: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+			Timeout:   10 * time.Second,
+		},
+	}, nil
+}
+
+// fetch requests the rules and returns the new layer, or nil if the document
+// has not changed since the last fetch.
+func (r *remoteRules) fetch(ctx context.Context) (*ruleLayer, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if r.etag != "" {
+		req.Header.Set("If-None-Match", r.etag)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, r.url)
+	}
+
+	// Read one byte past the limit so a truncated document is not mistaken
+	// for a complete one.
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRequestBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxRequestBodyBytes {
+		return nil, fmt.Errorf("rules from %s exceed %d bytes", r.url, maxRequestBodyBytes)
+	}
+
+	var layer ruleLayer
+	if err := json.Unmarshal(data, &layer); err != nil {
+		return nil, fmt.Errorf("failed to parse rules from %s: %w", r.url, err)
+	}
+	if err := layer.validate(); err != nil {
+		return nil, fmt.Errorf("invalid rules from %s: %w", r.url, err)
+	}
+
+	r.etag = resp.Header.Get("ETag")
+	if r.cacheFile != "" {
+		if err := os.WriteFile(r.cacheFile, data, 0o600); err != nil {
+			log.Warnf("Failed to cache remote rules in %s: %v", r.cacheFile, err)
+		}
+	}
+	return &layer, nil
+}
+
+// update fetches the rules once and installs them if they changed.
+func (r *remoteRules) update(ctx context.Context) error {
+	layer, err := r.fetch(ctx)
+	if err != nil {
+		remoteRulesFetchesTotal.WithLabelValues("error").Inc()
+		return err
+	}
+	if layer == nil {
+		remoteRulesFetchesTotal.WithLabelValues("not_modified").Inc()
+		return nil
+	}
+
+	remoteRulesFetchesTotal.WithLabelValues("updated").Inc()
+	setRuleLayer(ruleSourceRemote, layer)
+	log.Infof("Remote rules updated for %d kinds (etag %q)", len(layer.Kinds), r.etag)
+	return nil
+}
+
+// load installs the remote rules at startup, falling back to the cache file
+// if the endpoint cannot be reached.
+func (r *remoteRules) load(ctx context.Context) error {
+	err := r.update(ctx)
+	if err == nil || r.cacheFile == "" {
+		return err
+	}
+
+	layer, cacheErr := loadRulesFile(r.cacheFile)
+	if cacheErr != nil {
+		return fmt.Errorf("%w (no usable cache: %v)", err, cacheErr)
+	}
+	setRuleLayer(ruleSourceRemote, layer)
+	log.Warnf("Remote rules unavailable, using cached copy from %s: %v", r.cacheFile, err)
+	return nil
+}
+
+// poll refreshes the remote rules every interval until ctx is cancelled. On
+// errors the last rules stay in effect.
+func (r *remoteRules) poll(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.update(ctx); err != nil {
+				log.Errorf("Failed to refresh remote rules: %v", err)
+			}
+		}
+	}
+}
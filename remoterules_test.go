@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRemoteRules_ETag(t *testing.T) {
+	defer setRuleLayer(ruleSourceRemote, nil)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"kinds": {"RemoteKind": {"ignorePaths": ["status.hash"]}}}`))
+	}))
+	defer server.Close()
+
+	cacheFile := filepath.Join(t.TempDir(), "rules.json")
+	remote, err := newRemoteRules(server.URL, "", cacheFile)
+	if err != nil {
+		t.Fatalf("Failed to create remote rules: %v", err)
+	}
+
+	if err := remote.load(context.Background()); err != nil {
+		t.Fatalf("Failed to load remote rules: %v", err)
+	}
+	if expected := []string{"status.hash"}; !reflect.DeepEqual(activeRuleset("RemoteKind").IgnorePaths, expected) {
+		t.Errorf("Expected remote paths %v, got %v", expected, activeRuleset("RemoteKind").IgnorePaths)
+	}
+
+	layer, err := remote.fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to fetch remote rules: %v", err)
+	}
+	if layer != nil {
+		t.Errorf("Expected no layer for an unchanged document, got %+v", layer)
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", requests)
+	}
+
+	// An unreachable endpoint falls back to the cached copy.
+	setRuleLayer(ruleSourceRemote, nil)
+	server.Close()
+	if remote, err = newRemoteRules(server.URL, "", cacheFile); err != nil {
+		t.Fatalf("Failed to create remote rules: %v", err)
+	}
+	if err := remote.load(context.Background()); err != nil {
+		t.Fatalf("Expected the cached rules to be used, got %v", err)
+	}
+	if len(activeRuleset("RemoteKind").IgnorePaths) != 1 {
+		t.Errorf("Expected cached remote paths, got %v", activeRuleset("RemoteKind").IgnorePaths)
+	}
+}
+
+func TestRemoteRules_InvalidDocument(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"kinds": {"RemoteKind": {"ignorePaths": ["status..hash"]}}}`))
+	}))
+	defer server.Close()
+
+	remote, err := newRemoteRules(server.URL, "", "")
+	if err != nil {
+		t.Fatalf("Failed to create remote rules: %v", err)
+	}
+	if err := remote.load(context.Background()); err == nil {
+		t.Errorf("Expected an error for an invalid rules document, got nil")
+	}
+}
+
+func TestRemoteRules_Oversized(t *testing.T) {
+	defer func(n int64) { maxRequestBodyBytes = n }(maxRequestBodyBytes)
+	document := `{"kinds": {"RemoteKind": {"ignorePaths": ["status.hash"]}}}`
+	maxRequestBodyBytes = int64(len(document)) - 1
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(document))
+	}))
+	defer server.Close()
+
+	remote, err := newRemoteRules(server.URL, "", "")
+	if err != nil {
+		t.Fatalf("Failed to create remote rules: %v", err)
+	}
+	if _, err := remote.fetch(context.Background()); err == nil || !strings.Contains(err.Error(), "exceed") {
+		t.Errorf("Expected an error for an oversized rules document, got %v", err)
+	}
+}
+
+func TestRemoteRules_CAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"kinds": {}}`))
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600); err != nil {
+		t.Fatalf("Failed to write CA bundle: %v", err)
+	}
+
+	untrusted, err := newRemoteRules(server.URL, "", "")
+	if err != nil {
+		t.Fatalf("Failed to create remote rules: %v", err)
+	}
+	if _, err := untrusted.fetch(context.Background()); err == nil {
+		t.Errorf("Expected the server certificate to be rejected without the CA bundle")
+	}
+
+	trusted, err := newRemoteRules(server.URL, caFile, "")
+	if err != nil {
+		t.Fatalf("Failed to create remote rules: %v", err)
+	}
+	if _, err := trusted.fetch(context.Background()); err != nil {
+		t.Errorf("Expected the CA bundle to be trusted, got %v", err)
+	}
+}
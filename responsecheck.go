@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// strictResponses makes the webhook fail a request with a 500 instead of
+// sending an AdmissionReview that breaks the admission contract. Without it,
+// violations are only logged and counted.
+var strictResponses = false
+
+var (
+	// Counter for outgoing AdmissionReviews that break the admission contract
+	responseContractViolationsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_response_contract_violations_total",
+			Help: "Total number of outgoing AdmissionReviews that failed response validation.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(responseContractViolationsTotal)
+}
+
+// validateAdmissionReview checks an outgoing AdmissionReview for the mistakes
+// the apiserver does not report clearly: a missing or mismatched UID, missing
+// type metadata, and patches on denied or unpatched responses.
+func validateAdmissionReview(uid types.UID, review admissionv1.AdmissionReview) error {
+	var errs []error
+	if review.APIVersion != "admission.k8s.io/v1" {
+		errs = append(errs, fmt.Errorf("apiVersion is %q, expected admission.k8s.io/v1", review.APIVersion))
+	}
+	if review.Kind != "AdmissionReview" {
+		errs = append(errs, fmt.Errorf("kind is %q, expected AdmissionReview", review.Kind))
+	}
+	if review.Request != nil {
+		errs = append(errs, errors.New("request must not be echoed in the response"))
+	}
+
+	resp := review.Response
+	if resp == nil {
+		errs = append(errs, errors.New("response is missing"))
+		return errors.Join(errs...)
+	}
+	if resp.UID != uid {
+		errs = append(errs, fmt.Errorf("response UID %q does not match request UID %q", resp.UID, uid))
+	}
+	if len(resp.Patch) > 0 && !resp.Allowed {
+		errs = append(errs, errors.New("denied response carries a patch"))
+	}
+	if (len(resp.Patch) > 0) != (resp.PatchType != nil) {
+		errs = append(errs, errors.New("patch and patchType must be set together"))
+	}
+	return errors.Join(errs...)
+}
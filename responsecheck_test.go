@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// assertValidAdmissionReview fails the test if review does not answer the
+// request with uid according to the admission contract.
+func assertValidAdmissionReview(t *testing.T, uid types.UID, review admissionv1.AdmissionReview) {
+	t.Helper()
+	if err := validateAdmissionReview(uid, review); err != nil {
+		t.Errorf("Expected a valid AdmissionReview, got %v", err)
+	}
+}
+
+func TestValidateAdmissionReview(t *testing.T) {
+	typeMeta := metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"}
+	jsonPatch := admissionv1.PatchTypeJSONPatch
+
+	tests := []struct {
+		name      string
+		review    admissionv1.AdmissionReview
+		expectErr bool
+	}{
+		{
+			name:   "valid",
+			review: admissionv1.AdmissionReview{TypeMeta: typeMeta, Response: &admissionv1.AdmissionResponse{UID: "uid", Allowed: true}},
+		},
+		{
+			name:      "missing type meta",
+			review:    admissionv1.AdmissionReview{Response: &admissionv1.AdmissionResponse{UID: "uid", Allowed: true}},
+			expectErr: true,
+		},
+		{
+			name:      "missing response",
+			review:    admissionv1.AdmissionReview{TypeMeta: typeMeta},
+			expectErr: true,
+		},
+		{
+			name:      "mismatched UID",
+			review:    admissionv1.AdmissionReview{TypeMeta: typeMeta, Response: &admissionv1.AdmissionResponse{UID: "other", Allowed: true}},
+			expectErr: true,
+		},
+		{
+			name: "denied with patch",
+			review: admissionv1.AdmissionReview{TypeMeta: typeMeta, Response: &admissionv1.AdmissionResponse{
+				UID: "uid", Allowed: false, Patch: []byte(`[]`), PatchType: &jsonPatch,
+			}},
+			expectErr: true,
+		},
+		{
+			name: "patch without type",
+			review: admissionv1.AdmissionReview{TypeMeta: typeMeta, Response: &admissionv1.AdmissionResponse{
+				UID: "uid", Allowed: true, Patch: []byte(`[]`),
+			}},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAdmissionReview("uid", tt.review)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("Expected error=%t, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
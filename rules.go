@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// ignoredPaths are the built-in dot paths removed from both objects before
+// comparing them, because they change without any user intent.
+var ignoredPaths = activeProfile.IgnorePaths
+
+// ruleset is the configuration objects are compared against.
+type ruleset struct {
+	IgnorePaths       []string          `json:"ignorePaths"`
+	FinalizerPolicies []finalizerPolicy `json:"finalizerPolicies,omitempty"`
+
+	// compiled holds IgnorePaths parsed when the rules were merged
+	compiled ignorePlan
+}
+
+// compiledIgnorePaths returns the parsed ignore paths. Rulesets that did not
+// come from a rule source, such as inline rulesets, are compiled on first use.
+func (r ruleset) compiledIgnorePaths() ignorePlan {
+	if r.compiled == nil && len(r.IgnorePaths) > 0 {
+		return compileIgnorePaths(r.IgnorePaths)
+	}
+	return r.compiled
+}
+
+// ignoredFieldMetrics exports ignoredHits as a Prometheus metric in addition
+// to /debug/rules.
+var ignoredFieldMetrics = true
+
+var (
+	// Counter for admission requests where an ignored path was present
+	ignoredFieldHitsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_ignored_field_hits_total",
+			Help: "Total number of admission requests in which an ignored path was present and removed before comparison.",
+		},
+		[]string{"path"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(ignoredFieldHitsTotal)
+}
+
+var (
+	ignoredHitsMu sync.Mutex
+	ignoredHits   = map[string]uint64{}
+)
+
+type compiledPath struct {
+	expr string
+	path fieldPath
+}
+
+// ignorePlan is a list of parsed ignore paths. It is compiled once when the
+// rules are loaded and never modified afterwards, so requests share it
+// without parsing or locking.
+type ignorePlan []compiledPath
+
+// compileIgnorePaths parses paths into a plan. Paths that do not parse are
+// left out, as they never match; rule sources are validated when they are
+// loaded.
+func compileIgnorePaths(paths []string) ignorePlan {
+	plan := make(ignorePlan, 0, len(paths))
+	for _, expr := range paths {
+		path, err := parsePath(expr)
+		if err != nil {
+			continue
+		}
+		plan = append(plan, compiledPath{expr: expr, path: path})
+	}
+	return plan
+}
+
+// apply removes the planned paths from every object and appends the paths
+// that were present in at least one of them to hits. It only allocates when
+// hits has to grow.
+func (p ignorePlan) apply(hits []string, objs ...map[string]interface{}) []string {
+	for _, compiled := range p {
+		hit := false
+		for _, obj := range objs {
+			if compiled.path.remove(obj) {
+				hit = true
+			}
+		}
+		if hit {
+			hits = append(hits, compiled.expr)
+		}
+	}
+	return hits
+}
+
+// removeIgnoredPaths removes paths from every object and returns the paths
+// that were present in at least one of them.
+func removeIgnoredPaths(paths []string, objs ...map[string]interface{}) []string {
+	return compileIgnorePaths(paths).apply(nil, objs...)
+}
+
+// recordIgnoredHits counts the ignored paths removed for one admission
+// request.
+func recordIgnoredHits(paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+
+	ignoredHitsMu.Lock()
+	defer ignoredHitsMu.Unlock()
+
+	for _, path := range paths {
+		ignoredHits[path]++
+		if ignoredFieldMetrics {
+			ignoredFieldHitsTotal.WithLabelValues(path).Inc()
+		}
+	}
+}
+
+// ignoredPathStatus is the /debug/rules view of one ignored path.
+type ignoredPathStatus struct {
+	Path   string `json:"path"`
+	Source string `json:"source"`
+	Hits   uint64 `json:"hits"`
+}
+
+// handleDebugRules shows the merged rules of every kind, the source each
+// ignore path came from and how often it matched since startup. A path that
+// never matches is a candidate for removal.
+func handleDebugRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	kinds := map[string][]ignoredPathStatus{}
+	ignoredHitsMu.Lock()
+	for kind, rules := range mergedRulesSnapshot() {
+		statuses := make([]ignoredPathStatus, 0, len(rules.IgnorePaths))
+		for _, path := range rules.IgnorePaths {
+			statuses = append(statuses, ignoredPathStatus{Path: path, Source: rules.Sources[path], Hits: ignoredHits[path]})
+		}
+		kinds[kind] = statuses
+	}
+	ignoredHitsMu.Unlock()
+
+	responseBytes, err := json.Marshal(struct {
+		Kinds map[string][]ignoredPathStatus `json:"kinds"`
+	}{kinds})
+	if err != nil {
+		log.Errorf("Failed to marshal debug rules: %v", err)
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, responseBytes)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRemoveIgnoredPaths(t *testing.T) {
+	oldObj := map[string]interface{}{
+		"metadata": map[string]interface{}{"generation": 1.0, "name": "a"},
+		"status":   "not a map",
+	}
+	newObj := map[string]interface{}{
+		"metadata": map[string]interface{}{"generation": 2.0, "name": "a"},
+		"status":   map[string]interface{}{"lastResync": "now"},
+	}
+
+	hits := removeIgnoredPaths([]string{"metadata.generation", "status.lastResync", "metadata.managedFields"}, oldObj, newObj)
+
+	if expected := []string{"metadata.generation", "status.lastResync"}; !reflect.DeepEqual(hits, expected) {
+		t.Errorf("Expected hits %v, got %v", expected, hits)
+	}
+	if _, ok := newObj["metadata"].(map[string]interface{})["generation"]; ok {
+		t.Errorf("Expected metadata.generation to be removed")
+	}
+	if oldObj["status"] != "not a map" {
+		t.Errorf("Expected a non-object parent to be left alone, got %v", oldObj["status"])
+	}
+}
+
+func TestHandleDebugRules(t *testing.T) {
+	recordIgnoredHits([]string{"status.lastResync"})
+
+	w := httptest.NewRecorder()
+	handleDebugRules(w, httptest.NewRequest(http.MethodGet, "/debug/rules", nil))
+
+	var resp struct {
+		Kinds map[string][]ignoredPathStatus `json:"kinds"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	statuses := resp.Kinds["GrafanaDashboard"]
+	if len(statuses) != len(ignoredPaths) {
+		t.Fatalf("Expected %d ignored paths, got %+v", len(ignoredPaths), statuses)
+	}
+	for _, status := range statuses {
+		if status.Source != ruleSourceDefaults {
+			t.Errorf("Expected source %s for %s, got %s", ruleSourceDefaults, status.Path, status.Source)
+		}
+		if status.Path == "status.lastResync" && status.Hits == 0 {
+			t.Errorf("Expected hits for status.lastResync, got 0")
+		}
+	}
+}
+
+// planObject returns an object with every path of planPaths present, and a
+// func that restores the removed values without allocating.
+func planObject() (map[string]interface{}, func()) {
+	var generation, lastTransitionTime interface{} = 2.0, "2024-01-01T00:00:00Z"
+	managedFields := interface{}([]interface{}{map[string]interface{}{"manager": "kubectl"}})
+
+	metadata := map[string]interface{}{"name": "a", "generation": generation, "managedFields": managedFields}
+	conditions := []interface{}{
+		map[string]interface{}{"type": "Ready", "lastTransitionTime": lastTransitionTime},
+		map[string]interface{}{"type": "Synced", "lastTransitionTime": lastTransitionTime},
+	}
+	status := map[string]interface{}{"conditions": conditions, "lastResync": lastTransitionTime}
+	obj := map[string]interface{}{"metadata": metadata, "spec": map[string]interface{}{"json": "{}"}, "status": status}
+
+	return obj, func() {
+		metadata["generation"] = generation
+		metadata["managedFields"] = managedFields
+		status["lastResync"] = lastTransitionTime
+		for _, condition := range conditions {
+			condition.(map[string]interface{})["lastTransitionTime"] = lastTransitionTime
+		}
+	}
+}
+
+var planPaths = []string{
+	"metadata.managedFields",
+	"metadata.generation",
+	"status.lastResync",
+	"status.conditions[*].lastTransitionTime",
+	"status.conditions[?(@.type=='Ready')].reason",
+}
+
+func TestIgnorePlan_DoesNotAllocate(t *testing.T) {
+	plan := compileIgnorePaths(planPaths)
+	obj, restore := planObject()
+	hits := make([]string, 0, len(plan))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		restore()
+		hits = plan.apply(hits[:0], obj)
+	})
+
+	if allocs != 0 {
+		t.Errorf("Expected applying a compiled plan not to allocate, got %v allocations", allocs)
+	}
+	if len(hits) != 4 {
+		t.Errorf("Expected 4 hits, got %v", hits)
+	}
+}
+
+func BenchmarkIgnorePlan_Apply(b *testing.B) {
+	plan := compileIgnorePaths(planPaths)
+	obj, restore := planObject()
+	hits := make([]string, 0, len(plan))
+
+	b.ReportAllocs()
+	for b.Loop() {
+		restore()
+		hits = plan.apply(hits[:0], obj)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/errdefs"
+	log "github.com/sirupsen/logrus"
+)
+
+// Rules can come from several sources at once. They are merged per kind in
+// increasing order of precedence: the built-in defaults (--ignore-paths), the
+// --rules-file, the --rules-url, and runtime overrides set through
+// /api/v1/rules/overrides. The canary layer (--canary-rules-file) is merged
+// last, but only into the canary ruleset used for a share of requests.
+const (
+	ruleSourceDefaults = "defaults"
+	ruleSourceFile     = "file"
+	ruleSourceRemote   = "remote"
+	ruleSourceRuntime  = "runtime"
+	ruleSourceCanary   = "canary"
+)
+
+var ruleSourcePrecedence = []string{ruleSourceDefaults, ruleSourceFile, ruleSourceRemote, ruleSourceRuntime, ruleSourceCanary}
+
+// rulesFile is the optional JSON file holding a rule layer.
+var rulesFile = ""
+
+// kindRules are the rules one source contributes for one kind. By default its
+// ignore paths are added to those of lower-precedence sources, and its
+// finalizer policies take the place of lower-precedence policies for the
+// same finalizer; with Replace all lower-precedence rules are dropped.
+type kindRules struct {
+	IgnorePaths       []string          `json:"ignorePaths,omitempty"`
+	FinalizerPolicies []finalizerPolicy `json:"finalizerPolicies,omitempty"`
+	Replace           bool              `json:"replace,omitempty"`
+}
+
+// ruleLayer is everything one source contributes, keyed by kind.
+type ruleLayer struct {
+	Kinds map[string]kindRules `json:"kinds"`
+}
+
+// mergedKindRules is the effective ruleset for a kind, with the source each
+// ignore path came from.
+type mergedKindRules struct {
+	ruleset
+	Sources map[string]string `json:"sources"`
+}
+
+var (
+	ruleLayersMu sync.RWMutex
+	ruleLayers   = map[string]*ruleLayer{}
+	mergedRules  = map[string]mergedKindRules{}
+	canaryRules  map[string]mergedKindRules // nil without a canary layer
+)
+
+func init() {
+	setRuleLayer(ruleSourceDefaults, defaultRuleLayer())
+}
+
+// defaultRuleLayer holds the built-in ignore paths for dashboards.
+func defaultRuleLayer() *ruleLayer {
+	return &ruleLayer{Kinds: map[string]kindRules{
+		kindFilter.kind: {IgnorePaths: ignoredPaths},
+	}}
+}
+
+// setRuleLayer replaces the layer of source, or removes it if layer is nil,
+// and recomputes the merged rules.
+func setRuleLayer(source string, layer *ruleLayer) {
+	ruleLayersMu.Lock()
+	defer ruleLayersMu.Unlock()
+
+	if layer == nil {
+		delete(ruleLayers, source)
+	} else {
+		ruleLayers[source] = layer
+	}
+
+	stable := make(map[string]*ruleLayer, len(ruleLayers))
+	for source, layer := range ruleLayers {
+		if source != ruleSourceCanary {
+			stable[source] = layer
+		}
+	}
+	mergedRules = mergeRuleLayers(stable)
+
+	canaryRules = nil
+	if _, ok := ruleLayers[ruleSourceCanary]; ok {
+		canaryRules = mergeRuleLayers(ruleLayers)
+	}
+}
+
+// mergeRuleLayers merges layers per kind in order of precedence.
+func mergeRuleLayers(layers map[string]*ruleLayer) map[string]mergedKindRules {
+	merged := map[string]mergedKindRules{}
+	for _, source := range ruleSourcePrecedence {
+		layer, ok := layers[source]
+		if !ok {
+			continue
+		}
+
+		for kind, rules := range layer.Kinds {
+			current, exists := merged[kind]
+			if !exists || rules.Replace {
+				current = mergedKindRules{ruleset: ruleset{IgnorePaths: []string{}}, Sources: map[string]string{}}
+			}
+			for _, path := range rules.IgnorePaths {
+				if _, seen := current.Sources[path]; !seen {
+					current.IgnorePaths = append(current.IgnorePaths, path)
+				}
+				current.Sources[path] = source
+			}
+			for _, policy := range rules.FinalizerPolicies {
+				current.FinalizerPolicies = slices.DeleteFunc(current.FinalizerPolicies, func(p finalizerPolicy) bool {
+					return p.Finalizer == policy.Finalizer
+				})
+				current.FinalizerPolicies = append(current.FinalizerPolicies, policy)
+			}
+			merged[kind] = current
+		}
+	}
+
+	// Compile once here rather than on every request
+	for kind, rules := range merged {
+		rules.compiled = compileIgnorePaths(rules.IgnorePaths)
+		merged[kind] = rules
+	}
+	return merged
+}
+
+// activeRuleset returns the merged ruleset for kind.
+func activeRuleset(kind string) ruleset {
+	ruleLayersMu.RLock()
+	defer ruleLayersMu.RUnlock()
+
+	return mergedRules[kind].ruleset
+}
+
+// mergedRulesSnapshot returns a copy of the merged rules of every kind.
+func mergedRulesSnapshot() map[string]mergedKindRules {
+	ruleLayersMu.RLock()
+	defer ruleLayersMu.RUnlock()
+
+	snapshot := make(map[string]mergedKindRules, len(mergedRules))
+	for kind, rules := range mergedRules {
+		snapshot[kind] = rules
+	}
+	return snapshot
+}
+
+// validate checks that every ignore path in the layer parses and every
+// finalizer policy names a finalizer.
+func (l *ruleLayer) validate() error {
+	for kind, rules := range l.Kinds {
+		for _, path := range rules.IgnorePaths {
+			if _, err := parsePath(path); err != nil {
+				return fmt.Errorf("kind %s: %w", kind, err)
+			}
+		}
+		for _, policy := range rules.FinalizerPolicies {
+			if policy.Finalizer == "" {
+				return fmt.Errorf("kind %s: %w: finalizer policy without a finalizer", kind, errdefs.ErrRuleCompile)
+			}
+		}
+	}
+	return nil
+}
+
+// loadRulesFile reads a rule layer from a JSON file.
+func loadRulesFile(path string) (*ruleLayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	var layer ruleLayer
+	if err := json.Unmarshal(data, &layer); err != nil {
+		return nil, fmt.Errorf("failed to parse rules file %s: %w", path, err)
+	}
+	if err := layer.validate(); err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %w", path, err)
+	}
+	return &layer, nil
+}
+
+// handleRuleOverrides reads (GET), replaces (PUT) or clears (DELETE) the
+// runtime override layer, which takes precedence over every other source.
+// Overrides are kept in memory by the replica that received the call, so
+// other replicas and restarted pods do not see them; lasting or cluster-wide
+// changes belong in the rules file or the remote rules.
+func handleRuleOverrides(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		var layer ruleLayer
+		if err := json.Unmarshal(body, &layer); err != nil {
+			http.Error(w, "failed to unmarshal rules", http.StatusBadRequest)
+			return
+		}
+		if err := layer.validate(); err != nil {
+			http.Error(w, err.Error(), errdefs.HTTPStatus(err))
+			return
+		}
+		setRuleLayer(ruleSourceRuntime, &layer)
+		log.Infof("Runtime rule overrides set for %d kinds on this replica only", len(layer.Kinds))
+	case http.MethodDelete:
+		setRuleLayer(ruleSourceRuntime, nil)
+		log.Info("Runtime rule overrides cleared")
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ruleLayersMu.RLock()
+	layer := ruleLayers[ruleSourceRuntime]
+	ruleLayersMu.RUnlock()
+	if layer == nil {
+		layer = &ruleLayer{Kinds: map[string]kindRules{}}
+	}
+
+	responseBytes, err := json.Marshal(layer)
+	if err != nil {
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, responseBytes)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeRuleLayers_Precedence(t *testing.T) {
+	merged := mergeRuleLayers(map[string]*ruleLayer{
+		ruleSourceDefaults: {Kinds: map[string]kindRules{
+			"GrafanaDashboard": {IgnorePaths: []string{"metadata.generation", "status.lastResync"}},
+			"GrafanaFolder":    {IgnorePaths: []string{"metadata.generation"}},
+		}},
+		ruleSourceFile: {Kinds: map[string]kindRules{
+			"GrafanaDashboard": {IgnorePaths: []string{"status.hash", "status.lastResync"}},
+		}},
+		ruleSourceRuntime: {Kinds: map[string]kindRules{
+			"GrafanaFolder": {IgnorePaths: []string{"status.hash"}, Replace: true},
+		}},
+	})
+
+	dashboard := merged["GrafanaDashboard"]
+	if expected := []string{"metadata.generation", "status.lastResync", "status.hash"}; !reflect.DeepEqual(dashboard.IgnorePaths, expected) {
+		t.Errorf("Expected merged dashboard paths %v, got %v", expected, dashboard.IgnorePaths)
+	}
+	if dashboard.Sources["status.lastResync"] != ruleSourceFile || dashboard.Sources["metadata.generation"] != ruleSourceDefaults {
+		t.Errorf("Expected the highest-precedence source per path, got %v", dashboard.Sources)
+	}
+
+	folder := merged["GrafanaFolder"]
+	if expected := []string{"status.hash"}; !reflect.DeepEqual(folder.IgnorePaths, expected) {
+		t.Errorf("Expected runtime override to replace folder paths with %v, got %v", expected, folder.IgnorePaths)
+	}
+}
+
+func TestLoadRulesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(`{"kinds": {"GrafanaDashboard": {"ignorePaths": ["status.hash"]}}}`), 0o600); err != nil {
+		t.Fatalf("Failed to write rules file: %v", err)
+	}
+
+	layer, err := loadRulesFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load rules file: %v", err)
+	}
+	if paths := layer.Kinds["GrafanaDashboard"].IgnorePaths; len(paths) != 1 || paths[0] != "status.hash" {
+		t.Errorf("Unexpected rules: %+v", layer)
+	}
+
+	if _, err := loadRulesFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("Expected an error for a missing rules file, got nil")
+	}
+}
+
+func TestHandleRuleOverrides(t *testing.T) {
+	defer setRuleLayer(ruleSourceRuntime, nil)
+
+	w := httptest.NewRecorder()
+	handleRuleOverrides(w, httptest.NewRequest(http.MethodPut, "/api/v1/rules/overrides",
+		strings.NewReader(`{"kinds": {"GrafanaDashboard": {"ignorePaths": ["spec.resyncPeriod"], "replace": true}}}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", w.Code)
+	}
+	if paths := activeRuleset("GrafanaDashboard").IgnorePaths; !reflect.DeepEqual(paths, []string{"spec.resyncPeriod"}) {
+		t.Errorf("Expected the override to replace the dashboard rules, got %v", paths)
+	}
+
+	w = httptest.NewRecorder()
+	handleRuleOverrides(w, httptest.NewRequest(http.MethodDelete, "/api/v1/rules/overrides", nil))
+	if paths := activeRuleset("GrafanaDashboard").IgnorePaths; !reflect.DeepEqual(paths, ignoredPaths) {
+		t.Errorf("Expected the defaults after clearing overrides, got %v", paths)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+)
+
+// processStart is when the webhook started, for the uptime in /debug/runtime.
+var processStart = time.Now()
+
+// The default registry already exposes the go_* and process_* metrics.
+// The Go collector is replaced by one that also exports the GC and scheduler
+// metrics of the runtime, such as the goroutine scheduling latency, which
+// tell CPU starvation apart from slow comparisons during an incident.
+func init() {
+	prometheus.Unregister(collectors.NewGoCollector())
+	prometheus.MustRegister(collectors.NewGoCollector(
+		collectors.WithGoCollectorRuntimeMetrics(collectors.MetricsGC, collectors.MetricsScheduler),
+	))
+}
+
+// runtimeSnapshot is the /debug/runtime view of the instance.
+type runtimeSnapshot struct {
+	Time            time.Time `json:"time"`
+	Uptime          string    `json:"uptime"`
+	GoVersion       string    `json:"goVersion"`
+	GOMAXPROCS      int       `json:"gomaxprocs"`
+	Goroutines      int       `json:"goroutines"`
+	HeapBytes       int64     `json:"heapBytes"`
+	MemoryLimit     int64     `json:"memoryLimit"`
+	GCCycles        int64     `json:"gcCycles"`
+	LastGC          time.Time `json:"lastGC,omitempty"`
+	LastGCPause     string    `json:"lastGCPause,omitempty"`
+	OpenConnections int64     `json:"openConnections"`
+	Workers         int       `json:"workers"`
+	BusyWorkers     int64     `json:"busyWorkers"`
+	QueueSize       int       `json:"queueSize"`
+	QueueDepth      int64     `json:"queueDepth"`
+	InFlight        int64     `json:"inFlightRequests"`
+	Ready           bool      `json:"ready"`
+	Maintenance     bool      `json:"maintenance"`
+}
+
+// takeRuntimeSnapshot combines the runtime statistics with the webhook's own
+// gauges at now.
+func takeRuntimeSnapshot(now time.Time) runtimeSnapshot {
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+
+	snapshot := runtimeSnapshot{
+		Time:            now,
+		Uptime:          now.Sub(processStart).Round(time.Second).String(),
+		GoVersion:       runtime.Version(),
+		GOMAXPROCS:      runtime.GOMAXPROCS(0),
+		Goroutines:      runtime.NumGoroutine(),
+		HeapBytes:       heapBytes(),
+		MemoryLimit:     debug.SetMemoryLimit(-1),
+		GCCycles:        gc.NumGC,
+		OpenConnections: openConnections.Load(),
+		Workers:         workerCount,
+		BusyWorkers:     busyWorkers.Load(),
+		QueueSize:       queueSize,
+		QueueDepth:      int64(gaugeValue(queueDepth)),
+		InFlight:        inFlightRequests.Load(),
+		Ready:           ready.Load(),
+		Maintenance:     maintenanceMode.Load(),
+	}
+	if gc.NumGC > 0 {
+		snapshot.LastGC = gc.LastGC
+		snapshot.LastGCPause = gc.Pause[0].String()
+	}
+	return snapshot
+}
+
+// gaugeValue returns the current value of g.
+func gaugeValue(g prometheus.Gauge) float64 {
+	var m dto.Metric
+	if err := g.Write(&m); err != nil {
+		return 0
+	}
+	return m.GetGauge().GetValue()
+}
+
+// handleDebugRuntime serves a snapshot of the goroutines, memory, GC,
+// connections and admission queue, a single place to assess the health of an
+// instance during an incident.
+func handleDebugRuntime(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	responseBytes, err := json.Marshal(takeRuntimeSnapshot(time.Now()))
+	if err != nil {
+		log.Errorf("Failed to marshal runtime snapshot: %v", err)
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, responseBytes)
+}
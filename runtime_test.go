@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestGoCollector_ExportsSchedulerMetrics(t *testing.T) {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+
+	names := map[string]bool{}
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+	for _, name := range []string{"go_goroutines", "go_sched_latencies_seconds", "process_open_fds", "grafana_operator_webhook_open_connections", "grafana_operator_webhook_busy_workers"} {
+		if !names[name] {
+			t.Errorf("Expected %s to be exported", name)
+		}
+	}
+}
+
+func TestTrackConnState(t *testing.T) {
+	defer func(n int64) { openConnections.Store(n) }(openConnections.Load())
+	openConnections.Store(0)
+
+	trackConnState(nil, http.StateNew)
+	trackConnState(nil, http.StateNew)
+	trackConnState(nil, http.StateActive)
+	trackConnState(nil, http.StateIdle)
+	trackConnState(nil, http.StateClosed)
+
+	if got := openConnections.Load(); got != 1 {
+		t.Errorf("Expected 1 open connection, got %d", got)
+	}
+}
+
+func TestHandleDebugRuntime(t *testing.T) {
+	defer func(n int64) { openConnections.Store(n) }(openConnections.Load())
+	openConnections.Store(3)
+
+	w := httptest.NewRecorder()
+	handleDebugRuntime(w, httptest.NewRequest(http.MethodGet, "/debug/runtime", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", w.Code)
+	}
+
+	var snapshot runtimeSnapshot
+	if err := json.NewDecoder(w.Body).Decode(&snapshot); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if snapshot.Goroutines < 1 || snapshot.HeapBytes <= 0 || snapshot.GOMAXPROCS < 1 {
+		t.Errorf("Expected runtime statistics, got %+v", snapshot)
+	}
+	if snapshot.OpenConnections != 3 || snapshot.Workers != workerCount || snapshot.QueueSize != queueSize {
+		t.Errorf("Expected the webhook gauges, got %+v", snapshot)
+	}
+
+	w = httptest.NewRecorder()
+	handleDebugRuntime(w, httptest.NewRequest(http.MethodPost, "/debug/runtime", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status code 405, got %d", w.Code)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// selfTestFixtures are known-good admission requests with the decision the
+// webhook must make for them.
+//
+//go:embed selftest/*.json
+var selfTestFixtures embed.FS
+
+type selfTestFixture struct {
+	Description     string                        `json:"description"`
+	ExpectedAllowed bool                          `json:"expectedAllowed"`
+	Request         *admissionv1.AdmissionRequest `json:"request"`
+}
+
+// ready is set once the self-test has passed; until then /readyz fails so the
+// apiserver is not routed to a pod making wrong decisions.
+var ready atomic.Bool
+
+// runSelfTest evaluates every embedded fixture and reports all fixtures whose
+// decision deviates from the expected one.
+func runSelfTest() error {
+	entries, err := selfTestFixtures.ReadDir("selftest")
+	if err != nil {
+		return fmt.Errorf("failed to list self-test fixtures: %w", err)
+	}
+
+	// The fixtures carry no labels, so the object selector would skip them;
+	// it is the comparison that is being tested, not the deployment's scope.
+	filter := kindFilter
+	filter.objectSelector = &metav1.LabelSelector{}
+
+	var errs []error
+	for _, entry := range entries {
+		name := path.Join("selftest", entry.Name())
+		data, err := selfTestFixtures.ReadFile(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+
+		var fixture selfTestFixture
+		if err := json.Unmarshal(data, &fixture); err != nil || fixture.Request == nil {
+			errs = append(errs, fmt.Errorf("%s: invalid fixture: %v", name, err))
+			continue
+		}
+
+		resp, _, err := evaluateWithFilter(context.Background(), filter, fixture.Request)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		if resp.Allowed != fixture.ExpectedAllowed {
+			errs = append(errs, fmt.Errorf("%s: %s: expected allowed=%t, got %t", name, fixture.Description, fixture.ExpectedAllowed, resp.Allowed))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// handleReadyz reports whether the webhook passed its self-test.
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if !ready.Load() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte("ok")); err != nil {
+		log.Errorf("Failed to write readiness response: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRunSelfTest(t *testing.T) {
+	if err := runSelfTest(); err != nil {
+		t.Fatalf("Expected the self-test to pass, got %v", err)
+	}
+}
+
+func TestRunSelfTest_WithObjectSelector(t *testing.T) {
+	defer func(selector *metav1.LabelSelector) { kindFilter.objectSelector = selector }(kindFilter.objectSelector)
+	kindFilter.objectSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}
+
+	if err := runSelfTest(); err != nil {
+		t.Fatalf("Expected the self-test to pass with an object selector, got %v", err)
+	}
+	if kindFilter.objectSelector.MatchLabels["team"] != "a" {
+		t.Errorf("Expected the object selector to be left in place, got %+v", kindFilter.objectSelector)
+	}
+}
+
+func TestRunSelfTest_DetectsDeviation(t *testing.T) {
+	defer func(kind string) { kindFilter.kind = kind }(kindFilter.kind)
+
+	// A filter that no longer selects dashboards allows the no-op fixture.
+	kindFilter.kind = "SomethingElse"
+
+	if err := runSelfTest(); err == nil {
+		t.Errorf("Expected the self-test to fail, got nil")
+	}
+}
+
+func TestHandleReadyz(t *testing.T) {
+	defer ready.Store(ready.Load())
+
+	ready.Store(false)
+	w := httptest.NewRecorder()
+	handleReadyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code 503 before the self-test, got %d", w.Code)
+	}
+
+	ready.Store(true)
+	w = httptest.NewRecorder()
+	handleReadyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code 200 after the self-test, got %d", w.Code)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/store"
+	log "github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// Shutdown happens in two phases with a budget each: first the server stops
+// accepting connections and the admission requests in flight are drained,
+// then the decision exporter, the mirror and the snapshot exporter flush what
+// they buffered and the shutdown summary is written. A drain that runs out of
+// time therefore does not take the telemetry of the replica down with it.
+var (
+	shutdownDrainTimeout = 20 * time.Second
+	shutdownFlushTimeout = 10 * time.Second
+)
+
+// shutdownSummaryTTL is how long the summary of a replica is kept in the
+// state store.
+const shutdownSummaryTTL = 7 * 24 * time.Hour
+
+// lifetimeStats counts the admission requests answered since the start, for
+// the shutdown summary. Prometheus may never scrape a short-lived replica, so
+// these are kept apart from the metrics.
+type lifetimeStats struct {
+	processed atomic.Int64
+	changed   atomic.Int64
+	unchanged atomic.Int64
+	denied    atomic.Int64
+	errors    atomic.Int64
+}
+
+var stats lifetimeStats
+
+// recordAnswered counts an answered admission request.
+func (s *lifetimeStats) recordAnswered(resp *admissionv1.AdmissionResponse, cmp *comparison) {
+	s.processed.Add(1)
+	switch {
+	case isNoopDenial(resp):
+		s.unchanged.Add(1)
+	case !resp.Allowed:
+		s.denied.Add(1)
+	}
+	if cmp != nil && cmp.changed() {
+		s.changed.Add(1)
+	}
+}
+
+// recordError counts an admission request answered with an error.
+func (s *lifetimeStats) recordError() {
+	s.errors.Add(1)
+}
+
+// shutdownSummary is the final record of a replica.
+type shutdownSummary struct {
+	Replica   string    `json:"replica"`
+	Started   time.Time `json:"started"`
+	Stopped   time.Time `json:"stopped"`
+	Processed int64     `json:"processed"`
+	Changed   int64     `json:"changed"`
+	Unchanged int64     `json:"unchanged"`
+	Denied    int64     `json:"denied"`
+	Errors    int64     `json:"errors"`
+}
+
+// summarize returns the summary of the replica at now.
+func (s *lifetimeStats) summarize(now time.Time) shutdownSummary {
+	replica, err := os.Hostname()
+	if err != nil {
+		replica = "unknown"
+	}
+	return shutdownSummary{
+		Replica:   replica,
+		Started:   processStart,
+		Stopped:   now,
+		Processed: s.processed.Load(),
+		Changed:   s.changed.Load(),
+		Unchanged: s.unchanged.Load(),
+		Denied:    s.denied.Load(),
+		Errors:    s.errors.Load(),
+	}
+}
+
+// flushTelemetry is the second phase of the shutdown. Handlers still running
+// after a forced close may emit concurrently; what they emit is dropped and
+// counted, never sent on a closed channel.
+func flushTelemetry(ctx context.Context, s store.Store) {
+	if decisions != nil {
+		decisions.shutdown(ctx)
+	}
+	if mirror != nil {
+		mirror.shutdown(ctx)
+	}
+	if snapshots != nil {
+		snapshots.shutdown(ctx)
+	}
+
+	summary := stats.summarize(time.Now())
+	if err := persistShutdownSummary(ctx, s, summary); err != nil {
+		log.Warnf("Failed to persist the shutdown summary: %v", err)
+	}
+	log.WithFields(log.Fields{
+		"replica":   summary.Replica,
+		"uptime":    summary.Stopped.Sub(summary.Started).Round(time.Second).String(),
+		"processed": summary.Processed,
+		"changed":   summary.Changed,
+		"unchanged": summary.Unchanged,
+		"denied":    summary.Denied,
+		"errors":    summary.Errors,
+	}).Info("Shutdown summary")
+}
+
+// persistShutdownSummary keeps the summary in the state store under
+// shutdown-summary/<replica>. With a bolt or Redis store it outlives the pod.
+func persistShutdownSummary(ctx context.Context, s store.Store, summary shutdownSummary) error {
+	encoded, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	return s.Set(ctx, "shutdown-summary/"+summary.Replica, encoded, shutdownSummaryTTL)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/store"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestLifetimeStats(t *testing.T) {
+	var s lifetimeStats
+
+	s.recordAnswered(&admissionv1.AdmissionResponse{Allowed: true}, &comparison{specChanged: true})
+	s.recordAnswered(&admissionv1.AdmissionResponse{Allowed: false, Result: &metav1.Status{Status: metav1.StatusSuccess}}, &comparison{})
+	s.recordAnswered(&admissionv1.AdmissionResponse{Allowed: false, Result: &metav1.Status{Status: metav1.StatusFailure}}, &comparison{specChanged: true})
+	s.recordAnswered(&admissionv1.AdmissionResponse{Allowed: true}, nil)
+	s.recordError()
+
+	now := time.Now()
+	summary := s.summarize(now)
+	if summary.Processed != 4 || summary.Changed != 2 || summary.Unchanged != 1 || summary.Denied != 1 || summary.Errors != 1 {
+		t.Errorf("Expected 4 processed, 2 changed, 1 unchanged, 1 denied and 1 error, got %+v", summary)
+	}
+	if !summary.Stopped.Equal(now) || !summary.Started.Equal(processStart) || summary.Replica == "" {
+		t.Errorf("Expected the replica and its lifetime, got %+v", summary)
+	}
+}
+
+func TestPersistShutdownSummary(t *testing.T) {
+	s := store.NewMemory()
+	summary := shutdownSummary{Replica: "webhook-0", Processed: 7, Denied: 2}
+
+	if err := persistShutdownSummary(context.Background(), s, summary); err != nil {
+		t.Fatalf("Failed to persist the summary: %v", err)
+	}
+
+	encoded, err := s.Get(context.Background(), "shutdown-summary/webhook-0")
+	if err != nil {
+		t.Fatalf("Expected the summary to be stored: %v", err)
+	}
+	var stored shutdownSummary
+	if err := json.Unmarshal(encoded, &stored); err != nil {
+		t.Fatalf("Failed to decode the summary: %v", err)
+	}
+	if stored.Processed != 7 || stored.Denied != 2 {
+		t.Errorf("Expected the persisted counts, got %+v", stored)
+	}
+}
+
+func TestFlushTelemetry(t *testing.T) {
+	exporter := newDecisionExporter("http://127.0.0.1:0")
+	defer func(e *decisionExporter) { decisions = e }(decisions)
+	decisions = exporter
+
+	s := store.NewMemory()
+	flushTelemetry(context.Background(), s)
+
+	select {
+	case <-exporter.done:
+	default:
+		t.Errorf("Expected the decision exporter to be shut down")
+	}
+	summary := stats.summarize(time.Now())
+	if _, err := s.Get(context.Background(), "shutdown-summary/"+summary.Replica); err != nil {
+		t.Errorf("Expected the summary to be persisted: %v", err)
+	}
+}
+
+// A forced shutdown does not wait for running handlers, so they may answer
+// after the second phase has stopped the pool and the exporters.
+func TestFlushTelemetry_HandlerAfterForcedClose(t *testing.T) {
+	defer func(e *decisionExporter, m *requestMirror, s *snapshotExporter) {
+		decisions, mirror, snapshots = e, m, s
+	}(decisions, mirror, snapshots)
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer collector.Close()
+	decisions = newDecisionExporter(collector.URL)
+	m, err := newRequestMirror(newDownstream(t, true).URL, "")
+	if err != nil {
+		t.Fatalf("Failed to create mirror: %v", err)
+	}
+	mirror = m
+	snapshots = newTestSnapshotExporter(t, func(w http.ResponseWriter, r *http.Request) {})
+	go snapshots.run(time.Hour)
+
+	pool := newWorkerPool(1, 1, http.HandlerFunc(handleAdmissionReview))
+	pool.stop(context.Background())
+	flushTelemetry(context.Background(), store.NewMemory())
+
+	body, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       "late-uid",
+			Kind:      metav1.GroupVersionKind{Kind: "GrafanaDashboard"},
+			Namespace: "ns",
+			Name:      "late",
+			Operation: admissionv1.Update,
+			OldObject: runtime.RawExtension{Raw: []byte(`{"spec": {"json": "{\"title\": \"a\"}"}}`)},
+			Object:    runtime.RawExtension{Raw: []byte(`{"spec": {"json": "{\"title\": \"b\"}"}}`)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	// Neither the pool nor the handler itself may panic
+	w := httptest.NewRecorder()
+	pool.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected the stopped pool to reject the request, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	handleAdmissionReview(w, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the late handler to answer, got %d", w.Code)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// timeoutWarningRatio is the share of the apiserver timeout above which a
+// request is logged as close to being abandoned.
+const timeoutWarningRatio = 0.8
+
+var (
+	// Histogram of the share of the apiserver timeout each request consumed
+	timeoutBudgetConsumed = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "grafana_operator_webhook_timeout_budget_consumed_ratio",
+			Help:    "Fraction of the webhook's apiserver timeout consumed by each admission request, including time spent queued.",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 0.75, 0.9, 1},
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(timeoutBudgetConsumed)
+}
+
+// responseMargin is kept from the apiserver timeout for writing the response.
+// A downstream webhook or authorizer call cut off by the request deadline is
+// then still answered by the webhook under its own failure policy, rather
+// than timed out by the apiserver under failurePolicy Ignore.
+const responseMargin = 250 * time.Millisecond
+
+// withRequestDeadline bounds ctx by the apiserver timeout of a request that
+// arrived at start, less responseMargin. Downstream webhooks and the external
+// authorizer run one after the other under this single deadline, so together
+// they cannot outlast the apiserver.
+func withRequestDeadline(ctx context.Context, start time.Time) (context.Context, context.CancelFunc) {
+	if webhookTimeoutSeconds <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, start.Add(time.Duration(webhookTimeoutSeconds)*time.Second-responseMargin))
+}
+
+// observeTimeoutBudget records how much of the apiserver timeout
+// (webhookTimeoutSeconds) a request that arrived at start has consumed.
+func observeTimeoutBudget(start time.Time) {
+	if webhookTimeoutSeconds <= 0 {
+		return
+	}
+
+	elapsed := time.Since(start)
+	timeout := time.Duration(webhookTimeoutSeconds) * time.Second
+	ratio := elapsed.Seconds() / timeout.Seconds()
+	timeoutBudgetConsumed.Observe(ratio)
+	recentBudgets.observe(ratio, time.Now())
+
+	if ratio >= timeoutWarningRatio {
+		log.Warnf("Admission request took %s of its %s timeout, headroom %s", elapsed, timeout, timeout-elapsed)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestObserveTimeoutBudget(t *testing.T) {
+	observeTimeoutBudget(time.Now().Add(-time.Duration(webhookTimeoutSeconds) * time.Second / 2))
+
+	metric := &dto.Metric{}
+	if err := timeoutBudgetConsumed.Write(metric); err != nil {
+		t.Fatalf("Failed to read histogram: %v", err)
+	}
+	if metric.Histogram.GetSampleCount() == 0 {
+		t.Fatalf("Expected an observation")
+	}
+	if sum := metric.Histogram.GetSampleSum(); sum < 0.5 {
+		t.Errorf("Expected at least half the budget consumed, got %v", sum)
+	}
+}
+
+func TestWithRequestDeadline(t *testing.T) {
+	start := time.Now().Add(-time.Second)
+	ctx, cancel := withRequestDeadline(context.Background(), start)
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		t.Fatalf("Expected a deadline")
+	}
+	if expected := start.Add(time.Duration(webhookTimeoutSeconds)*time.Second - responseMargin); !deadline.Equal(expected) {
+		t.Errorf("Expected the deadline %s, got %s", expected, deadline)
+	}
+}
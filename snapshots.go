@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/objectstore"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// With --snapshot-bucket every decision that carries a diff or a denial is
+// kept for offline analysis beyond the Prometheus retention. Decisions are
+// buffered and uploaded every snapshotInterval as gzip-compressed NDJSON under
+//
+//	<prefix>/cluster=<cluster>/date=<YYYY-MM-DD>/<replica>-<HHMMSS>.ndjson.gz
+//
+// so the objects can be queried as a partitioned table. When uploads fall
+// behind, records beyond snapshotMaxRecords are dropped.
+var (
+	snapshotBucketURL  = ""
+	snapshotCluster    = "default"
+	snapshotInterval   = 5 * time.Minute
+	snapshotMaxRecords = 10000
+)
+
+var (
+	// Counter for snapshot uploads, by result
+	snapshotUploadsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_snapshot_uploads_total",
+			Help: "Total number of decision snapshots uploaded to object storage, differentiated by result.",
+		},
+		[]string{"result"}, // result is "success" or "error"
+	)
+
+	// Counter for decision records that were not uploaded
+	snapshotDroppedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_snapshot_dropped_total",
+			Help: "Total number of decision records dropped because the snapshot buffer was full or the upload failed.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(snapshotUploadsTotal)
+	prometheus.MustRegister(snapshotDroppedTotal)
+}
+
+// snapshotRecord is one line of a snapshot.
+type snapshotRecord struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	explainedDecision
+}
+
+// snapshotExporter buffers decision records and uploads them to a bucket.
+type snapshotExporter struct {
+	bucket  *objectstore.Bucket
+	cluster string
+	replica string
+
+	mu      sync.Mutex
+	records []snapshotRecord
+	closed  bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// snapshots is nil unless --snapshot-bucket is set.
+var snapshots *snapshotExporter
+
+func newSnapshotExporter(bucket *objectstore.Bucket, cluster string) *snapshotExporter {
+	replica, err := os.Hostname()
+	if err != nil {
+		replica = "unknown"
+	}
+	return &snapshotExporter{
+		bucket:  bucket,
+		cluster: cluster,
+		replica: replica,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// add buffers the decision made for req if it carries a diff or a denial.
+// No-op updates are denied with a success code and are not buffered either.
+// It never blocks the admission path.
+func (e *snapshotExporter) add(req *admissionv1.AdmissionRequest, decision explainedDecision) {
+	if len(decision.Diff) == 0 && (decision.Allowed || decision.Code == http.StatusOK) {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed || len(e.records) >= snapshotMaxRecords {
+		snapshotDroppedTotal.Inc()
+		return
+	}
+	e.records = append(e.records, snapshotRecord{
+		Kind:              req.Kind.Kind,
+		Namespace:         req.Namespace,
+		Name:              req.Name,
+		explainedDecision: decision,
+	})
+}
+
+func (e *snapshotExporter) run(interval time.Duration) {
+	defer close(e.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.flush(context.Background(), time.Now())
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+// flush uploads the buffered records as one snapshot taken at now.
+func (e *snapshotExporter) flush(ctx context.Context, now time.Time) {
+	e.mu.Lock()
+	records := e.records
+	e.records = nil
+	e.mu.Unlock()
+
+	if len(records) == 0 {
+		return
+	}
+
+	body, err := encodeSnapshot(records)
+	if err == nil {
+		err = e.bucket.Put(ctx, e.snapshotKey(now), body, "application/x-ndjson", "gzip")
+	}
+	if err != nil {
+		log.Errorf("Failed to upload snapshot of %d decision records: %v", len(records), err)
+		snapshotUploadsTotal.WithLabelValues("error").Inc()
+		snapshotDroppedTotal.Add(float64(len(records)))
+		return
+	}
+	snapshotUploadsTotal.WithLabelValues("success").Inc()
+}
+
+// snapshotKey returns the key of the snapshot taken at now, partitioned by
+// cluster and UTC date. The time has nanosecond precision, so a shutdown
+// flush right after a periodic one does not overwrite it.
+func (e *snapshotExporter) snapshotKey(now time.Time) string {
+	now = now.UTC()
+	return fmt.Sprintf("cluster=%s/date=%s/%s-%s.ndjson.gz",
+		e.cluster, now.Format(time.DateOnly), e.replica, now.Format("150405.000000000"))
+}
+
+// encodeSnapshot returns records as gzip-compressed NDJSON.
+func encodeSnapshot(records []snapshotRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(zw)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// shutdown stops the exporter and uploads the records still buffered, unless
+// ctx expires first. Records added afterwards are dropped.
+func (e *snapshotExporter) shutdown(ctx context.Context) {
+	close(e.stop)
+	<-e.done
+
+	e.mu.Lock()
+	e.closed = true
+	e.mu.Unlock()
+	e.flush(ctx, time.Now())
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/objectstore"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestSnapshotExporter(t *testing.T, handler http.HandlerFunc) *snapshotExporter {
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	bucket, err := objectstore.Open("s3://snapshots/webhook?endpoint=" + server.URL)
+	if err != nil {
+		t.Fatalf("Failed to open bucket: %v", err)
+	}
+	e := newSnapshotExporter(bucket, "prod")
+	e.replica = "replica-0"
+	return e
+}
+
+func TestSnapshotExporter_Flush(t *testing.T) {
+	var path string
+	var records []snapshotRecord
+	e := newTestSnapshotExporter(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("Expected a gzip body: %v", err)
+			return
+		}
+		scanner := bufio.NewScanner(zr)
+		for scanner.Scan() {
+			var record snapshotRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				t.Errorf("Failed to decode record: %v", err)
+			}
+			records = append(records, record)
+		}
+	})
+
+	req := &admissionv1.AdmissionRequest{Kind: metav1.GroupVersionKind{Kind: "GrafanaDashboard"}, Namespace: "ns", Name: "dash"}
+	e.add(req, explainedDecision{Allowed: true, Diff: []difference{{Path: "spec.json.title"}}})
+	e.add(req, explainedDecision{Allowed: false, Code: http.StatusForbidden})
+	e.add(req, explainedDecision{Allowed: true})
+	e.add(req, explainedDecision{Allowed: false, Code: http.StatusOK, Message: "Update successful."})
+
+	e.flush(context.Background(), time.Date(2026, 3, 4, 5, 6, 7, 8000, time.UTC))
+
+	if path != "/snapshots/webhook/cluster=prod/date=2026-03-04/replica-0-050607.000008000.ndjson.gz" {
+		t.Errorf("Expected a partitioned key, got %s", path)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected the diff and the denial, got %+v", records)
+	}
+	if records[0].Name != "dash" || len(records[0].Diff) != 1 || records[0].Diff[0].Path != "spec.json.title" {
+		t.Errorf("Expected the diff of dash, got %+v", records[0])
+	}
+	if records[1].Allowed || records[1].Code != http.StatusForbidden {
+		t.Errorf("Expected the denial, got %+v", records[1])
+	}
+}
+
+func TestSnapshotExporter_FlushFailure(t *testing.T) {
+	e := newTestSnapshotExporter(t, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	req := &admissionv1.AdmissionRequest{Kind: metav1.GroupVersionKind{Kind: "GrafanaDashboard"}, Name: "dash"}
+	e.add(req, explainedDecision{Allowed: false})
+
+	before := testutil.ToFloat64(snapshotDroppedTotal)
+	e.flush(context.Background(), time.Now())
+	if dropped := testutil.ToFloat64(snapshotDroppedTotal) - before; dropped != 1 {
+		t.Errorf("Expected 1 dropped record, got %v", dropped)
+	}
+	if len(e.records) != 0 {
+		t.Errorf("Expected the buffer to be emptied, got %d records", len(e.records))
+	}
+}
+
+func TestSnapshotExporter_BufferFull(t *testing.T) {
+	defer func(n int) { snapshotMaxRecords = n }(snapshotMaxRecords)
+	snapshotMaxRecords = 1
+
+	e := newTestSnapshotExporter(t, func(w http.ResponseWriter, r *http.Request) {})
+	req := &admissionv1.AdmissionRequest{Name: "dash"}
+
+	before := testutil.ToFloat64(snapshotDroppedTotal)
+	e.add(req, explainedDecision{Allowed: false})
+	e.add(req, explainedDecision{Allowed: false})
+	if dropped := testutil.ToFloat64(snapshotDroppedTotal) - before; dropped != 1 {
+		t.Errorf("Expected 1 dropped record, got %v", dropped)
+	}
+}
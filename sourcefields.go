@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sourceFields are the spec fields that say where a dashboard comes from and
+// where it goes. Counting their changes shows how often dashboards are
+// repointed to another source, re-pinned to another revision or moved.
+var sourceFields = activeProfile.SourceFields
+
+var (
+	// Counter for allowed changes to dashboard source fields, by field
+	sourceFieldChangesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_source_field_changes_total",
+			Help: "Total number of allowed updates changing a dashboard source field, differentiated by field.",
+		},
+		[]string{"field"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(sourceFieldChangesTotal)
+}
+
+// lookupField returns the value at a dot path, or nil if it is missing.
+func lookupField(obj map[string]interface{}, path string) interface{} {
+	var value interface{} = obj
+	for _, key := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+// changedSourceFields returns the source fields that differ between the
+// compared objects.
+func changedSourceFields(cmp comparison) []string {
+	if cmp.partial || !cmp.specChanged {
+		return nil
+	}
+
+	var changed []string
+	for _, field := range sourceFields {
+		if !reflect.DeepEqual(lookupField(cmp.oldObj, field), lookupField(cmp.newObj, field)) {
+			changed = append(changed, field)
+		}
+	}
+	return changed
+}
+
+// recordSourceFieldChanges counts the source fields changed by an allowed
+// update.
+func recordSourceFieldChanges(cmp comparison, allowed bool) {
+	if !allowed {
+		return
+	}
+	for _, field := range changedSourceFields(cmp) {
+		sourceFieldChangesTotal.WithLabelValues(field).Inc()
+	}
+}
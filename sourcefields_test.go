@@ -0,0 +1,24 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestChangedSourceFields(t *testing.T) {
+	var cmp comparison
+	json.Unmarshal([]byte(`{"spec": {"url": "https://a", "grafanaCom": {"id": 1860, "revision": 30}, "folder": "ops"}}`), &cmp.oldObj)
+	json.Unmarshal([]byte(`{"spec": {"url": "https://b", "grafanaCom": {"id": 1860, "revision": 31}, "folder": "ops", "json": "{}"}}`), &cmp.newObj)
+	cmp.specChanged = true
+
+	expected := []string{"spec.url", "spec.grafanaCom.revision"}
+	if changed := changedSourceFields(cmp); !reflect.DeepEqual(changed, expected) {
+		t.Errorf("Expected changed source fields %v, got %v", expected, changed)
+	}
+
+	cmp.partial = true
+	if changed := changedSourceFields(cmp); changed != nil {
+		t.Errorf("Expected no source fields for a partial comparison, got %v", changed)
+	}
+}
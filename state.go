@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+
+	"github.com/hsiaoairplane/grafana-operator-webhook/pkg/store"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// stateStoreURL selects where the response cache, the deny-loop counters, the
+// spec change buckets and cached authorizer decisions are kept. The default
+// keeps them in memory per replica; bolt:///path keeps them across restarts
+// and redis://host:port/db shares them between replicas.
+var stateStoreURL = "memory://"
+
+// state is the open state store.
+var state store.Store = store.NewMemory()
+
+var (
+	// Counter for failed state store operations
+	stateStoreErrorsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_state_store_errors_total",
+			Help: "Total number of state store operations that failed and were skipped.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(stateStoreErrorsTotal)
+}
+
+// openStateStore opens the configured store and moves the stateful
+// components onto it.
+func openStateStore() (store.Store, error) {
+	s, err := store.Open(stateStoreURL)
+	if err != nil {
+		return nil, err
+	}
+	state = s
+	admissionResponses = newResponseCache(s)
+	denyLoops = newDenyLoopDetector(s)
+	specChanges = newSpecChangeLimiter(s)
+	return s, nil
+}
+
+// stateStoreFailed records a failed store operation. State is an
+// optimization, so callers carry on as if the key did not exist.
+func stateStoreFailed(ctx context.Context, operation string, err error) {
+	stateStoreErrorsTotal.Inc()
+	loggerFromContext(ctx).Warnf("State store %s failed: %v", operation, err)
+}
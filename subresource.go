@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// comparableSubresources are the subresources whose requests carry the whole
+// parent object, so they can be compared like updates of the object itself.
+// Requests for any other subresource, such as scale, carry a payload with a
+// different schema and are passed through untouched.
+var comparableSubresources = map[string]bool{
+	"":       true,
+	"status": true,
+}
+
+var (
+	// Counter for subresource requests passed through without comparison
+	subresourcePassthroughTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_subresource_passthrough_total",
+			Help: "Total number of subresource requests allowed without comparison, differentiated by subresource.",
+		},
+		[]string{"subresource"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(subresourcePassthroughTotal)
+}
+
+// isPassthroughSubresource reports whether req targets a subresource whose
+// payload is not the parent object.
+func isPassthroughSubresource(req *admissionv1.AdmissionRequest) bool {
+	return !comparableSubresources[req.SubResource]
+}
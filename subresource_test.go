@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestSubresourcePassthrough(t *testing.T) {
+	tests := []struct {
+		name            string
+		kind            string
+		subresource     string
+		oldObj          string
+		newObj          string
+		expectedAllowed bool
+		passedThrough   bool
+	}{
+		{
+			name:            "scale",
+			kind:            "Scale",
+			subresource:     "scale",
+			oldObj:          `{"kind": "Scale", "spec": {"replicas": 1}}`,
+			newObj:          `{"kind": "Scale", "spec": {"replicas": 1}}`,
+			expectedAllowed: true,
+			passedThrough:   true,
+		},
+		{
+			name:            "unknown subresource of the object",
+			kind:            "GrafanaDashboard",
+			subresource:     "custom",
+			oldObj:          `{"spec": {"folder": "a"}}`,
+			newObj:          `{"spec": {"folder": "a"}}`,
+			expectedAllowed: true,
+			passedThrough:   true,
+		},
+		{
+			name:            "status is compared",
+			kind:            "GrafanaDashboard",
+			subresource:     "status",
+			oldObj:          `{"metadata": {"generation": 1}, "status": {"hash": "a"}}`,
+			newObj:          `{"metadata": {"generation": 2}, "status": {"hash": "a"}}`,
+			expectedAllowed: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reqBytes, err := json.Marshal(admissionv1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request: &admissionv1.AdmissionRequest{
+					UID:         types.UID("subresource-" + test.name),
+					Kind:        metav1.GroupVersionKind{Kind: test.kind},
+					Resource:    metav1.GroupVersionResource{Resource: "grafanadashboards"},
+					SubResource: test.subresource,
+					Namespace:   "ns",
+					Name:        "dash",
+					Operation:   admissionv1.Update,
+					OldObject:   runtime.RawExtension{Raw: []byte(test.oldObj)},
+					Object:      runtime.RawExtension{Raw: []byte(test.newObj)},
+				},
+			})
+			if err != nil {
+				t.Fatalf("Failed to marshal request: %v", err)
+			}
+
+			before := testutil.ToFloat64(subresourcePassthroughTotal.WithLabelValues(test.subresource))
+			w := httptest.NewRecorder()
+			handleAdmissionReview(w, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(reqBytes)))
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status code 200, got %d", w.Code)
+			}
+
+			var review admissionv1.AdmissionReview
+			if err := json.NewDecoder(w.Body).Decode(&review); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if review.Response.Allowed != test.expectedAllowed {
+				t.Errorf("Expected allowed to be %t, got %+v", test.expectedAllowed, review.Response)
+			}
+
+			passed := testutil.ToFloat64(subresourcePassthroughTotal.WithLabelValues(test.subresource)) - before
+			if (passed == 1) != test.passedThrough {
+				t.Errorf("Expected passthrough to be %t, counted %v", test.passedThrough, passed)
+			}
+		})
+	}
+}
+
+func TestKindFilter_Subresources(t *testing.T) {
+	req := &admissionv1.AdmissionRequest{Kind: metav1.GroupVersionKind{Kind: "GrafanaDashboard"}, Operation: admissionv1.Update}
+	for subresource, expected := range map[string]bool{"": true, "status": true, "scale": false, "custom": false} {
+		req.SubResource = subresource
+		if got := kindFilter.matches(req); got != expected {
+			t.Errorf("Expected %q to match %t, got %t", subresource, expected, got)
+		}
+	}
+}
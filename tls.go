@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	stdlog "log"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	tlsCertFile = "/certs/tls.crt"
+	tlsKeyFile  = "/certs/tls.key"
+)
+
+var (
+	// Gauge exposing the NotAfter of the serving certificate so alerts can
+	// fire before it expires and the apiserver starts rejecting calls.
+	tlsCertExpiry = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "grafana_operator_webhook_tls_cert_expiry_timestamp_seconds",
+			Help: "Expiry time of the webhook serving certificate in seconds since the Unix epoch.",
+		},
+	)
+
+	// Counter for failed TLS handshakes, typically a CA bundle mismatch or an
+	// expired certificate on either side.
+	tlsHandshakeErrorsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_tls_handshake_errors_total",
+			Help: "Total number of failed TLS handshakes on the webhook server.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(tlsCertExpiry)
+	prometheus.MustRegister(tlsHandshakeErrorsTotal)
+}
+
+// loadTLSConfig loads the serving key pair and records the certificate
+// expiry metric.
+func loadTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load key pair: %w", err)
+	}
+
+	leaf := cert.Leaf
+	if leaf == nil {
+		leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+	}
+	tlsCertExpiry.Set(float64(leaf.NotAfter.Unix()))
+	log.Infof("Loaded serving certificate %s, expires at %s", leaf.Subject.CommonName, leaf.NotAfter)
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// serverErrorLogWriter forwards net/http server errors to logrus and counts
+// TLS handshake failures, which net/http only reports through ErrorLog.
+type serverErrorLogWriter struct{}
+
+func (serverErrorLogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSpace(string(p))
+	if strings.Contains(msg, "TLS handshake error") {
+		tlsHandshakeErrorsTotal.Inc()
+		log.Debug(msg)
+	} else {
+		log.Warn(msg)
+	}
+	return len(p), nil
+}
+
+func newServerErrorLog() *stdlog.Logger {
+	return stdlog.New(serverErrorLogWriter{}, "", 0)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// writeTestKeyPair writes a self-signed certificate and key expiring at
+// notAfter into dir and returns their paths.
+func writeTestKeyPair(t *testing.T, dir string, notAfter time.Time) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "webhook.grafana-operator.svc"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestLoadTLSConfig_RecordsExpiry(t *testing.T) {
+	notAfter := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+	certFile, keyFile := writeTestKeyPair(t, t.TempDir(), notAfter)
+
+	cfg, err := loadTLSConfig(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load TLS config: %v", err)
+	}
+	if len(cfg.Certificates) != 1 {
+		t.Fatalf("Expected 1 certificate, got %d", len(cfg.Certificates))
+	}
+
+	if got := testutil.ToFloat64(tlsCertExpiry); got != float64(notAfter.Unix()) {
+		t.Errorf("Expected expiry %d, got %v", notAfter.Unix(), got)
+	}
+}
+
+func TestLoadTLSConfig_MissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := loadTLSConfig(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")); err == nil {
+		t.Errorf("Expected an error for missing key pair, got nil")
+	}
+}
+
+func TestServerErrorLog_CountsHandshakeErrors(t *testing.T) {
+	before := testutil.ToFloat64(tlsHandshakeErrorsTotal)
+
+	errorLog := newServerErrorLog()
+	errorLog.Printf("http: TLS handshake error from 10.0.0.1:443: remote error: tls: bad certificate")
+	errorLog.Printf("http: superfluous response.WriteHeader call")
+
+	if got := testutil.ToFloat64(tlsHandshakeErrorsTotal) - before; got != 1 {
+		t.Errorf("Expected 1 handshake error, got %v", got)
+	}
+}
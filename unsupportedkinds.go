@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// unsupportedKindWarnInterval is how often a warning is logged per kind the
+// webhook has no profile for. Such requests come from webhook rules that
+// select more than the profile kind; they are allowed, but each one costs the
+// apiserver a round trip.
+var unsupportedKindWarnInterval = time.Minute
+
+var (
+	// Counter for requests of kinds the webhook is not built for
+	unsupportedKindRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_unsupported_kind_requests_total",
+			Help: "Total number of admission requests for kinds the webhook has no profile for, differentiated by group, version and kind.",
+		},
+		[]string{"group", "version", "kind"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(unsupportedKindRequestsTotal)
+}
+
+// unsupportedKind is the /debug/unsupported-kinds view of one
+// GroupVersionKind.
+type unsupportedKind struct {
+	Group     string    `json:"group"`
+	Version   string    `json:"version"`
+	Kind      string    `json:"kind"`
+	Requests  int64     `json:"requests"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+
+	lastWarned time.Time
+}
+
+// unsupportedKindTracker counts requests per unsupported GroupVersionKind.
+// Only kinds selected by the webhook rules of the cluster can show up, which
+// bounds the map.
+type unsupportedKindTracker struct {
+	mu    sync.Mutex
+	kinds map[metav1.GroupVersionKind]*unsupportedKind
+}
+
+var unsupportedKinds = newUnsupportedKindTracker()
+
+func newUnsupportedKindTracker() *unsupportedKindTracker {
+	return &unsupportedKindTracker{kinds: make(map[metav1.GroupVersionKind]*unsupportedKind)}
+}
+
+// isUnsupportedKind reports whether req is for a kind other than the one of
+// the active profile.
+func isUnsupportedKind(req *admissionv1.AdmissionRequest) bool {
+	return req.Kind.Kind != kindFilter.kind
+}
+
+// observe counts a request for an unsupported kind at now and logs a warning
+// at most once per unsupportedKindWarnInterval for each kind.
+func (t *unsupportedKindTracker) observe(ctx context.Context, req *admissionv1.AdmissionRequest, now time.Time) {
+	gvk := req.Kind
+	unsupportedKindRequestsTotal.WithLabelValues(gvk.Group, gvk.Version, gvk.Kind).Inc()
+
+	t.mu.Lock()
+	entry, ok := t.kinds[gvk]
+	if !ok {
+		entry = &unsupportedKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind, FirstSeen: now}
+		t.kinds[gvk] = entry
+	}
+	entry.Requests++
+	entry.LastSeen = now
+	warn := now.Sub(entry.lastWarned) >= unsupportedKindWarnInterval
+	if warn {
+		entry.lastWarned = now
+	}
+	requests := entry.Requests
+	t.mu.Unlock()
+
+	if warn {
+		loggerFromContext(ctx).Warnf("Received %d requests for %s, which has no profile, check the rules of the webhook configuration", requests, gvk.String())
+	}
+}
+
+// top returns up to limit unsupported kinds, most requested first. A limit
+// of 0 returns all of them.
+func (t *unsupportedKindTracker) top(limit int) []unsupportedKind {
+	t.mu.Lock()
+	result := make([]unsupportedKind, 0, len(t.kinds))
+	for _, entry := range t.kinds {
+		result = append(result, *entry)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Requests != result[j].Requests {
+			return result[i].Requests > result[j].Requests
+		}
+		return result[i].Group+"/"+result[i].Version+"/"+result[i].Kind < result[j].Group+"/"+result[j].Version+"/"+result[j].Kind
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// handleDebugUnsupportedKinds serves the kinds the webhook received but has
+// no profile for, most requested first, up to the limit query parameter.
+func handleDebugUnsupportedKinds(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 10
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	responseBytes, err := json.Marshal(struct {
+		Kinds []unsupportedKind `json:"kinds"`
+	}{unsupportedKinds.top(limit)})
+	if err != nil {
+		log.Errorf("Failed to marshal unsupported kinds: %v", err)
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, responseBytes)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestUnsupportedKindTracker(t *testing.T) {
+	tracker := newUnsupportedKindTracker()
+	configMaps := &admissionv1.AdmissionRequest{Kind: metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}}
+	secrets := &admissionv1.AdmissionRequest{Kind: metav1.GroupVersionKind{Version: "v1", Kind: "Secret"}}
+	counted := testutil.ToFloat64(unsupportedKindRequestsTotal.WithLabelValues("", "v1", "ConfigMap"))
+
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	tracker.observe(context.Background(), configMaps, now)
+	tracker.observe(context.Background(), configMaps, now.Add(time.Second))
+	tracker.observe(context.Background(), secrets, now.Add(2*time.Second))
+
+	if got := testutil.ToFloat64(unsupportedKindRequestsTotal.WithLabelValues("", "v1", "ConfigMap")) - counted; got != 2 {
+		t.Errorf("Expected 2 ConfigMap requests to be counted, got %v", got)
+	}
+
+	top := tracker.top(0)
+	if len(top) != 2 || top[0].Kind != "ConfigMap" || top[1].Kind != "Secret" {
+		t.Fatalf("Expected ConfigMap before Secret, got %+v", top)
+	}
+	if top[0].Requests != 2 || !top[0].FirstSeen.Equal(now) || !top[0].LastSeen.Equal(now.Add(time.Second)) {
+		t.Errorf("Expected 2 ConfigMap requests seen from %v, got %+v", now, top[0])
+	}
+	if got := tracker.top(1); len(got) != 1 {
+		t.Errorf("Expected the limit to apply, got %+v", got)
+	}
+}
+
+func TestUnsupportedKindTracker_RateLimitsWarnings(t *testing.T) {
+	tracker := newUnsupportedKindTracker()
+	req := &admissionv1.AdmissionRequest{Kind: metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}}
+
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	tracker.observe(context.Background(), req, now)
+	tracker.observe(context.Background(), req, now.Add(time.Second))
+	warned := tracker.kinds[req.Kind].lastWarned
+	if !warned.Equal(now) {
+		t.Errorf("Expected the second warning to be suppressed, last warned at %v", warned)
+	}
+
+	tracker.observe(context.Background(), req, now.Add(unsupportedKindWarnInterval))
+	warned = tracker.kinds[req.Kind].lastWarned
+	if !warned.Equal(now.Add(unsupportedKindWarnInterval)) {
+		t.Errorf("Expected a warning after the interval, last warned at %v", warned)
+	}
+}
+
+func TestEvaluateRequest_UnsupportedKind(t *testing.T) {
+	req := &admissionv1.AdmissionRequest{
+		UID:       "test-uid-unsupported",
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Operation: admissionv1.Update,
+	}
+	counted := testutil.ToFloat64(unsupportedKindRequestsTotal.WithLabelValues("", "v1", "Pod"))
+
+	resp, cmp, err := evaluateRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !resp.Allowed || cmp != nil {
+		t.Errorf("Expected the request to be allowed without comparison, got %+v", resp)
+	}
+	if got := testutil.ToFloat64(unsupportedKindRequestsTotal.WithLabelValues("", "v1", "Pod")) - counted; got != 0 {
+		t.Errorf("Expected evaluateRequest not to count the request, got %v", got)
+	}
+
+	recordUncompared(context.Background(), req)
+	if got := testutil.ToFloat64(unsupportedKindRequestsTotal.WithLabelValues("", "v1", "Pod")) - counted; got != 1 {
+		t.Errorf("Expected 1 Pod request to be counted, got %v", got)
+	}
+}
+
+func TestHandleDebugUnsupportedKinds(t *testing.T) {
+	defer func(tracker *unsupportedKindTracker) { unsupportedKinds = tracker }(unsupportedKinds)
+	unsupportedKinds = newUnsupportedKindTracker()
+	unsupportedKinds.observe(context.Background(), &admissionv1.AdmissionRequest{Kind: metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}}, time.Now())
+
+	w := httptest.NewRecorder()
+	handleDebugUnsupportedKinds(w, httptest.NewRequest(http.MethodGet, "/debug/unsupported-kinds", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Kinds []unsupportedKind `json:"kinds"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Kinds) != 1 || response.Kinds[0].Kind != "ConfigMap" || response.Kinds[0].Requests != 1 {
+		t.Errorf("Expected one ConfigMap request, got %+v", response.Kinds)
+	}
+
+	w = httptest.NewRecorder()
+	handleDebugUnsupportedKinds(w, httptest.NewRequest(http.MethodGet, "/debug/unsupported-kinds?limit=-1", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code 400, got %d", w.Code)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// With --register-webhook the leader keeps the ValidatingWebhookConfiguration
+// in the state described by these settings, restoring it whenever it drifts
+// (CA bundle rotated, rules edited by hand, configuration deleted).
+var (
+	registerWebhook          = false
+	webhookConfigName        = "application-admission-webhook"
+	webhookServiceName       = "webhook"
+	webhookServiceNamespace  = "grafana-operator"
+	webhookCAFile            = "/certs/ca.crt"
+	webhookTimeoutSeconds    = int32(3)
+	webhookReconcileInterval = time.Minute
+)
+
+var (
+	// Counter for corrections of the registered webhook configuration
+	webhookConfigDriftCorrectedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "grafana_operator_webhook_config_drift_corrected_total",
+			Help: "Total number of times the ValidatingWebhookConfiguration was created or restored to its desired state.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(webhookConfigDriftCorrectedTotal)
+}
+
+// desiredWebhookConfiguration returns the ValidatingWebhookConfiguration this
+// webhook should be registered with. Rules and object selector come from
+// kindFilter so cluster-level filtering matches in-process filtering.
+// Fields the apiserver would default are set explicitly so the result can be
+// compared to the live object.
+func desiredWebhookConfiguration(caBundle []byte) *admissionregistrationv1.ValidatingWebhookConfiguration {
+	path := "/validate"
+	port := int32(443)
+	failurePolicy := admissionregistrationv1.Ignore
+	matchPolicy := admissionregistrationv1.Equivalent
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+	timeoutSeconds := webhookTimeoutSeconds
+
+	return &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: webhookConfigName,
+		},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{
+			{
+				Name:                    "application.admission.webhook",
+				AdmissionReviewVersions: []string{"v1"},
+				ClientConfig: admissionregistrationv1.WebhookClientConfig{
+					Service: &admissionregistrationv1.ServiceReference{
+						Name:      webhookServiceName,
+						Namespace: webhookServiceNamespace,
+						Path:      &path,
+						Port:      &port,
+					},
+					CABundle: caBundle,
+				},
+				Rules:             kindFilter.rules(),
+				FailurePolicy:     &failurePolicy,
+				MatchPolicy:       &matchPolicy,
+				NamespaceSelector: &metav1.LabelSelector{},
+				ObjectSelector:    kindFilter.objectSelector,
+				SideEffects:       &sideEffects,
+				TimeoutSeconds:    &timeoutSeconds,
+			},
+		},
+	}
+}
+
+// reconcileWebhookConfiguration creates the webhook configuration or restores
+// its webhooks to the desired state. It reports whether anything was changed.
+func reconcileWebhookConfiguration(ctx context.Context, client kubernetes.Interface) (bool, error) {
+	caBundle, err := os.ReadFile(webhookCAFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	desired := desiredWebhookConfiguration(caBundle)
+
+	webhookConfigs := client.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	current, err := webhookConfigs.Get(ctx, desired.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		if _, err := webhookConfigs.Create(ctx, desired, metav1.CreateOptions{}); err != nil {
+			return false, fmt.Errorf("failed to create webhook configuration: %w", err)
+		}
+		log.Infof("Created ValidatingWebhookConfiguration %s", desired.Name)
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get webhook configuration: %w", err)
+	}
+
+	if equality.Semantic.DeepEqual(current.Webhooks, desired.Webhooks) {
+		return false, nil
+	}
+
+	updated := current.DeepCopy()
+	updated.Webhooks = desired.Webhooks
+	if _, err := webhookConfigs.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		return false, fmt.Errorf("failed to update webhook configuration: %w", err)
+	}
+	log.Infof("Restored drifted ValidatingWebhookConfiguration %s", desired.Name)
+	return true, nil
+}
+
+// runWebhookConfigReconciler reconciles the webhook configuration every
+// webhookReconcileInterval until ctx is cancelled.
+func runWebhookConfigReconciler(ctx context.Context, client kubernetes.Interface) {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		corrected, err := reconcileWebhookConfiguration(ctx, client)
+		if err != nil {
+			log.Errorf("Failed to reconcile webhook configuration: %v", err)
+			return
+		}
+		if corrected {
+			webhookConfigDriftCorrectedTotal.Inc()
+		}
+	}, webhookReconcileInterval)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestReconcileWebhookConfiguration(t *testing.T) {
+	defer func(file string) { webhookCAFile = file }(webhookCAFile)
+	webhookCAFile = filepath.Join(t.TempDir(), "ca.crt")
+	if err := os.WriteFile(webhookCAFile, []byte("ca-1"), 0o600); err != nil {
+		t.Fatalf("Failed to write CA bundle: %v", err)
+	}
+
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	webhookConfigs := client.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+
+	// Missing configuration is created.
+	if corrected, err := reconcileWebhookConfiguration(ctx, client); err != nil || !corrected {
+		t.Fatalf("Expected configuration to be created, got corrected=%t err=%v", corrected, err)
+	}
+
+	// Nothing to do when the live object matches.
+	if corrected, err := reconcileWebhookConfiguration(ctx, client); err != nil || corrected {
+		t.Fatalf("Expected no drift, got corrected=%t err=%v", corrected, err)
+	}
+
+	// A hand-edited rule is restored.
+	current, err := webhookConfigs.Get(ctx, webhookConfigName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get configuration: %v", err)
+	}
+	current.Webhooks[0].Rules[0].Operations = []admissionregistrationv1.OperationType{admissionregistrationv1.OperationAll}
+	if _, err := webhookConfigs.Update(ctx, current, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to update configuration: %v", err)
+	}
+	if corrected, err := reconcileWebhookConfiguration(ctx, client); err != nil || !corrected {
+		t.Fatalf("Expected edited rule to be restored, got corrected=%t err=%v", corrected, err)
+	}
+
+	// A rotated CA bundle is picked up.
+	if err := os.WriteFile(webhookCAFile, []byte("ca-2"), 0o600); err != nil {
+		t.Fatalf("Failed to write CA bundle: %v", err)
+	}
+	if corrected, err := reconcileWebhookConfiguration(ctx, client); err != nil || !corrected {
+		t.Fatalf("Expected rotated CA bundle to be applied, got corrected=%t err=%v", corrected, err)
+	}
+
+	current, err = webhookConfigs.Get(ctx, webhookConfigName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get configuration: %v", err)
+	}
+	if got := string(current.Webhooks[0].ClientConfig.CABundle); got != "ca-2" {
+		t.Errorf("Expected CA bundle ca-2, got %s", got)
+	}
+	if got := current.Webhooks[0].Rules[0].Operations[0]; got != admissionregistrationv1.Update {
+		t.Errorf("Expected operation UPDATE, got %s", got)
+	}
+}
+
+func TestReconcileWebhookConfiguration_MissingCAFile(t *testing.T) {
+	defer func(file string) { webhookCAFile = file }(webhookCAFile)
+	webhookCAFile = filepath.Join(t.TempDir(), "missing.crt")
+
+	if _, err := reconcileWebhookConfiguration(context.Background(), fake.NewSimpleClientset()); err == nil {
+		t.Errorf("Expected an error for a missing CA bundle, got nil")
+	}
+}
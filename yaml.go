@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// isYAMLRequest reports whether the request body is declared as YAML. The
+// tooling endpoints accept YAML because most manifests are written in it.
+func isYAMLRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "application/yaml", "application/x-yaml", "text/yaml":
+		return true
+	}
+	return false
+}
+
+// yamlDocuments reads a multi-document YAML stream and returns each
+// non-empty document converted to JSON.
+type yamlDocuments struct {
+	reader *utilyaml.YAMLReader
+}
+
+func newYAMLDocuments(r io.Reader) *yamlDocuments {
+	return &yamlDocuments{reader: utilyaml.NewYAMLReader(bufio.NewReader(r))}
+}
+
+// next returns the next document as JSON, or io.EOF after the last one.
+func (d *yamlDocuments) next() ([]byte, error) {
+	for {
+		doc, err := d.reader.Read()
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := yaml.YAMLToJSON(doc)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(data, []byte("null")) {
+			return data, nil
+		}
+	}
+}
+
+// decode unmarshals the next document into v.
+func (d *yamlDocuments) decode(v interface{}) error {
+	data, err := d.next()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleValidateBatch_YAML(t *testing.T) {
+	body := `oldObject:
+  metadata: {name: a}
+  spec: {json: "1"}
+object:
+  metadata: {name: a}
+  spec: {json: "2"}
+---
+# a no-op resync
+oldObject:
+  metadata: {name: b}
+  status: {lastResync: "1"}
+object:
+  metadata: {name: b}
+  status: {lastResync: "2"}
+---
+`
+
+	req := httptest.NewRequest(http.MethodPost, "/validate-batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/yaml")
+	w := httptest.NewRecorder()
+
+	handleValidateBatch(w, req)
+
+	var decisions []batchDecision
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var d batchDecision
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			t.Fatalf("Failed to decode decision %q: %v", scanner.Text(), err)
+		}
+		decisions = append(decisions, d)
+	}
+
+	if len(decisions) != 2 {
+		t.Fatalf("Expected 2 decisions, got %d: %v", len(decisions), decisions)
+	}
+	if !decisions[0].Allowed || decisions[0].Name != "a" {
+		t.Errorf("Expected pair a to be allowed, got %+v", decisions[0])
+	}
+	if decisions[1].Allowed || decisions[1].Error != "" {
+		t.Errorf("Expected pair b to be denied, got %+v", decisions[1])
+	}
+}
+
+func TestHandleEvaluate_YAML(t *testing.T) {
+	body := `oldObject:
+  spec: {json: a}
+object:
+  spec: {json: b}
+`
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/evaluate", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/yaml")
+	w := httptest.NewRecorder()
+
+	handleEvaluate(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp evaluateResponseBody
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !resp.Allowed || len(resp.Diff) != 1 {
+		t.Errorf("Expected an allowed spec change with one diff, got %+v", resp)
+	}
+}